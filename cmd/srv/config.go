@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/srv"
+)
+
+// Config is everything the server binary can be configured with. Values
+// come from, in increasing order of precedence, the built-in defaults, a
+// JSON config file (-config or CONFIG_FILE), environment variables and
+// command-line flags.
+type Config struct {
+	Listen       string `json:"listen"`
+	DBPath       string `json:"db_path"`
+	TemplatesDir string `json:"templates_dir"` // empty for the built-in templates
+	StaticDir    string `json:"static_dir"`    // empty for the built-in files
+	// Dev re-reads templates on every request and, unless the directories
+	// above are set, serves them and the static files from ./srv.
+	Dev         bool   `json:"dev"`
+	ProjectRoot string `json:"project_root"`
+	SyncDir     string `json:"sync_dir"`
+	BlobDir     string `json:"blob_dir"` // cached favicons and thumbnails
+	SecretsKey  string `json:"secrets_key"`
+
+	FetchTimeout duration `json:"fetch_timeout"`
+	UserAgent    string   `json:"user_agent"`
+	Workers      int      `json:"workers"`
+	CORSOrigins  []string `json:"cors_origins"`
+	// ScreenshotFallback sends pages without a preview image to an
+	// external screenshot service.
+	ScreenshotFallback bool `json:"screenshot_fallback"`
+
+	ReadTimeout     duration `json:"read_timeout"`
+	WriteTimeout    duration `json:"write_timeout"`
+	IdleTimeout     duration `json:"idle_timeout"`
+	ShutdownTimeout duration `json:"shutdown_timeout"`
+	MaxBodyBytes    int64    `json:"max_body_bytes"`
+	MaxUploadBytes  int64    `json:"max_upload_bytes"` // for the import endpoints
+
+	DefaultOwner   string `json:"default_owner"`
+	AllowAnonymous bool   `json:"allow_anonymous"`
+	// Admins are the user IDs that may manage shared secrets.
+	Admins []string `json:"admins"`
+
+	// OpenAIAPIKey and AnthropicAPIKey are used when the LLM or embeddings
+	// provider matches and has no key of its own.
+	OpenAIAPIKey    string `json:"openai_api_key"`
+	AnthropicAPIKey string `json:"anthropic_api_key"`
+	LLM             struct {
+		Provider  string   `json:"provider"`
+		Model     string   `json:"model"`
+		BaseURL   string   `json:"base_url"`
+		APIKey    string   `json:"api_key"`
+		Timeout   duration `json:"timeout"`
+		MaxTokens int      `json:"max_tokens"`
+	} `json:"llm"`
+	Embeddings struct {
+		Provider string `json:"provider"`
+		Model    string `json:"model"`
+		BaseURL  string `json:"base_url"`
+		APIKey   string `json:"api_key"`
+	} `json:"embeddings"`
+	AutoTag struct {
+		Mode      string  `json:"mode"` // "off", "suggest" or "apply"
+		Threshold float64 `json:"threshold"`
+	} `json:"autotag"`
+
+	// Git holds defaults for settings .github-config.json leaves empty.
+	Git struct {
+		Remote      string `json:"remote"`
+		Branch      string `json:"branch"`
+		Auth        string `json:"auth"`
+		Username    string `json:"username"`
+		SSHKey      string `json:"ssh_key"`
+		SSHKeyDir   string `json:"ssh_key_dir"`
+		AuthorName  string `json:"author_name"`
+		AuthorEmail string `json:"author_email"`
+	} `json:"git"`
+
+	Features srv.Features `json:"features"`
+}
+
+func defaultConfig() *Config {
+	cfg := &Config{
+		Listen:       ":8000",
+		DBPath:       "db.sqlite3",
+		FetchTimeout: duration(srv.DefaultFetchTimeout),
+		UserAgent:    srv.DefaultUserAgent,
+		Workers:      4,
+		CORSOrigins:  []string{"*"},
+		Features:     srv.DefaultFeatures,
+
+		ScreenshotFallback: true,
+
+		ReadTimeout:     duration(30 * time.Second),
+		WriteTimeout:    duration(2 * time.Minute),
+		IdleTimeout:     duration(2 * time.Minute),
+		ShutdownTimeout: duration(30 * time.Second),
+		MaxBodyBytes:    1 << 20,
+		MaxUploadBytes:  64 << 20,
+	}
+	cfg.AutoTag.Mode = "off"
+	cfg.AutoTag.Threshold = 0.7
+	return cfg
+}
+
+// setting ties a config value to its flag and environment variable.
+type setting struct {
+	key    string // path in the config file, e.g. "llm.model"
+	env    string
+	value  any // pointer into Config
+	secret bool
+}
+
+// flagName turns "llm.api_key" into "llm-api-key".
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "listen", env: "LISTEN", value: &c.Listen},
+		{key: "db_path", env: "DB_PATH", value: &c.DBPath},
+		{key: "templates_dir", env: "TEMPLATES_DIR", value: &c.TemplatesDir},
+		{key: "static_dir", env: "STATIC_DIR", value: &c.StaticDir},
+		{key: "dev", env: "DEV", value: &c.Dev},
+		{key: "project_root", env: "PROJECT_ROOT", value: &c.ProjectRoot},
+		{key: "sync_dir", env: "SYNC_DIR", value: &c.SyncDir},
+		{key: "blob_dir", env: "BLOB_DIR", value: &c.BlobDir},
+		{key: "secrets_key", env: "SECRETS_KEY", value: &c.SecretsKey, secret: true},
+		{key: "fetch_timeout", env: "FETCH_TIMEOUT", value: &c.FetchTimeout},
+		{key: "user_agent", env: "USER_AGENT", value: &c.UserAgent},
+		{key: "screenshot_fallback", env: "SCREENSHOT_FALLBACK", value: &c.ScreenshotFallback},
+		{key: "workers", env: "WORKERS", value: &c.Workers},
+		{key: "cors_origins", env: "CORS_ORIGINS", value: &c.CORSOrigins},
+		{key: "read_timeout", env: "READ_TIMEOUT", value: &c.ReadTimeout},
+		{key: "write_timeout", env: "WRITE_TIMEOUT", value: &c.WriteTimeout},
+		{key: "idle_timeout", env: "IDLE_TIMEOUT", value: &c.IdleTimeout},
+		{key: "shutdown_timeout", env: "SHUTDOWN_TIMEOUT", value: &c.ShutdownTimeout},
+		{key: "max_body_bytes", env: "MAX_BODY_BYTES", value: &c.MaxBodyBytes},
+		{key: "max_upload_bytes", env: "MAX_UPLOAD_BYTES", value: &c.MaxUploadBytes},
+		{key: "default_owner", env: "DEFAULT_OWNER_ID", value: &c.DefaultOwner},
+		{key: "allow_anonymous", env: "ALLOW_ANONYMOUS", value: &c.AllowAnonymous},
+		{key: "admins", env: "ADMINS", value: &c.Admins},
+		{key: "openai_api_key", env: "OPENAI_API_KEY", value: &c.OpenAIAPIKey, secret: true},
+		{key: "anthropic_api_key", env: "ANTHROPIC_API_KEY", value: &c.AnthropicAPIKey, secret: true},
+		{key: "llm.provider", env: "LLM_PROVIDER", value: &c.LLM.Provider},
+		{key: "llm.model", env: "LLM_MODEL", value: &c.LLM.Model},
+		{key: "llm.base_url", env: "LLM_BASE_URL", value: &c.LLM.BaseURL},
+		{key: "llm.api_key", env: "LLM_API_KEY", value: &c.LLM.APIKey, secret: true},
+		{key: "llm.timeout", env: "LLM_TIMEOUT", value: &c.LLM.Timeout},
+		{key: "llm.max_tokens", env: "LLM_MAX_TOKENS", value: &c.LLM.MaxTokens},
+		{key: "embeddings.provider", env: "EMBEDDINGS_PROVIDER", value: &c.Embeddings.Provider},
+		{key: "embeddings.model", env: "EMBEDDINGS_MODEL", value: &c.Embeddings.Model},
+		{key: "embeddings.base_url", env: "EMBEDDINGS_BASE_URL", value: &c.Embeddings.BaseURL},
+		{key: "embeddings.api_key", env: "EMBEDDINGS_API_KEY", value: &c.Embeddings.APIKey, secret: true},
+		{key: "autotag.mode", env: "AUTOTAG", value: &c.AutoTag.Mode},
+		{key: "autotag.threshold", env: "AUTOTAG_THRESHOLD", value: &c.AutoTag.Threshold},
+		{key: "git.remote", env: "SYNC_GIT_REMOTE", value: &c.Git.Remote},
+		{key: "git.branch", env: "SYNC_GIT_BRANCH", value: &c.Git.Branch},
+		{key: "git.auth", env: "SYNC_GIT_AUTH", value: &c.Git.Auth},
+		{key: "git.username", env: "SYNC_GIT_USERNAME", value: &c.Git.Username},
+		{key: "git.ssh_key", env: "SYNC_GIT_SSH_KEY", value: &c.Git.SSHKey},
+		{key: "git.ssh_key_dir", env: "SYNC_GIT_SSH_KEY_DIR", value: &c.Git.SSHKeyDir},
+		{key: "git.author_name", env: "SYNC_GIT_AUTHOR_NAME", value: &c.Git.AuthorName},
+		{key: "git.author_email", env: "SYNC_GIT_AUTHOR_EMAIL", value: &c.Git.AuthorEmail},
+		{key: "features.web_search", env: "FEATURE_WEB_SEARCH", value: &c.Features.WebSearch},
+		{key: "features.youtube_import", env: "FEATURE_YOUTUBE_IMPORT", value: &c.Features.YouTubeImport},
+		{key: "features.git_sync", env: "FEATURE_GIT_SYNC", value: &c.Features.GitSync},
+	}
+}
+
+// loadConfig builds the configuration from args (without the program
+// name) and the environment, returning the remaining arguments.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (*Config, []string, error) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("srv", flag.ContinueOnError)
+	configFile, _ := lookupEnv("CONFIG_FILE")
+	fs.StringVar(&configFile, "config", configFile, "JSON config file (env CONFIG_FILE)")
+
+	// Flags are applied last, after the file and environment are read.
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var flagValues []flagValue
+	for _, s := range cfg.settings() {
+		fs.Func(s.flagName(), fmt.Sprintf("%s (env %s)", s.key, s.env), func(v string) error {
+			flagValues = append(flagValues, flagValue{s, v})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read config: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %w", configFile, err)
+		}
+	}
+	for _, s := range cfg.settings() {
+		if v, ok := lookupEnv(s.env); ok && v != "" {
+			if err := setValue(s.value, v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, f := range flagValues {
+		if err := setValue(f.setting.value, f.value); err != nil {
+			return nil, nil, fmt.Errorf("-%s: %w", f.setting.flagName(), err)
+		}
+	}
+	// The git config file, sync directories and blobs live next to the
+	// database unless placed elsewhere.
+	if cfg.ProjectRoot == "" {
+		cfg.ProjectRoot = filepath.Dir(cfg.DBPath)
+	}
+	if cfg.SyncDir == "" {
+		cfg.SyncDir = filepath.Join(cfg.ProjectRoot, "sync-data")
+	}
+	if cfg.BlobDir == "" {
+		cfg.BlobDir = filepath.Join(cfg.ProjectRoot, "blobs")
+	}
+	return cfg, fs.Args(), nil
+}
+
+// setValue parses v into the Config field ptr points at. Lists are
+// comma-separated.
+func setValue(ptr any, v string) error {
+	var err error
+	switch p := ptr.(type) {
+	case *string:
+		*p = v
+	case *int:
+		*p, err = strconv.Atoi(v)
+	case *int64:
+		*p, err = strconv.ParseInt(v, 10, 64)
+	case *float64:
+		*p, err = strconv.ParseFloat(v, 64)
+	case *bool:
+		*p, err = strconv.ParseBool(v)
+	case *duration:
+		var d time.Duration
+		d, err = time.ParseDuration(v)
+		*p = duration(d)
+	case *[]string:
+		*p = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("unsupported setting type %T", ptr))
+	}
+	return err
+}
+
+// printConfig writes the effective configuration as JSON, in the format
+// the config file uses, with secrets masked.
+func printConfig(cfg *Config) error {
+	masked := *cfg
+	for _, s := range masked.settings() {
+		if p, ok := s.value.(*string); ok && s.secret && *p != "" {
+			*p = "********"
+		}
+	}
+	data, err := json.MarshalIndent(masked, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// duration is a time.Duration written as "15s" in the config file.
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = duration(v)
+	return err
+}
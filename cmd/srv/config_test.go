@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(file, []byte(`{"listen": ":9000", "workers": 8, "fetch_timeout": "5s", "llm": {"provider": "fake", "model": "m1"}}`), 0o600)
+	env := map[string]string{
+		"CONFIG_FILE":  file,
+		"LLM_MODEL":    "m2",
+		"WORKERS":      "6",
+		"CORS_ORIGINS": "https://a.example, chrome-extension://*",
+	}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+
+	cfg, args, err := loadConfig([]string{"-workers", "2", "-features-web-search=false", "config", "print"}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(args, []string{"config", "print"}) {
+		t.Errorf("args = %v", args)
+	}
+	if cfg.Listen != ":9000" || time.Duration(cfg.FetchTimeout) != 5*time.Second || cfg.LLM.Provider != "fake" {
+		t.Errorf("file values not applied: %+v", cfg)
+	}
+	if cfg.LLM.Model != "m2" {
+		t.Errorf("env should override the file, got model %q", cfg.LLM.Model)
+	}
+	if cfg.Workers != 2 || cfg.Features.WebSearch || !cfg.Features.GitSync {
+		t.Errorf("flags should override env: workers %d, features %+v", cfg.Workers, cfg.Features)
+	}
+	if !slices.Equal(cfg.CORSOrigins, []string{"https://a.example", "chrome-extension://*"}) {
+		t.Errorf("cors origins = %v", cfg.CORSOrigins)
+	}
+	if cfg.DBPath != "db.sqlite3" || cfg.SyncDir != "sync-data" {
+		t.Errorf("defaults not kept: %q, %q", cfg.DBPath, cfg.SyncDir)
+	}
+
+	os.WriteFile(file, []byte(`{"listn": ":9000"}`), 0o600)
+	if _, _, err := loadConfig(nil, lookup); err == nil {
+		t.Error("expected an error for an unknown config key")
+	}
+}
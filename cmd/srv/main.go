@@ -1,36 +1,230 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
+	"srv.exe.dev/db"
 	"srv.exe.dev/srv"
 )
 
-var flagListenAddr = flag.String("listen", ":8000", "address to listen on")
-
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
 func run() error {
-	flag.Parse()
+	cfg, args, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	switch strings.Join(args, " ") {
+	case "":
+	case "reindex":
+		return reindex(cfg)
+	case "config print":
+		return printConfig(cfg)
+	default:
+		return fmt.Errorf("unknown command %q", strings.Join(args, " "))
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostname = "unknown"
 	}
-	
-	// Set OpenAI API key if available
-	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
-		srv.SetOpenAIKey(key)
-	}
-	
-	server, err := srv.New("db.sqlite3", hostname)
+
+	server, err := srv.New(cfg.DBPath, hostname)
 	if err != nil {
 		return fmt.Errorf("create server: %w", err)
 	}
-	return server.Serve(*flagListenAddr)
+	server.Secrets, err = srv.NewSecretStore(server.DB, cfg.SecretsKey)
+	if err != nil {
+		return fmt.Errorf("open secret store: %w", err)
+	}
+
+	llmConfig := cfg.llmConfig()
+	// Without a configured key, the OpenAI key in the secret store is read
+	// on each request so that setting or rotating it needs no restart.
+	if llmConfig.APIKey == "" && (llmConfig.Provider == "" || llmConfig.Provider == "openai") && server.Secrets.Unlocked() {
+		llmConfig.Provider = "openai"
+		llmConfig.APIKeyFunc = server.Secrets.KeyFunc(srv.SecretOpenAIKey)
+	}
+	llm, err := srv.NewLLMProvider(llmConfig)
+	if err != nil {
+		return fmt.Errorf("configure LLM: %w", err)
+	}
+
+	embeddingConfig := cfg.embeddingConfig()
+	if embeddingConfig.APIKey == "" && embeddingConfig.Provider == "openai" && llmConfig.Provider == "openai" {
+		embeddingConfig.APIKey, embeddingConfig.APIKeyFunc = llmConfig.APIKey, llmConfig.APIKeyFunc
+	}
+	embedder, err := srv.NewEmbeddingProvider(embeddingConfig)
+	if err != nil {
+		return fmt.Errorf("configure embeddings: %w", err)
+	}
+
+	server.LLM = llm
+	server.Embedder = embedder
+	server.AutoTag = cfg.autoTagOptions()
+	configureServer(server, cfg)
+	if err := configureOwners(server, cfg); err != nil {
+		return err
+	}
+	return server.Serve(cfg.Listen)
+}
+
+// llmConfig returns the LLM settings. For backwards compatibility a bare
+// OpenAI key selects the OpenAI provider.
+func (c *Config) llmConfig() srv.LLMConfig {
+	cfg := srv.LLMConfig{
+		Provider:  c.LLM.Provider,
+		Model:     c.LLM.Model,
+		BaseURL:   c.LLM.BaseURL,
+		APIKey:    c.LLM.APIKey,
+		Timeout:   time.Duration(c.LLM.Timeout),
+		MaxTokens: c.LLM.MaxTokens,
+	}
+	if cfg.Provider == "" && c.OpenAIAPIKey != "" {
+		cfg.Provider = "openai"
+	}
+	if cfg.APIKey == "" {
+		switch cfg.Provider {
+		case "openai":
+			cfg.APIKey = c.OpenAIAPIKey
+		case "anthropic":
+			cfg.APIKey = c.AnthropicAPIKey
+		}
+	}
+	return cfg
+}
+
+// embeddingConfig returns the embedding settings. The default is the local
+// hashing embedder.
+func (c *Config) embeddingConfig() srv.EmbeddingConfig {
+	cfg := srv.EmbeddingConfig{
+		Provider: c.Embeddings.Provider,
+		Model:    c.Embeddings.Model,
+		BaseURL:  c.Embeddings.BaseURL,
+		APIKey:   c.Embeddings.APIKey,
+	}
+	if cfg.APIKey == "" && cfg.Provider == "openai" {
+		cfg.APIKey = c.OpenAIAPIKey
+	}
+	return cfg
+}
+
+// autoTagOptions maps autotag.mode ("off", "suggest" or "apply") and
+// autotag.threshold to srv.AutoTagOptions.
+func (c *Config) autoTagOptions() srv.AutoTagOptions {
+	var opts srv.AutoTagOptions
+	switch c.AutoTag.Mode {
+	case "suggest":
+		opts.Enabled, opts.SuggestOnly = true, true
+	case "apply":
+		opts.Enabled = true
+	}
+	opts.Threshold = c.AutoTag.Threshold
+	return opts
+}
+
+// configureServer applies the path, fetch, screenshot, CORS, worker, HTTP
+// server, git and feature settings. Empty paths keep the server's defaults.
+func configureServer(server *srv.Server, cfg *Config) {
+	for _, p := range []struct {
+		dst   *string
+		value string
+	}{
+		{&server.TemplatesDir, cfg.TemplatesDir},
+		{&server.StaticDir, cfg.StaticDir},
+		{&server.ProjectRoot, cfg.ProjectRoot},
+		{&server.SyncDir, cfg.SyncDir},
+		{&server.BlobDir, cfg.BlobDir},
+	} {
+		if p.value != "" {
+			*p.dst = p.value
+		}
+	}
+	server.Dev = cfg.Dev
+	server.FetchTimeout = time.Duration(cfg.FetchTimeout)
+	server.UserAgent = cfg.UserAgent
+	server.ScreenshotFallback = cfg.ScreenshotFallback
+	server.Workers = cfg.Workers
+	server.CORSOrigins = cfg.CORSOrigins
+	server.Features = cfg.Features
+	server.ReadTimeout = time.Duration(cfg.ReadTimeout)
+	server.WriteTimeout = time.Duration(cfg.WriteTimeout)
+	server.IdleTimeout = time.Duration(cfg.IdleTimeout)
+	server.ShutdownTimeout = time.Duration(cfg.ShutdownTimeout)
+	server.MaxBodyBytes = cfg.MaxBodyBytes
+	server.MaxUploadBytes = cfg.MaxUploadBytes
+	server.GitDefaults = srv.GitHubConfig{
+		Remote:      cfg.Git.Remote,
+		Branch:      cfg.Git.Branch,
+		Auth:        cfg.Git.Auth,
+		Username:    cfg.Git.Username,
+		SSHKey:      cfg.Git.SSHKey,
+		AuthorName:  cfg.Git.AuthorName,
+		AuthorEmail: cfg.Git.AuthorEmail,
+	}
+	server.SSHKeyDir = cfg.Git.SSHKeyDir
+}
+
+// configureOwners applies default_owner, allow_anonymous and admins. Rows
+// created before per-user data existed are given to the default owner, and
+// with allow_anonymous requests without identity headers act as that owner.
+// Without a default owner the server refuses to start while such rows
+// exist, rather than leave them where no one can see them.
+func configureOwners(server *srv.Server, cfg *Config) error {
+	server.DefaultOwner = cfg.DefaultOwner
+	server.AllowAnonymous = cfg.AllowAnonymous
+	server.Admins = cfg.Admins
+	if server.DefaultOwner == "" {
+		if server.AllowAnonymous {
+			return fmt.Errorf("allow_anonymous requires default_owner")
+		}
+		n, err := db.CountUnowned(server.DB)
+		if err != nil {
+			return fmt.Errorf("count unowned rows: %w", err)
+		}
+		if n > 0 {
+			return fmt.Errorf("%d rows predate per-user data and have no owner; set default_owner (DEFAULT_OWNER_ID) to the user who should receive them", n)
+		}
+		return nil
+	}
+	n, err := db.AssignUnowned(server.DB, server.DefaultOwner)
+	if err != nil {
+		return fmt.Errorf("assign unowned rows: %w", err)
+	}
+	if n > 0 {
+		slog.Info("assigned unowned rows", "owner", server.DefaultOwner, "rows", n)
+	}
+	return nil
+}
+
+// reindex rebuilds the full-text search index for an existing database.
+func reindex(cfg *Config) error {
+	wdb, err := db.Open(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer wdb.Close()
+	if err := db.RunMigrations(wdb); err != nil {
+		return fmt.Errorf("run migrations: %w", err)
+	}
+	n, err := db.RebuildSearchIndex(wdb)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("reindexed %d bookmarks\n", n)
+	return nil
 }
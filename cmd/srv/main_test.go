@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/srv"
+)
+
+func TestConfigureOwners(t *testing.T) {
+	server, err := srv.New(filepath.Join(t.TempDir(), "db.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := configureOwners(server, &Config{}); err != nil {
+		t.Fatalf("empty database: %v", err)
+	}
+	if _, err := server.DB.Exec("INSERT INTO collections (name) VALUES ('Legacy')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := configureOwners(server, &Config{}); err == nil || !strings.Contains(err.Error(), "default_owner") {
+		t.Errorf("started with unowned rows and no default owner: %v", err)
+	}
+	if err := configureOwners(server, &Config{DefaultOwner: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := configureOwners(server, &Config{}); err != nil {
+		t.Errorf("rows still unowned after assignment: %v", err)
+	}
+}
@@ -40,67 +40,114 @@ func Open(path string) (*sql.DB, error) {
 	return db, nil
 }
 
+// Close folds the write-ahead log back into the database file and closes
+// db, so that a stopped server leaves a single self-contained file.
+func Close(db *sql.DB) error {
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE);"); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return db.Close()
+}
+
 // RunMigrations executes database migrations in numeric order (NNN-*.sql),
 // similar in spirit to exed's exedb.RunMigrations.
 func RunMigrations(db *sql.DB) error {
-	entries, err := migrationFS.ReadDir("migrations")
+	migrations, err := listMigrations()
 	if err != nil {
-		return fmt.Errorf("read migrations dir: %w", err)
+		return err
 	}
-	var migrations []string
-	pat := regexp.MustCompile(`^(\d{3})-.*\.sql$`)
-	for _, e := range entries {
-		if e.IsDir() {
+	executed, err := executedMigrations(db)
+	if err != nil {
+		return err
+	}
+	if executed == nil {
+		slog.Info("db: migrations table not found; running all migrations")
+	}
+	for _, m := range migrations {
+		if executed[m.number] {
 			continue
 		}
-		name := e.Name()
-		if pat.MatchString(name) {
-			migrations = append(migrations, name)
+		if err := executeMigration(db, m.file); err != nil {
+			return fmt.Errorf("execute %s: %w", m.file, err)
 		}
+		slog.Info("db: applied migration", "file", m.file, "number", m.number)
 	}
-	sort.Strings(migrations)
+	return nil
+}
 
-	executed := make(map[int]bool)
-	var tableName string
-	err = db.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='migrations'").Scan(&tableName)
-	switch {
-	case err == nil:
-		rows, err := db.Query("SELECT migration_number FROM migrations")
-		if err != nil {
-			return fmt.Errorf("query executed migrations: %w", err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var n int
-			if err := rows.Scan(&n); err != nil {
-				return fmt.Errorf("scan migration number: %w", err)
-			}
-			executed[n] = true
+// PendingMigrations returns the embedded migrations that db has not run.
+func PendingMigrations(db *sql.DB) ([]string, error) {
+	migrations, err := listMigrations()
+	if err != nil {
+		return nil, err
+	}
+	executed, err := executedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, m := range migrations {
+		if !executed[m.number] {
+			pending = append(pending, m.file)
 		}
-	case errors.Is(err, sql.ErrNoRows):
-		slog.Info("db: migrations table not found; running all migrations")
-	default:
-		return fmt.Errorf("check migrations table: %w", err)
 	}
+	return pending, nil
+}
 
-	for _, m := range migrations {
-		match := pat.FindStringSubmatch(m)
-		if len(match) != 2 {
-			return fmt.Errorf("invalid migration filename: %s", m)
+type migration struct {
+	file   string
+	number int
+}
+
+var migrationPattern = regexp.MustCompile(`^(\d{3})-.*\.sql$`)
+
+// listMigrations returns the embedded migrations in numeric order.
+func listMigrations() ([]migration, error) {
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations dir: %w", err)
+	}
+	var migrations []migration
+	for _, e := range entries {
+		match := migrationPattern.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
 		}
 		n, err := strconv.Atoi(match[1])
 		if err != nil {
-			return fmt.Errorf("parse migration number %s: %w", m, err)
+			return nil, fmt.Errorf("parse migration number %s: %w", e.Name(), err)
 		}
-		if executed[n] {
-			continue
-		}
-		if err := executeMigration(db, m); err != nil {
-			return fmt.Errorf("execute %s: %w", m, err)
+		migrations = append(migrations, migration{file: e.Name(), number: n})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].file < migrations[j].file })
+	return migrations, nil
+}
+
+// executedMigrations returns the numbers recorded in the migrations table,
+// or nil if the table doesn't exist yet.
+func executedMigrations(db *sql.DB) (map[int]bool, error) {
+	var tableName string
+	err := db.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='migrations'").Scan(&tableName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("check migrations table: %w", err)
+	}
+	rows, err := db.Query("SELECT migration_number FROM migrations")
+	if err != nil {
+		return nil, fmt.Errorf("query executed migrations: %w", err)
+	}
+	defer rows.Close()
+	executed := make(map[int]bool)
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			return nil, fmt.Errorf("scan migration number: %w", err)
 		}
-		slog.Info("db: applied migration", "file", m, "number", n)
+		executed[n] = true
 	}
-	return nil
+	return executed, rows.Err()
 }
 
 func executeMigration(db *sql.DB, filename string) error {
@@ -113,3 +160,72 @@ func executeMigration(db *sql.DB, filename string) error {
 	}
 	return nil
 }
+
+// RebuildSearchIndex repopulates bookmarks_fts from the bookmarks, tags and
+// bookmark_tags tables. The triggers keep the index current during normal
+// operation; this is for databases whose index has drifted or predates them.
+func RebuildSearchIndex(db *sql.DB) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM bookmarks_fts"); err != nil {
+		return 0, fmt.Errorf("clear search index: %w", err)
+	}
+	res, err := tx.Exec(`
+		INSERT INTO bookmarks_fts(rowid, title, description, summary, keywords, url, tags)
+		SELECT b.id, b.title, b.description, b.summary, b.keywords, b.url,
+			(SELECT group_concat(t.name, ' ') FROM tags t
+			 JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = b.id)
+		FROM bookmarks b`)
+	if err != nil {
+		return 0, fmt.Errorf("populate search index: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	if _, err := tx.Exec("INSERT INTO bookmarks_fts(bookmarks_fts) VALUES ('optimize')"); err != nil {
+		return 0, fmt.Errorf("optimize search index: %w", err)
+	}
+	return n, tx.Commit()
+}
+
+// ownedTables are the tables with an owner_id column.
+var ownedTables = []string{"bookmarks", "tags", "collections", "jobs"}
+
+// CountUnowned returns the number of rows without an owner.
+func CountUnowned(db *sql.DB) (int64, error) {
+	var total int64
+	for _, table := range ownedTables {
+		var n int64
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table + " WHERE owner_id = ''").Scan(&n); err != nil {
+			return 0, fmt.Errorf("count %s: %w", table, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// AssignUnowned gives rows without an owner (those that predate per-user
+// data) to owner. It returns the number of rows reassigned.
+func AssignUnowned(db *sql.DB, owner string) (int64, error) {
+	if owner == "" {
+		return 0, fmt.Errorf("owner required")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, table := range ownedTables {
+		res, err := tx.Exec("UPDATE "+table+" SET owner_id = ? WHERE owner_id = ''", owner)
+		if err != nil {
+			return 0, fmt.Errorf("assign %s: %w", table, err)
+		}
+		n, _ := res.RowsAffected()
+		total += n
+	}
+	return total, tx.Commit()
+}
@@ -7,76 +7,100 @@ package dbgen
 
 import (
 	"context"
+	"time"
 )
 
 const addBookmarkToCollection = `-- name: AddBookmarkToCollection :exec
-INSERT OR IGNORE INTO bookmark_collections (bookmark_id, collection_id) VALUES (?, ?)
+INSERT OR IGNORE INTO bookmark_collections (bookmark_id, collection_id)
+SELECT b.id, c.id FROM bookmarks b
+JOIN collections c ON c.owner_id = b.owner_id
+WHERE b.owner_id = ?1 AND b.id = ?2 AND c.id = ?3
 `
 
 type AddBookmarkToCollectionParams struct {
-	BookmarkID   int64 `json:"bookmark_id"`
-	CollectionID int64 `json:"collection_id"`
+	OwnerID      string `json:"owner_id"`
+	BookmarkID   int64  `json:"bookmark_id"`
+	CollectionID int64  `json:"collection_id"`
 }
 
+// Both rows must belong to the owner; otherwise nothing is inserted.
 func (q *Queries) AddBookmarkToCollection(ctx context.Context, arg AddBookmarkToCollectionParams) error {
-	_, err := q.db.ExecContext(ctx, addBookmarkToCollection, arg.BookmarkID, arg.CollectionID)
+	_, err := q.db.ExecContext(ctx, addBookmarkToCollection, arg.OwnerID, arg.BookmarkID, arg.CollectionID)
 	return err
 }
 
 const addTagToBookmark = `-- name: AddTagToBookmark :exec
-INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id) VALUES (?, ?)
+INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id)
+SELECT b.id, t.id FROM bookmarks b
+JOIN tags t ON t.owner_id = b.owner_id
+WHERE b.owner_id = ?1 AND b.id = ?2 AND t.id = ?3
 `
 
 type AddTagToBookmarkParams struct {
-	BookmarkID int64 `json:"bookmark_id"`
-	TagID      int64 `json:"tag_id"`
+	OwnerID    string `json:"owner_id"`
+	BookmarkID int64  `json:"bookmark_id"`
+	TagID      int64  `json:"tag_id"`
 }
 
+// Both rows must belong to the owner; otherwise nothing is inserted.
 func (q *Queries) AddTagToBookmark(ctx context.Context, arg AddTagToBookmarkParams) error {
-	_, err := q.db.ExecContext(ctx, addTagToBookmark, arg.BookmarkID, arg.TagID)
+	_, err := q.db.ExecContext(ctx, addTagToBookmark, arg.OwnerID, arg.BookmarkID, arg.TagID)
 	return err
 }
 
 const countBookmarks = `-- name: CountBookmarks :one
-SELECT COUNT(*) FROM bookmarks
+SELECT COUNT(*) FROM bookmarks WHERE owner_id = ?
 `
 
-func (q *Queries) CountBookmarks(ctx context.Context) (int64, error) {
-	row := q.db.QueryRowContext(ctx, countBookmarks)
+func (q *Queries) CountBookmarks(ctx context.Context, ownerID string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countBookmarks, ownerID)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
 const countBookmarksBySource = `-- name: CountBookmarksBySource :one
-SELECT COUNT(*) FROM bookmarks WHERE source_type = ?
+SELECT COUNT(*) FROM bookmarks WHERE owner_id = ? AND source_type = ?
 `
 
-func (q *Queries) CountBookmarksBySource(ctx context.Context, sourceType string) (int64, error) {
-	row := q.db.QueryRowContext(ctx, countBookmarksBySource, sourceType)
+type CountBookmarksBySourceParams struct {
+	OwnerID    string `json:"owner_id"`
+	SourceType string `json:"source_type"`
+}
+
+func (q *Queries) CountBookmarksBySource(ctx context.Context, arg CountBookmarksBySourceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countBookmarksBySource, arg.OwnerID, arg.SourceType)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
 const countBookmarksInCollection = `-- name: CountBookmarksInCollection :one
-SELECT COUNT(*) FROM bookmark_collections WHERE collection_id = ?
+SELECT COUNT(*) FROM bookmark_collections bc
+JOIN collections c ON c.id = bc.collection_id
+WHERE c.owner_id = ? AND bc.collection_id = ?
 `
 
-func (q *Queries) CountBookmarksInCollection(ctx context.Context, collectionID int64) (int64, error) {
-	row := q.db.QueryRowContext(ctx, countBookmarksInCollection, collectionID)
+type CountBookmarksInCollectionParams struct {
+	OwnerID      string `json:"owner_id"`
+	CollectionID int64  `json:"collection_id"`
+}
+
+func (q *Queries) CountBookmarksInCollection(ctx context.Context, arg CountBookmarksInCollectionParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countBookmarksInCollection, arg.OwnerID, arg.CollectionID)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
 const createBookmark = `-- name: CreateBookmark :one
-INSERT INTO bookmarks (url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-RETURNING id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords
+INSERT INTO bookmarks (owner_id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+RETURNING id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id
 `
 
 type CreateBookmarkParams struct {
+	OwnerID     string  `json:"owner_id"`
 	Url         string  `json:"url"`
 	Title       string  `json:"title"`
 	Description *string `json:"description"`
@@ -88,6 +112,7 @@ type CreateBookmarkParams struct {
 
 func (q *Queries) CreateBookmark(ctx context.Context, arg CreateBookmarkParams) (Bookmark, error) {
 	row := q.db.QueryRowContext(ctx, createBookmark,
+		arg.OwnerID,
 		arg.Url,
 		arg.Title,
 		arg.Description,
@@ -109,16 +134,18 @@ func (q *Queries) CreateBookmark(ctx context.Context, arg CreateBookmarkParams)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Keywords,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
 const createCollection = `-- name: CreateCollection :one
-INSERT INTO collections (name, description, icon) VALUES (?, ?, ?)
-RETURNING id, name, description, icon, created_at
+INSERT INTO collections (owner_id, name, description, icon) VALUES (?, ?, ?, ?)
+RETURNING id, name, description, icon, created_at, owner_id
 `
 
 type CreateCollectionParams struct {
+	OwnerID     string  `json:"owner_id"`
 	Name        string  `json:"name"`
 	Description *string `json:"description"`
 	Icon        *string `json:"icon"`
@@ -126,7 +153,12 @@ type CreateCollectionParams struct {
 
 // Collections
 func (q *Queries) CreateCollection(ctx context.Context, arg CreateCollectionParams) (Collection, error) {
-	row := q.db.QueryRowContext(ctx, createCollection, arg.Name, arg.Description, arg.Icon)
+	row := q.db.QueryRowContext(ctx, createCollection,
+		arg.OwnerID,
+		arg.Name,
+		arg.Description,
+		arg.Icon,
+	)
 	var i Collection
 	err := row.Scan(
 		&i.ID,
@@ -134,62 +166,134 @@ func (q *Queries) CreateCollection(ctx context.Context, arg CreateCollectionPara
 		&i.Description,
 		&i.Icon,
 		&i.CreatedAt,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
 const createTag = `-- name: CreateTag :one
-INSERT INTO tags (name, color) VALUES (?, ?)
-ON CONFLICT(name) DO UPDATE SET name = excluded.name
-RETURNING id, name, color
+INSERT INTO tags (owner_id, name, color) VALUES (?, ?, ?)
+ON CONFLICT(owner_id, name) DO UPDATE SET name = excluded.name
+RETURNING id, owner_id, name, color
 `
 
 type CreateTagParams struct {
-	Name  string  `json:"name"`
-	Color *string `json:"color"`
+	OwnerID string  `json:"owner_id"`
+	Name    string  `json:"name"`
+	Color   *string `json:"color"`
 }
 
 // Tags
 func (q *Queries) CreateTag(ctx context.Context, arg CreateTagParams) (Tag, error) {
-	row := q.db.QueryRowContext(ctx, createTag, arg.Name, arg.Color)
+	row := q.db.QueryRowContext(ctx, createTag, arg.OwnerID, arg.Name, arg.Color)
 	var i Tag
-	err := row.Scan(&i.ID, &i.Name, &i.Color)
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Color,
+	)
 	return i, err
 }
 
 const deleteBookmark = `-- name: DeleteBookmark :exec
-DELETE FROM bookmarks WHERE id = ?
+DELETE FROM bookmarks WHERE owner_id = ? AND id = ?
 `
 
-func (q *Queries) DeleteBookmark(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteBookmark, id)
+type DeleteBookmarkParams struct {
+	OwnerID string `json:"owner_id"`
+	ID      int64  `json:"id"`
+}
+
+func (q *Queries) DeleteBookmark(ctx context.Context, arg DeleteBookmarkParams) error {
+	_, err := q.db.ExecContext(ctx, deleteBookmark, arg.OwnerID, arg.ID)
 	return err
 }
 
 const deleteCollection = `-- name: DeleteCollection :exec
-DELETE FROM collections WHERE id = ?
+DELETE FROM collections WHERE owner_id = ? AND id = ?
+`
+
+type DeleteCollectionParams struct {
+	OwnerID string `json:"owner_id"`
+	ID      int64  `json:"id"`
+}
+
+func (q *Queries) DeleteCollection(ctx context.Context, arg DeleteCollectionParams) error {
+	_, err := q.db.ExecContext(ctx, deleteCollection, arg.OwnerID, arg.ID)
+	return err
+}
+
+const deleteOwnerBookmarkCollections = `-- name: DeleteOwnerBookmarkCollections :exec
+DELETE FROM bookmark_collections WHERE bookmark_id IN (SELECT id FROM bookmarks WHERE owner_id = ?)
+`
+
+func (q *Queries) DeleteOwnerBookmarkCollections(ctx context.Context, ownerID string) error {
+	_, err := q.db.ExecContext(ctx, deleteOwnerBookmarkCollections, ownerID)
+	return err
+}
+
+const deleteOwnerBookmarkTags = `-- name: DeleteOwnerBookmarkTags :exec
+DELETE FROM bookmark_tags WHERE bookmark_id IN (SELECT id FROM bookmarks WHERE owner_id = ?)
 `
 
-func (q *Queries) DeleteCollection(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteCollection, id)
+func (q *Queries) DeleteOwnerBookmarkTags(ctx context.Context, ownerID string) error {
+	_, err := q.db.ExecContext(ctx, deleteOwnerBookmarkTags, ownerID)
+	return err
+}
+
+const deleteOwnerBookmarks = `-- name: DeleteOwnerBookmarks :exec
+DELETE FROM bookmarks WHERE owner_id = ?
+`
+
+func (q *Queries) DeleteOwnerBookmarks(ctx context.Context, ownerID string) error {
+	_, err := q.db.ExecContext(ctx, deleteOwnerBookmarks, ownerID)
+	return err
+}
+
+const deleteOwnerCollections = `-- name: DeleteOwnerCollections :exec
+DELETE FROM collections WHERE owner_id = ?
+`
+
+func (q *Queries) DeleteOwnerCollections(ctx context.Context, ownerID string) error {
+	_, err := q.db.ExecContext(ctx, deleteOwnerCollections, ownerID)
+	return err
+}
+
+const deleteOwnerTags = `-- name: DeleteOwnerTags :exec
+DELETE FROM tags WHERE owner_id = ?
+`
+
+func (q *Queries) DeleteOwnerTags(ctx context.Context, ownerID string) error {
+	_, err := q.db.ExecContext(ctx, deleteOwnerTags, ownerID)
 	return err
 }
 
 const deleteTag = `-- name: DeleteTag :exec
-DELETE FROM tags WHERE id = ?
+DELETE FROM tags WHERE owner_id = ? AND id = ?
 `
 
-func (q *Queries) DeleteTag(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteTag, id)
+type DeleteTagParams struct {
+	OwnerID string `json:"owner_id"`
+	ID      int64  `json:"id"`
+}
+
+func (q *Queries) DeleteTag(ctx context.Context, arg DeleteTagParams) error {
+	_, err := q.db.ExecContext(ctx, deleteTag, arg.OwnerID, arg.ID)
 	return err
 }
 
 const getBookmark = `-- name: GetBookmark :one
-SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords FROM bookmarks WHERE id = ?
+SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id FROM bookmarks WHERE owner_id = ? AND id = ?
 `
 
-func (q *Queries) GetBookmark(ctx context.Context, id int64) (Bookmark, error) {
-	row := q.db.QueryRowContext(ctx, getBookmark, id)
+type GetBookmarkParams struct {
+	OwnerID string `json:"owner_id"`
+	ID      int64  `json:"id"`
+}
+
+func (q *Queries) GetBookmark(ctx context.Context, arg GetBookmarkParams) (Bookmark, error) {
+	row := q.db.QueryRowContext(ctx, getBookmark, arg.OwnerID, arg.ID)
 	var i Bookmark
 	err := row.Scan(
 		&i.ID,
@@ -203,16 +307,22 @@ func (q *Queries) GetBookmark(ctx context.Context, id int64) (Bookmark, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Keywords,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
 const getBookmarkByURL = `-- name: GetBookmarkByURL :one
-SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords FROM bookmarks WHERE url = ?
+SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id FROM bookmarks WHERE owner_id = ? AND url = ?
 `
 
-func (q *Queries) GetBookmarkByURL(ctx context.Context, url string) (Bookmark, error) {
-	row := q.db.QueryRowContext(ctx, getBookmarkByURL, url)
+type GetBookmarkByURLParams struct {
+	OwnerID string `json:"owner_id"`
+	Url     string `json:"url"`
+}
+
+func (q *Queries) GetBookmarkByURL(ctx context.Context, arg GetBookmarkByURLParams) (Bookmark, error) {
+	row := q.db.QueryRowContext(ctx, getBookmarkByURL, arg.OwnerID, arg.Url)
 	var i Bookmark
 	err := row.Scan(
 		&i.ID,
@@ -226,18 +336,24 @@ func (q *Queries) GetBookmarkByURL(ctx context.Context, url string) (Bookmark, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Keywords,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
 const getBookmarkCollections = `-- name: GetBookmarkCollections :many
-SELECT c.id, c.name, c.description, c.icon, c.created_at FROM collections c
+SELECT c.id, c.name, c.description, c.icon, c.created_at, c.owner_id FROM collections c
 JOIN bookmark_collections bc ON c.id = bc.collection_id
-WHERE bc.bookmark_id = ?
+WHERE c.owner_id = ? AND bc.bookmark_id = ?
 `
 
-func (q *Queries) GetBookmarkCollections(ctx context.Context, bookmarkID int64) ([]Collection, error) {
-	rows, err := q.db.QueryContext(ctx, getBookmarkCollections, bookmarkID)
+type GetBookmarkCollectionsParams struct {
+	OwnerID    string `json:"owner_id"`
+	BookmarkID int64  `json:"bookmark_id"`
+}
+
+func (q *Queries) GetBookmarkCollections(ctx context.Context, arg GetBookmarkCollectionsParams) ([]Collection, error) {
+	rows, err := q.db.QueryContext(ctx, getBookmarkCollections, arg.OwnerID, arg.BookmarkID)
 	if err != nil {
 		return nil, err
 	}
@@ -251,6 +367,7 @@ func (q *Queries) GetBookmarkCollections(ctx context.Context, bookmarkID int64)
 			&i.Description,
 			&i.Icon,
 			&i.CreatedAt,
+			&i.OwnerID,
 		); err != nil {
 			return nil, err
 		}
@@ -266,13 +383,18 @@ func (q *Queries) GetBookmarkCollections(ctx context.Context, bookmarkID int64)
 }
 
 const getBookmarkTags = `-- name: GetBookmarkTags :many
-SELECT t.id, t.name, t.color FROM tags t
+SELECT t.id, t.owner_id, t.name, t.color FROM tags t
 JOIN bookmark_tags bt ON t.id = bt.tag_id
-WHERE bt.bookmark_id = ?
+WHERE t.owner_id = ? AND bt.bookmark_id = ?
 `
 
-func (q *Queries) GetBookmarkTags(ctx context.Context, bookmarkID int64) ([]Tag, error) {
-	rows, err := q.db.QueryContext(ctx, getBookmarkTags, bookmarkID)
+type GetBookmarkTagsParams struct {
+	OwnerID    string `json:"owner_id"`
+	BookmarkID int64  `json:"bookmark_id"`
+}
+
+func (q *Queries) GetBookmarkTags(ctx context.Context, arg GetBookmarkTagsParams) ([]Tag, error) {
+	rows, err := q.db.QueryContext(ctx, getBookmarkTags, arg.OwnerID, arg.BookmarkID)
 	if err != nil {
 		return nil, err
 	}
@@ -280,7 +402,12 @@ func (q *Queries) GetBookmarkTags(ctx context.Context, bookmarkID int64) ([]Tag,
 	items := []Tag{}
 	for rows.Next() {
 		var i Tag
-		if err := rows.Scan(&i.ID, &i.Name, &i.Color); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Color,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -295,21 +422,27 @@ func (q *Queries) GetBookmarkTags(ctx context.Context, bookmarkID int64) ([]Tag,
 }
 
 const getBookmarksByTag = `-- name: GetBookmarksByTag :many
-SELECT b.id, b.url, b.title, b.description, b.summary, b.source_type, b.favicon_url, b.image_url, b.created_at, b.updated_at, b.keywords FROM bookmarks b
+SELECT b.id, b.url, b.title, b.description, b.summary, b.source_type, b.favicon_url, b.image_url, b.created_at, b.updated_at, b.keywords, b.owner_id FROM bookmarks b
 JOIN bookmark_tags bt ON b.id = bt.bookmark_id
-WHERE bt.tag_id = ?
+WHERE b.owner_id = ? AND bt.tag_id = ?
 ORDER BY b.created_at DESC
 LIMIT ? OFFSET ?
 `
 
 type GetBookmarksByTagParams struct {
-	TagID  int64 `json:"tag_id"`
-	Limit  int64 `json:"limit"`
-	Offset int64 `json:"offset"`
+	OwnerID string `json:"owner_id"`
+	TagID   int64  `json:"tag_id"`
+	Limit   int64  `json:"limit"`
+	Offset  int64  `json:"offset"`
 }
 
 func (q *Queries) GetBookmarksByTag(ctx context.Context, arg GetBookmarksByTagParams) ([]Bookmark, error) {
-	rows, err := q.db.QueryContext(ctx, getBookmarksByTag, arg.TagID, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, getBookmarksByTag,
+		arg.OwnerID,
+		arg.TagID,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -329,6 +462,7 @@ func (q *Queries) GetBookmarksByTag(ctx context.Context, arg GetBookmarksByTagPa
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Keywords,
+			&i.OwnerID,
 		); err != nil {
 			return nil, err
 		}
@@ -344,21 +478,27 @@ func (q *Queries) GetBookmarksByTag(ctx context.Context, arg GetBookmarksByTagPa
 }
 
 const getBookmarksInCollection = `-- name: GetBookmarksInCollection :many
-SELECT b.id, b.url, b.title, b.description, b.summary, b.source_type, b.favicon_url, b.image_url, b.created_at, b.updated_at, b.keywords FROM bookmarks b
+SELECT b.id, b.url, b.title, b.description, b.summary, b.source_type, b.favicon_url, b.image_url, b.created_at, b.updated_at, b.keywords, b.owner_id FROM bookmarks b
 JOIN bookmark_collections bc ON b.id = bc.bookmark_id
-WHERE bc.collection_id = ?
+WHERE b.owner_id = ? AND bc.collection_id = ?
 ORDER BY b.created_at DESC
 LIMIT ? OFFSET ?
 `
 
 type GetBookmarksInCollectionParams struct {
-	CollectionID int64 `json:"collection_id"`
-	Limit        int64 `json:"limit"`
-	Offset       int64 `json:"offset"`
+	OwnerID      string `json:"owner_id"`
+	CollectionID int64  `json:"collection_id"`
+	Limit        int64  `json:"limit"`
+	Offset       int64  `json:"offset"`
 }
 
 func (q *Queries) GetBookmarksInCollection(ctx context.Context, arg GetBookmarksInCollectionParams) ([]Bookmark, error) {
-	rows, err := q.db.QueryContext(ctx, getBookmarksInCollection, arg.CollectionID, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, getBookmarksInCollection,
+		arg.OwnerID,
+		arg.CollectionID,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -378,6 +518,7 @@ func (q *Queries) GetBookmarksInCollection(ctx context.Context, arg GetBookmarks
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Keywords,
+			&i.OwnerID,
 		); err != nil {
 			return nil, err
 		}
@@ -393,11 +534,16 @@ func (q *Queries) GetBookmarksInCollection(ctx context.Context, arg GetBookmarks
 }
 
 const getCollection = `-- name: GetCollection :one
-SELECT id, name, description, icon, created_at FROM collections WHERE id = ?
+SELECT id, name, description, icon, created_at, owner_id FROM collections WHERE owner_id = ? AND id = ?
 `
 
-func (q *Queries) GetCollection(ctx context.Context, id int64) (Collection, error) {
-	row := q.db.QueryRowContext(ctx, getCollection, id)
+type GetCollectionParams struct {
+	OwnerID string `json:"owner_id"`
+	ID      int64  `json:"id"`
+}
+
+func (q *Queries) GetCollection(ctx context.Context, arg GetCollectionParams) (Collection, error) {
+	row := q.db.QueryRowContext(ctx, getCollection, arg.OwnerID, arg.ID)
 	var i Collection
 	err := row.Scan(
 		&i.ID,
@@ -405,43 +551,305 @@ func (q *Queries) GetCollection(ctx context.Context, id int64) (Collection, erro
 		&i.Description,
 		&i.Icon,
 		&i.CreatedAt,
+		&i.OwnerID,
+	)
+	return i, err
+}
+
+const getCollectionByName = `-- name: GetCollectionByName :one
+SELECT id, name, description, icon, created_at, owner_id FROM collections WHERE owner_id = ? AND name = ? ORDER BY id LIMIT 1
+`
+
+type GetCollectionByNameParams struct {
+	OwnerID string `json:"owner_id"`
+	Name    string `json:"name"`
+}
+
+func (q *Queries) GetCollectionByName(ctx context.Context, arg GetCollectionByNameParams) (Collection, error) {
+	row := q.db.QueryRowContext(ctx, getCollectionByName, arg.OwnerID, arg.Name)
+	var i Collection
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.Icon,
+		&i.CreatedAt,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
 const getTag = `-- name: GetTag :one
-SELECT id, name, color FROM tags WHERE id = ?
+SELECT id, owner_id, name, color FROM tags WHERE owner_id = ? AND id = ?
 `
 
-func (q *Queries) GetTag(ctx context.Context, id int64) (Tag, error) {
-	row := q.db.QueryRowContext(ctx, getTag, id)
+type GetTagParams struct {
+	OwnerID string `json:"owner_id"`
+	ID      int64  `json:"id"`
+}
+
+func (q *Queries) GetTag(ctx context.Context, arg GetTagParams) (Tag, error) {
+	row := q.db.QueryRowContext(ctx, getTag, arg.OwnerID, arg.ID)
 	var i Tag
-	err := row.Scan(&i.ID, &i.Name, &i.Color)
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Color,
+	)
 	return i, err
 }
 
 const getTagByName = `-- name: GetTagByName :one
-SELECT id, name, color FROM tags WHERE name = ?
+SELECT id, owner_id, name, color FROM tags WHERE owner_id = ? AND name = ?
 `
 
-func (q *Queries) GetTagByName(ctx context.Context, name string) (Tag, error) {
-	row := q.db.QueryRowContext(ctx, getTagByName, name)
+type GetTagByNameParams struct {
+	OwnerID string `json:"owner_id"`
+	Name    string `json:"name"`
+}
+
+func (q *Queries) GetTagByName(ctx context.Context, arg GetTagByNameParams) (Tag, error) {
+	row := q.db.QueryRowContext(ctx, getTagByName, arg.OwnerID, arg.Name)
 	var i Tag
-	err := row.Scan(&i.ID, &i.Name, &i.Color)
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Color,
+	)
+	return i, err
+}
+
+const importBookmark = `-- name: ImportBookmark :one
+INSERT INTO bookmarks (owner_id, url, title, description, summary, source_type, favicon_url, image_url, keywords, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id
+`
+
+type ImportBookmarkParams struct {
+	OwnerID     string    `json:"owner_id"`
+	Url         string    `json:"url"`
+	Title       string    `json:"title"`
+	Description *string   `json:"description"`
+	Summary     *string   `json:"summary"`
+	SourceType  string    `json:"source_type"`
+	FaviconUrl  *string   `json:"favicon_url"`
+	ImageUrl    *string   `json:"image_url"`
+	Keywords    *string   `json:"keywords"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (q *Queries) ImportBookmark(ctx context.Context, arg ImportBookmarkParams) (Bookmark, error) {
+	row := q.db.QueryRowContext(ctx, importBookmark,
+		arg.OwnerID,
+		arg.Url,
+		arg.Title,
+		arg.Description,
+		arg.Summary,
+		arg.SourceType,
+		arg.FaviconUrl,
+		arg.ImageUrl,
+		arg.Keywords,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	)
+	var i Bookmark
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Title,
+		&i.Description,
+		&i.Summary,
+		&i.SourceType,
+		&i.FaviconUrl,
+		&i.ImageUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Keywords,
+		&i.OwnerID,
+	)
 	return i, err
 }
 
+const listAllBookmarkCollections = `-- name: ListAllBookmarkCollections :many
+SELECT bc.bookmark_id, bc.collection_id FROM bookmark_collections bc
+JOIN collections c ON c.id = bc.collection_id
+WHERE c.owner_id = ?
+ORDER BY bc.collection_id, bc.bookmark_id
+`
+
+func (q *Queries) ListAllBookmarkCollections(ctx context.Context, ownerID string) ([]BookmarkCollection, error) {
+	rows, err := q.db.QueryContext(ctx, listAllBookmarkCollections, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BookmarkCollection{}
+	for rows.Next() {
+		var i BookmarkCollection
+		if err := rows.Scan(&i.BookmarkID, &i.CollectionID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllBookmarkTagNames = `-- name: ListAllBookmarkTagNames :many
+SELECT bt.bookmark_id, t.name FROM bookmark_tags bt
+JOIN tags t ON t.id = bt.tag_id
+WHERE t.owner_id = ?
+ORDER BY bt.bookmark_id, t.name
+`
+
+type ListAllBookmarkTagNamesRow struct {
+	BookmarkID int64  `json:"bookmark_id"`
+	Name       string `json:"name"`
+}
+
+func (q *Queries) ListAllBookmarkTagNames(ctx context.Context, ownerID string) ([]ListAllBookmarkTagNamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAllBookmarkTagNames, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAllBookmarkTagNamesRow{}
+	for rows.Next() {
+		var i ListAllBookmarkTagNamesRow
+		if err := rows.Scan(&i.BookmarkID, &i.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllBookmarkTags = `-- name: ListAllBookmarkTags :many
+SELECT bt.bookmark_id, bt.tag_id FROM bookmark_tags bt
+JOIN tags t ON t.id = bt.tag_id
+WHERE t.owner_id = ?
+ORDER BY bt.bookmark_id, bt.tag_id
+`
+
+func (q *Queries) ListAllBookmarkTags(ctx context.Context, ownerID string) ([]BookmarkTag, error) {
+	rows, err := q.db.QueryContext(ctx, listAllBookmarkTags, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BookmarkTag{}
+	for rows.Next() {
+		var i BookmarkTag
+		if err := rows.Scan(&i.BookmarkID, &i.TagID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllBookmarks = `-- name: ListAllBookmarks :many
+SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id FROM bookmarks WHERE owner_id = ? ORDER BY created_at, id
+`
+
+func (q *Queries) ListAllBookmarks(ctx context.Context, ownerID string) ([]Bookmark, error) {
+	rows, err := q.db.QueryContext(ctx, listAllBookmarks, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Bookmark{}
+	for rows.Next() {
+		var i Bookmark
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Description,
+			&i.Summary,
+			&i.SourceType,
+			&i.FaviconUrl,
+			&i.ImageUrl,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Keywords,
+			&i.OwnerID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBookmarkIDsMissingMetadata = `-- name: ListBookmarkIDsMissingMetadata :many
+SELECT id FROM bookmarks
+WHERE owner_id = ? AND (image_url IS NULL OR image_url NOT LIKE '/thumbnails/%' OR summary IS NULL OR summary = ''
+    OR favicon_url IS NULL OR favicon_url NOT LIKE '/favicons/%')
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListBookmarkIDsMissingMetadata(ctx context.Context, ownerID string) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listBookmarkIDsMissingMetadata, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listBookmarks = `-- name: ListBookmarks :many
-SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords FROM bookmarks ORDER BY created_at DESC LIMIT ? OFFSET ?
+SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id FROM bookmarks WHERE owner_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?
 `
 
 type ListBookmarksParams struct {
-	Limit  int64 `json:"limit"`
-	Offset int64 `json:"offset"`
+	OwnerID string `json:"owner_id"`
+	Limit   int64  `json:"limit"`
+	Offset  int64  `json:"offset"`
 }
 
 func (q *Queries) ListBookmarks(ctx context.Context, arg ListBookmarksParams) ([]Bookmark, error) {
-	rows, err := q.db.QueryContext(ctx, listBookmarks, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listBookmarks, arg.OwnerID, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -461,6 +869,7 @@ func (q *Queries) ListBookmarks(ctx context.Context, arg ListBookmarksParams) ([
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Keywords,
+			&i.OwnerID,
 		); err != nil {
 			return nil, err
 		}
@@ -476,17 +885,23 @@ func (q *Queries) ListBookmarks(ctx context.Context, arg ListBookmarksParams) ([
 }
 
 const listBookmarksBySource = `-- name: ListBookmarksBySource :many
-SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords FROM bookmarks WHERE source_type = ? ORDER BY created_at DESC LIMIT ? OFFSET ?
+SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id FROM bookmarks WHERE owner_id = ? AND source_type = ? ORDER BY created_at DESC LIMIT ? OFFSET ?
 `
 
 type ListBookmarksBySourceParams struct {
+	OwnerID    string `json:"owner_id"`
 	SourceType string `json:"source_type"`
 	Limit      int64  `json:"limit"`
 	Offset     int64  `json:"offset"`
 }
 
 func (q *Queries) ListBookmarksBySource(ctx context.Context, arg ListBookmarksBySourceParams) ([]Bookmark, error) {
-	rows, err := q.db.QueryContext(ctx, listBookmarksBySource, arg.SourceType, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listBookmarksBySource,
+		arg.OwnerID,
+		arg.SourceType,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -506,6 +921,7 @@ func (q *Queries) ListBookmarksBySource(ctx context.Context, arg ListBookmarksBy
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Keywords,
+			&i.OwnerID,
 		); err != nil {
 			return nil, err
 		}
@@ -521,11 +937,11 @@ func (q *Queries) ListBookmarksBySource(ctx context.Context, arg ListBookmarksBy
 }
 
 const listCollections = `-- name: ListCollections :many
-SELECT id, name, description, icon, created_at FROM collections ORDER BY name
+SELECT id, name, description, icon, created_at, owner_id FROM collections WHERE owner_id = ? ORDER BY name
 `
 
-func (q *Queries) ListCollections(ctx context.Context) ([]Collection, error) {
-	rows, err := q.db.QueryContext(ctx, listCollections)
+func (q *Queries) ListCollections(ctx context.Context, ownerID string) ([]Collection, error) {
+	rows, err := q.db.QueryContext(ctx, listCollections, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -539,6 +955,7 @@ func (q *Queries) ListCollections(ctx context.Context) ([]Collection, error) {
 			&i.Description,
 			&i.Icon,
 			&i.CreatedAt,
+			&i.OwnerID,
 		); err != nil {
 			return nil, err
 		}
@@ -554,11 +971,11 @@ func (q *Queries) ListCollections(ctx context.Context) ([]Collection, error) {
 }
 
 const listTags = `-- name: ListTags :many
-SELECT id, name, color FROM tags ORDER BY name
+SELECT id, owner_id, name, color FROM tags WHERE owner_id = ? ORDER BY name
 `
 
-func (q *Queries) ListTags(ctx context.Context) ([]Tag, error) {
-	rows, err := q.db.QueryContext(ctx, listTags)
+func (q *Queries) ListTags(ctx context.Context, ownerID string) ([]Tag, error) {
+	rows, err := q.db.QueryContext(ctx, listTags, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -566,7 +983,12 @@ func (q *Queries) ListTags(ctx context.Context) ([]Tag, error) {
 	items := []Tag{}
 	for rows.Next() {
 		var i Tag
-		if err := rows.Scan(&i.ID, &i.Name, &i.Color); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Color,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -581,41 +1003,48 @@ func (q *Queries) ListTags(ctx context.Context) ([]Tag, error) {
 }
 
 const removeBookmarkFromCollection = `-- name: RemoveBookmarkFromCollection :exec
-DELETE FROM bookmark_collections WHERE bookmark_id = ? AND collection_id = ?
+DELETE FROM bookmark_collections
+WHERE bookmark_id = ?1 AND collection_id = ?2
+  AND collection_id IN (SELECT id FROM collections WHERE owner_id = ?3)
 `
 
 type RemoveBookmarkFromCollectionParams struct {
-	BookmarkID   int64 `json:"bookmark_id"`
-	CollectionID int64 `json:"collection_id"`
+	BookmarkID   int64  `json:"bookmark_id"`
+	CollectionID int64  `json:"collection_id"`
+	OwnerID      string `json:"owner_id"`
 }
 
 func (q *Queries) RemoveBookmarkFromCollection(ctx context.Context, arg RemoveBookmarkFromCollectionParams) error {
-	_, err := q.db.ExecContext(ctx, removeBookmarkFromCollection, arg.BookmarkID, arg.CollectionID)
+	_, err := q.db.ExecContext(ctx, removeBookmarkFromCollection, arg.BookmarkID, arg.CollectionID, arg.OwnerID)
 	return err
 }
 
 const removeTagFromBookmark = `-- name: RemoveTagFromBookmark :exec
-DELETE FROM bookmark_tags WHERE bookmark_id = ? AND tag_id = ?
+DELETE FROM bookmark_tags
+WHERE bookmark_id = ?1 AND tag_id = ?2
+  AND bookmark_id IN (SELECT id FROM bookmarks WHERE owner_id = ?3)
 `
 
 type RemoveTagFromBookmarkParams struct {
-	BookmarkID int64 `json:"bookmark_id"`
-	TagID      int64 `json:"tag_id"`
+	BookmarkID int64  `json:"bookmark_id"`
+	TagID      int64  `json:"tag_id"`
+	OwnerID    string `json:"owner_id"`
 }
 
 func (q *Queries) RemoveTagFromBookmark(ctx context.Context, arg RemoveTagFromBookmarkParams) error {
-	_, err := q.db.ExecContext(ctx, removeTagFromBookmark, arg.BookmarkID, arg.TagID)
+	_, err := q.db.ExecContext(ctx, removeTagFromBookmark, arg.BookmarkID, arg.TagID, arg.OwnerID)
 	return err
 }
 
 const searchBookmarksFTS = `-- name: SearchBookmarksFTS :many
-SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords FROM bookmarks 
-WHERE title LIKE ? OR description LIKE ? OR summary LIKE ?
+SELECT id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id FROM bookmarks
+WHERE owner_id = ? AND (title LIKE ? OR description LIKE ? OR summary LIKE ?)
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?
 `
 
 type SearchBookmarksFTSParams struct {
+	OwnerID     string  `json:"owner_id"`
 	Title       string  `json:"title"`
 	Description *string `json:"description"`
 	Summary     *string `json:"summary"`
@@ -626,6 +1055,7 @@ type SearchBookmarksFTSParams struct {
 // Note: FTS search is done via raw SQL in the server code
 func (q *Queries) SearchBookmarksFTS(ctx context.Context, arg SearchBookmarksFTSParams) ([]Bookmark, error) {
 	rows, err := q.db.QueryContext(ctx, searchBookmarksFTS,
+		arg.OwnerID,
 		arg.Title,
 		arg.Description,
 		arg.Summary,
@@ -651,6 +1081,7 @@ func (q *Queries) SearchBookmarksFTS(ctx context.Context, arg SearchBookmarksFTS
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Keywords,
+			&i.OwnerID,
 		); err != nil {
 			return nil, err
 		}
@@ -671,14 +1102,15 @@ UPDATE bookmarks SET
     description = ?,
     summary = ?,
     updated_at = CURRENT_TIMESTAMP
-WHERE id = ?
-RETURNING id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords
+WHERE owner_id = ? AND id = ?
+RETURNING id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id
 `
 
 type UpdateBookmarkParams struct {
 	Title       string  `json:"title"`
 	Description *string `json:"description"`
 	Summary     *string `json:"summary"`
+	OwnerID     string  `json:"owner_id"`
 	ID          int64   `json:"id"`
 }
 
@@ -687,6 +1119,7 @@ func (q *Queries) UpdateBookmark(ctx context.Context, arg UpdateBookmarkParams)
 		arg.Title,
 		arg.Description,
 		arg.Summary,
+		arg.OwnerID,
 		arg.ID,
 	)
 	var i Bookmark
@@ -702,6 +1135,7 @@ func (q *Queries) UpdateBookmark(ctx context.Context, arg UpdateBookmarkParams)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Keywords,
+		&i.OwnerID,
 	)
 	return i, err
 }
@@ -711,18 +1145,82 @@ UPDATE bookmarks SET
     summary = ?,
     keywords = ?,
     updated_at = CURRENT_TIMESTAMP
-WHERE id = ?
-RETURNING id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords
+WHERE owner_id = ? AND id = ?
+RETURNING id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id
 `
 
 type UpdateBookmarkAnalysisParams struct {
 	Summary  *string `json:"summary"`
 	Keywords *string `json:"keywords"`
+	OwnerID  string  `json:"owner_id"`
 	ID       int64   `json:"id"`
 }
 
 func (q *Queries) UpdateBookmarkAnalysis(ctx context.Context, arg UpdateBookmarkAnalysisParams) (Bookmark, error) {
-	row := q.db.QueryRowContext(ctx, updateBookmarkAnalysis, arg.Summary, arg.Keywords, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateBookmarkAnalysis,
+		arg.Summary,
+		arg.Keywords,
+		arg.OwnerID,
+		arg.ID,
+	)
+	var i Bookmark
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Title,
+		&i.Description,
+		&i.Summary,
+		&i.SourceType,
+		&i.FaviconUrl,
+		&i.ImageUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Keywords,
+		&i.OwnerID,
+	)
+	return i, err
+}
+
+const updateBookmarkFromSync = `-- name: UpdateBookmarkFromSync :one
+UPDATE bookmarks SET
+    title = ?,
+    description = ?,
+    summary = ?,
+    source_type = ?,
+    favicon_url = ?,
+    image_url = ?,
+    keywords = ?,
+    updated_at = ?
+WHERE owner_id = ? AND id = ?
+RETURNING id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at, keywords, owner_id
+`
+
+type UpdateBookmarkFromSyncParams struct {
+	Title       string    `json:"title"`
+	Description *string   `json:"description"`
+	Summary     *string   `json:"summary"`
+	SourceType  string    `json:"source_type"`
+	FaviconUrl  *string   `json:"favicon_url"`
+	ImageUrl    *string   `json:"image_url"`
+	Keywords    *string   `json:"keywords"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	OwnerID     string    `json:"owner_id"`
+	ID          int64     `json:"id"`
+}
+
+func (q *Queries) UpdateBookmarkFromSync(ctx context.Context, arg UpdateBookmarkFromSyncParams) (Bookmark, error) {
+	row := q.db.QueryRowContext(ctx, updateBookmarkFromSync,
+		arg.Title,
+		arg.Description,
+		arg.Summary,
+		arg.SourceType,
+		arg.FaviconUrl,
+		arg.ImageUrl,
+		arg.Keywords,
+		arg.UpdatedAt,
+		arg.OwnerID,
+		arg.ID,
+	)
 	var i Bookmark
 	err := row.Scan(
 		&i.ID,
@@ -736,19 +1234,21 @@ func (q *Queries) UpdateBookmarkAnalysis(ctx context.Context, arg UpdateBookmark
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Keywords,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
 const updateCollection = `-- name: UpdateCollection :one
-UPDATE collections SET name = ?, description = ?, icon = ? WHERE id = ?
-RETURNING id, name, description, icon, created_at
+UPDATE collections SET name = ?, description = ?, icon = ? WHERE owner_id = ? AND id = ?
+RETURNING id, name, description, icon, created_at, owner_id
 `
 
 type UpdateCollectionParams struct {
 	Name        string  `json:"name"`
 	Description *string `json:"description"`
 	Icon        *string `json:"icon"`
+	OwnerID     string  `json:"owner_id"`
 	ID          int64   `json:"id"`
 }
 
@@ -757,6 +1257,7 @@ func (q *Queries) UpdateCollection(ctx context.Context, arg UpdateCollectionPara
 		arg.Name,
 		arg.Description,
 		arg.Icon,
+		arg.OwnerID,
 		arg.ID,
 	)
 	var i Collection
@@ -766,6 +1267,7 @@ func (q *Queries) UpdateCollection(ctx context.Context, arg UpdateCollectionPara
 		&i.Description,
 		&i.Icon,
 		&i.CreatedAt,
+		&i.OwnerID,
 	)
 	return i, err
 }
@@ -8,6 +8,17 @@ import (
 	"time"
 )
 
+type ApiToken struct {
+	ID         int64      `json:"id"`
+	OwnerID    string     `json:"owner_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"token_hash"`
+	Prefix     string     `json:"prefix"`
+	Scopes     string     `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
 type Bookmark struct {
 	ID          int64     `json:"id"`
 	Url         string    `json:"url"`
@@ -20,6 +31,7 @@ type Bookmark struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	Keywords    *string   `json:"keywords"`
+	OwnerID     string    `json:"owner_id"`
 }
 
 type BookmarkCollection struct {
@@ -27,6 +39,13 @@ type BookmarkCollection struct {
 	CollectionID int64 `json:"collection_id"`
 }
 
+type BookmarkMetadata struct {
+	BookmarkID     int64     `json:"bookmark_id"`
+	StructuredData *string   `json:"structured_data"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Oembed         *string   `json:"oembed"`
+}
+
 type BookmarkTag struct {
 	BookmarkID int64 `json:"bookmark_id"`
 	TagID      int64 `json:"tag_id"`
@@ -36,6 +55,9 @@ type BookmarksFt struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	Summary     string `json:"summary"`
+	Keywords    string `json:"keywords"`
+	Url         string `json:"url"`
+	Tags        string `json:"tags"`
 }
 
 type Collection struct {
@@ -44,6 +66,42 @@ type Collection struct {
 	Description *string   `json:"description"`
 	Icon        *string   `json:"icon"`
 	CreatedAt   time.Time `json:"created_at"`
+	OwnerID     string    `json:"owner_id"`
+}
+
+type Embedding struct {
+	BookmarkID  int64     `json:"bookmark_id"`
+	Model       string    `json:"model"`
+	Dims        int64     `json:"dims"`
+	Vector      []byte    `json:"vector"`
+	ContentHash string    `json:"content_hash"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type Favicon struct {
+	Domain      string    `json:"domain"`
+	Hash        *string   `json:"hash"`
+	ContentType *string   `json:"content_type"`
+	SourceUrl   *string   `json:"source_url"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+type Job struct {
+	ID          int64      `json:"id"`
+	ParentID    *int64     `json:"parent_id"`
+	Kind        string     `json:"kind"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"`
+	Attempts    int64      `json:"attempts"`
+	MaxAttempts int64      `json:"max_attempts"`
+	Result      *string    `json:"result"`
+	Error       *string    `json:"error"`
+	RunAt       time.Time  `json:"run_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	OwnerID     string     `json:"owner_id"`
 }
 
 type Migration struct {
@@ -52,10 +110,57 @@ type Migration struct {
 	ExecutedAt      time.Time `json:"executed_at"`
 }
 
+type Secret struct {
+	Name       string    `json:"name"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type SyncConflict struct {
+	ID        int64     `json:"id"`
+	OwnerID   string    `json:"owner_id"`
+	Url       string    `json:"url"`
+	Fields    string    `json:"fields"`
+	Base      *string   `json:"base"`
+	Ours      *string   `json:"ours"`
+	Theirs    *string   `json:"theirs"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Tag struct {
-	ID    int64   `json:"id"`
-	Name  string  `json:"name"`
-	Color *string `json:"color"`
+	ID      int64   `json:"id"`
+	OwnerID string  `json:"owner_id"`
+	Name    string  `json:"name"`
+	Color   *string `json:"color"`
+}
+
+type TagSuggestion struct {
+	ID         int64     `json:"id"`
+	BookmarkID int64     `json:"bookmark_id"`
+	TagName    string    `json:"tag_name"`
+	Source     string    `json:"source"`
+	Confidence float64   `json:"confidence"`
+	Existing   bool      `json:"existing"`
+	Applied    bool      `json:"applied"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Thumbnail struct {
+	Hash        string    `json:"hash"`
+	Width       int64     `json:"width"`
+	Height      int64     `json:"height"`
+	Blob        string    `json:"blob"`
+	ContentType string    `json:"content_type"`
+	SourceUrl   *string   `json:"source_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type User struct {
+	ID         string    `json:"id"`
+	Email      string    `json:"email"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
 }
 
 type Visitor struct {
@@ -0,0 +1,74 @@
+-- Extend full-text search to keywords, URL and tag names.
+--
+-- Tag names live outside the bookmarks table, so the index can no longer be
+-- an external-content table over bookmarks. It stores its own copy of the
+-- indexed text, keyed by bookmark id, and triggers on bookmarks,
+-- bookmark_tags and tags keep it in sync.
+DROP TRIGGER IF EXISTS bookmarks_ai;
+DROP TRIGGER IF EXISTS bookmarks_ad;
+DROP TRIGGER IF EXISTS bookmarks_au;
+DROP TABLE IF EXISTS bookmarks_fts;
+
+CREATE VIRTUAL TABLE bookmarks_fts USING fts5(
+    title,
+    description,
+    summary,
+    keywords,
+    url,
+    tags
+);
+
+CREATE TRIGGER bookmarks_ai AFTER INSERT ON bookmarks BEGIN
+    INSERT INTO bookmarks_fts(rowid, title, description, summary, keywords, url, tags)
+    VALUES (
+        new.id, new.title, new.description, new.summary, new.keywords, new.url,
+        (SELECT group_concat(t.name, ' ') FROM tags t
+         JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = new.id)
+    );
+END;
+
+CREATE TRIGGER bookmarks_ad AFTER DELETE ON bookmarks BEGIN
+    DELETE FROM bookmarks_fts WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER bookmarks_au AFTER UPDATE OF title, description, summary, keywords, url ON bookmarks BEGIN
+    UPDATE bookmarks_fts SET
+        title = new.title,
+        description = new.description,
+        summary = new.summary,
+        keywords = new.keywords,
+        url = new.url
+    WHERE rowid = new.id;
+END;
+
+CREATE TRIGGER bookmark_tags_ai AFTER INSERT ON bookmark_tags BEGIN
+    UPDATE bookmarks_fts SET tags = (
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = new.bookmark_id
+    ) WHERE rowid = new.bookmark_id;
+END;
+
+CREATE TRIGGER bookmark_tags_ad AFTER DELETE ON bookmark_tags BEGIN
+    UPDATE bookmarks_fts SET tags = (
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = old.bookmark_id
+    ) WHERE rowid = old.bookmark_id;
+END;
+
+CREATE TRIGGER tags_au AFTER UPDATE OF name ON tags BEGIN
+    UPDATE bookmarks_fts SET tags = (
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = bookmarks_fts.rowid
+    ) WHERE rowid IN (SELECT bookmark_id FROM bookmark_tags WHERE tag_id = new.id);
+END;
+
+-- Index existing bookmarks
+INSERT INTO bookmarks_fts(rowid, title, description, summary, keywords, url, tags)
+SELECT b.id, b.title, b.description, b.summary, b.keywords, b.url,
+    (SELECT group_concat(t.name, ' ') FROM tags t
+     JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = b.id)
+FROM bookmarks b;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (004, '004-fts-extended');
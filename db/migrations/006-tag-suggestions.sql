@@ -0,0 +1,21 @@
+-- Auto-tag suggestions
+--
+-- Every suggestion is kept, applied or not, so the LLM path and the
+-- keyword fallback can be audited side by side. source is 'llm' or
+-- 'keywords'.
+CREATE TABLE IF NOT EXISTS tag_suggestions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    bookmark_id INTEGER NOT NULL REFERENCES bookmarks(id) ON DELETE CASCADE,
+    tag_name TEXT NOT NULL,
+    source TEXT NOT NULL,
+    confidence REAL NOT NULL,
+    existing BOOLEAN NOT NULL DEFAULT FALSE,
+    applied BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS tag_suggestions_bookmark_id ON tag_suggestions(bookmark_id);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (006, '006-tag-suggestions');
@@ -0,0 +1,17 @@
+-- Bookmark embeddings for semantic search
+--
+-- vector holds little-endian float32 values. Vectors from different models
+-- are not comparable, so each model keeps its own row.
+CREATE TABLE IF NOT EXISTS embeddings (
+    bookmark_id INTEGER NOT NULL REFERENCES bookmarks(id) ON DELETE CASCADE,
+    model TEXT NOT NULL,
+    dims INTEGER NOT NULL,
+    vector BLOB NOT NULL,
+    content_hash TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (bookmark_id, model)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (007, '007-embeddings');
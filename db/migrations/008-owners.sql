@@ -0,0 +1,82 @@
+-- Per-user data isolation
+--
+-- owner_id holds the exe.dev user ID (X-ExeDev-UserID). Rows that predate
+-- this migration get an empty owner and are assigned to the configured
+-- default user at startup (see db.AssignUnowned).
+--
+-- tags.name was globally unique, so tags is rebuilt with a per-owner
+-- unique constraint. Foreign keys are switched off while the old table is
+-- dropped so that bookmark_tags rows are not cascaded away.
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    email TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE bookmarks ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE collections ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE jobs ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS bookmarks_owner_created ON bookmarks(owner_id, created_at);
+CREATE INDEX IF NOT EXISTS collections_owner ON collections(owner_id);
+CREATE INDEX IF NOT EXISTS jobs_owner ON jobs(owner_id);
+
+-- Triggers that mention tags would block the rename below.
+DROP TRIGGER IF EXISTS bookmarks_ai;
+DROP TRIGGER IF EXISTS bookmark_tags_ai;
+DROP TRIGGER IF EXISTS bookmark_tags_ad;
+DROP TRIGGER IF EXISTS tags_au;
+
+CREATE TABLE tags_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    color TEXT DEFAULT '#6366f1',
+    UNIQUE (owner_id, name)
+);
+INSERT INTO tags_new (id, owner_id, name, color) SELECT id, '', name, color FROM tags;
+DROP TABLE tags;
+ALTER TABLE tags_new RENAME TO tags;
+
+CREATE TRIGGER bookmarks_ai AFTER INSERT ON bookmarks BEGIN
+    INSERT INTO bookmarks_fts(rowid, title, description, summary, keywords, url, tags)
+    VALUES (
+        new.id, new.title, new.description, new.summary, new.keywords, new.url,
+        (SELECT group_concat(t.name, ' ') FROM tags t
+         JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = new.id)
+    );
+END;
+
+CREATE TRIGGER bookmark_tags_ai AFTER INSERT ON bookmark_tags BEGIN
+    UPDATE bookmarks_fts SET tags = (
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = new.bookmark_id
+    ) WHERE rowid = new.bookmark_id;
+END;
+
+CREATE TRIGGER bookmark_tags_ad AFTER DELETE ON bookmark_tags BEGIN
+    UPDATE bookmarks_fts SET tags = (
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = old.bookmark_id
+    ) WHERE rowid = old.bookmark_id;
+END;
+
+CREATE TRIGGER tags_au AFTER UPDATE OF name ON tags BEGIN
+    UPDATE bookmarks_fts SET tags = (
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN bookmark_tags bt ON bt.tag_id = t.id WHERE bt.bookmark_id = bookmarks_fts.rowid
+    ) WHERE rowid IN (SELECT bookmark_id FROM bookmark_tags WHERE tag_id = new.id);
+END;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (008, '008-owners');
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
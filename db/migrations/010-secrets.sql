@@ -0,0 +1,16 @@
+-- Encrypted credentials
+--
+-- Each secret is sealed with AES-256-GCM under the key derived from
+-- SECRETS_KEY, using its name as additional data so a ciphertext cannot be
+-- moved to another name. Secrets are stored one per row so updating one
+-- leaves the others untouched.
+CREATE TABLE IF NOT EXISTS secrets (
+    name TEXT PRIMARY KEY,
+    nonce BLOB NOT NULL,
+    ciphertext BLOB NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (010, '010-secrets');
@@ -0,0 +1,20 @@
+-- Bookmarks that both sides of a git sync edited in incompatible ways
+--
+-- base, ours and theirs hold the bookmark's sync file at the merge base,
+-- locally and on the remote; NULL means it was absent (deleted). The local
+-- version is kept until the conflict is resolved.
+CREATE TABLE IF NOT EXISTS sync_conflicts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id TEXT NOT NULL,
+    url TEXT NOT NULL,
+    fields TEXT NOT NULL,
+    base TEXT,
+    ours TEXT,
+    theirs TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_id, url)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (011, '011-sync-conflicts');
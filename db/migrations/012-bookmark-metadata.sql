@@ -0,0 +1,13 @@
+-- Page metadata kept alongside bookmarks
+--
+-- structured_data holds the schema.org item (JSON-LD or microdata) that
+-- describes the page, as srv.StructuredData JSON.
+CREATE TABLE IF NOT EXISTS bookmark_metadata (
+    bookmark_id INTEGER PRIMARY KEY REFERENCES bookmarks(id) ON DELETE CASCADE,
+    structured_data TEXT,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (012, '012-bookmark-metadata');
@@ -0,0 +1,8 @@
+-- oEmbed responses for bookmarks on video, photo and social sites
+--
+-- oembed holds srv.OEmbed JSON: title, author, thumbnail and embed HTML.
+ALTER TABLE bookmark_metadata ADD COLUMN oembed TEXT;
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (013, '013-oembed');
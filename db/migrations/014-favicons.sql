@@ -0,0 +1,16 @@
+-- Favicons cached per domain
+--
+-- The image is stored once in the server's blob directory under its
+-- SHA-256 hash. A row with no hash records that discovery found nothing,
+-- so the domain isn't fetched again until the entry is stale.
+CREATE TABLE IF NOT EXISTS favicons (
+    domain TEXT PRIMARY KEY,
+    hash TEXT,
+    content_type TEXT,
+    source_url TEXT,
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (014, '014-favicons');
@@ -0,0 +1,19 @@
+-- Preview images resized to standard widths
+--
+-- Each row is one width of an original image, identified by the SHA-256
+-- hash of the downloaded file. blob is the hash of the resized image in
+-- the server's blob directory.
+CREATE TABLE IF NOT EXISTS thumbnails (
+    hash TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    blob TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    source_url TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (hash, width)
+);
+
+-- Record execution of this migration
+INSERT OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES (015, '015-thumbnails');
@@ -0,0 +1,18 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (owner_id, name, token_hash, prefix, scopes)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListAPITokens :many
+SELECT * FROM api_tokens WHERE owner_id = ? ORDER BY created_at DESC, id DESC;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens WHERE token_hash = ?;
+
+-- name: TouchAPIToken :exec
+-- Recorded at most once a minute to keep token checks cheap.
+UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP
+WHERE id = ? AND (last_used_at IS NULL OR last_used_at < datetime('now', '-1 minute'));
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens WHERE owner_id = ? AND id = ?;
@@ -0,0 +1,12 @@
+-- name: UpsertBookmarkMetadata :exec
+INSERT INTO bookmark_metadata (bookmark_id, structured_data, oembed)
+VALUES (?, ?, ?)
+ON CONFLICT(bookmark_id) DO UPDATE SET
+    structured_data = excluded.structured_data,
+    oembed = excluded.oembed,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetBookmarkMetadata :one
+SELECT m.* FROM bookmark_metadata m
+JOIN bookmarks b ON b.id = m.bookmark_id
+WHERE b.owner_id = ? AND m.bookmark_id = ?;
@@ -1,19 +1,27 @@
 -- name: CreateBookmark :one
-INSERT INTO bookmarks (url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+INSERT INTO bookmarks (owner_id, url, title, description, summary, source_type, favicon_url, image_url, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+RETURNING *;
+
+-- name: ImportBookmark :one
+INSERT INTO bookmarks (owner_id, url, title, description, summary, source_type, favicon_url, image_url, keywords, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetBookmark :one
-SELECT * FROM bookmarks WHERE id = ?;
+SELECT * FROM bookmarks WHERE owner_id = ? AND id = ?;
 
 -- name: GetBookmarkByURL :one
-SELECT * FROM bookmarks WHERE url = ?;
+SELECT * FROM bookmarks WHERE owner_id = ? AND url = ?;
 
 -- name: ListBookmarks :many
-SELECT * FROM bookmarks ORDER BY created_at DESC LIMIT ? OFFSET ?;
+SELECT * FROM bookmarks WHERE owner_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?;
+
+-- name: ListAllBookmarks :many
+SELECT * FROM bookmarks WHERE owner_id = ? ORDER BY created_at, id;
 
 -- name: ListBookmarksBySource :many
-SELECT * FROM bookmarks WHERE source_type = ? ORDER BY created_at DESC LIMIT ? OFFSET ?;
+SELECT * FROM bookmarks WHERE owner_id = ? AND source_type = ? ORDER BY created_at DESC LIMIT ? OFFSET ?;
 
 -- name: UpdateBookmark :one
 UPDATE bookmarks SET
@@ -21,7 +29,7 @@ UPDATE bookmarks SET
     description = ?,
     summary = ?,
     updated_at = CURRENT_TIMESTAMP
-WHERE id = ?
+WHERE owner_id = ? AND id = ?
 RETURNING *;
 
 -- name: UpdateBookmarkAnalysis :one
@@ -29,96 +37,165 @@ UPDATE bookmarks SET
     summary = ?,
     keywords = ?,
     updated_at = CURRENT_TIMESTAMP
-WHERE id = ?
+WHERE owner_id = ? AND id = ?
+RETURNING *;
+
+-- name: UpdateBookmarkFromSync :one
+UPDATE bookmarks SET
+    title = ?,
+    description = ?,
+    summary = ?,
+    source_type = ?,
+    favicon_url = ?,
+    image_url = ?,
+    keywords = ?,
+    updated_at = ?
+WHERE owner_id = ? AND id = ?
 RETURNING *;
 
 -- name: DeleteBookmark :exec
-DELETE FROM bookmarks WHERE id = ?;
+DELETE FROM bookmarks WHERE owner_id = ? AND id = ?;
 
 -- name: SearchBookmarksFTS :many
 -- Note: FTS search is done via raw SQL in the server code
-SELECT * FROM bookmarks 
-WHERE title LIKE ? OR description LIKE ? OR summary LIKE ?
+SELECT * FROM bookmarks
+WHERE owner_id = ? AND (title LIKE ? OR description LIKE ? OR summary LIKE ?)
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?;
 
 -- name: CountBookmarks :one
-SELECT COUNT(*) FROM bookmarks;
+SELECT COUNT(*) FROM bookmarks WHERE owner_id = ?;
 
 -- name: CountBookmarksBySource :one
-SELECT COUNT(*) FROM bookmarks WHERE source_type = ?;
+SELECT COUNT(*) FROM bookmarks WHERE owner_id = ? AND source_type = ?;
 
 -- Tags
 -- name: CreateTag :one
-INSERT INTO tags (name, color) VALUES (?, ?)
-ON CONFLICT(name) DO UPDATE SET name = excluded.name
+INSERT INTO tags (owner_id, name, color) VALUES (?, ?, ?)
+ON CONFLICT(owner_id, name) DO UPDATE SET name = excluded.name
 RETURNING *;
 
 -- name: GetTag :one
-SELECT * FROM tags WHERE id = ?;
+SELECT * FROM tags WHERE owner_id = ? AND id = ?;
 
 -- name: GetTagByName :one
-SELECT * FROM tags WHERE name = ?;
+SELECT * FROM tags WHERE owner_id = ? AND name = ?;
 
 -- name: ListTags :many
-SELECT * FROM tags ORDER BY name;
+SELECT * FROM tags WHERE owner_id = ? ORDER BY name;
 
 -- name: DeleteTag :exec
-DELETE FROM tags WHERE id = ?;
+DELETE FROM tags WHERE owner_id = ? AND id = ?;
 
 -- name: AddTagToBookmark :exec
-INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id) VALUES (?, ?);
+-- Both rows must belong to the owner; otherwise nothing is inserted.
+INSERT OR IGNORE INTO bookmark_tags (bookmark_id, tag_id)
+SELECT b.id, t.id FROM bookmarks b
+JOIN tags t ON t.owner_id = b.owner_id
+WHERE b.owner_id = sqlc.arg(owner_id) AND b.id = sqlc.arg(bookmark_id) AND t.id = sqlc.arg(tag_id);
 
 -- name: RemoveTagFromBookmark :exec
-DELETE FROM bookmark_tags WHERE bookmark_id = ? AND tag_id = ?;
+DELETE FROM bookmark_tags
+WHERE bookmark_id = sqlc.arg(bookmark_id) AND tag_id = sqlc.arg(tag_id)
+  AND bookmark_id IN (SELECT id FROM bookmarks WHERE owner_id = sqlc.arg(owner_id));
 
 -- name: GetBookmarkTags :many
 SELECT t.* FROM tags t
 JOIN bookmark_tags bt ON t.id = bt.tag_id
-WHERE bt.bookmark_id = ?;
+WHERE t.owner_id = ? AND bt.bookmark_id = ?;
+
+-- name: ListAllBookmarkTagNames :many
+SELECT bt.bookmark_id, t.name FROM bookmark_tags bt
+JOIN tags t ON t.id = bt.tag_id
+WHERE t.owner_id = ?
+ORDER BY bt.bookmark_id, t.name;
 
 -- name: GetBookmarksByTag :many
 SELECT b.* FROM bookmarks b
 JOIN bookmark_tags bt ON b.id = bt.bookmark_id
-WHERE bt.tag_id = ?
+WHERE b.owner_id = ? AND bt.tag_id = ?
 ORDER BY b.created_at DESC
 LIMIT ? OFFSET ?;
 
 -- Collections
 -- name: CreateCollection :one
-INSERT INTO collections (name, description, icon) VALUES (?, ?, ?)
+INSERT INTO collections (owner_id, name, description, icon) VALUES (?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetCollection :one
-SELECT * FROM collections WHERE id = ?;
+SELECT * FROM collections WHERE owner_id = ? AND id = ?;
+
+-- name: GetCollectionByName :one
+SELECT * FROM collections WHERE owner_id = ? AND name = ? ORDER BY id LIMIT 1;
 
 -- name: ListCollections :many
-SELECT * FROM collections ORDER BY name;
+SELECT * FROM collections WHERE owner_id = ? ORDER BY name;
 
 -- name: UpdateCollection :one
-UPDATE collections SET name = ?, description = ?, icon = ? WHERE id = ?
+UPDATE collections SET name = ?, description = ?, icon = ? WHERE owner_id = ? AND id = ?
 RETURNING *;
 
 -- name: DeleteCollection :exec
-DELETE FROM collections WHERE id = ?;
+DELETE FROM collections WHERE owner_id = ? AND id = ?;
 
 -- name: AddBookmarkToCollection :exec
-INSERT OR IGNORE INTO bookmark_collections (bookmark_id, collection_id) VALUES (?, ?);
+-- Both rows must belong to the owner; otherwise nothing is inserted.
+INSERT OR IGNORE INTO bookmark_collections (bookmark_id, collection_id)
+SELECT b.id, c.id FROM bookmarks b
+JOIN collections c ON c.owner_id = b.owner_id
+WHERE b.owner_id = sqlc.arg(owner_id) AND b.id = sqlc.arg(bookmark_id) AND c.id = sqlc.arg(collection_id);
 
 -- name: RemoveBookmarkFromCollection :exec
-DELETE FROM bookmark_collections WHERE bookmark_id = ? AND collection_id = ?;
+DELETE FROM bookmark_collections
+WHERE bookmark_id = sqlc.arg(bookmark_id) AND collection_id = sqlc.arg(collection_id)
+  AND collection_id IN (SELECT id FROM collections WHERE owner_id = sqlc.arg(owner_id));
 
 -- name: GetBookmarkCollections :many
 SELECT c.* FROM collections c
 JOIN bookmark_collections bc ON c.id = bc.collection_id
-WHERE bc.bookmark_id = ?;
+WHERE c.owner_id = ? AND bc.bookmark_id = ?;
+
+-- name: ListAllBookmarkCollections :many
+SELECT bc.* FROM bookmark_collections bc
+JOIN collections c ON c.id = bc.collection_id
+WHERE c.owner_id = ?
+ORDER BY bc.collection_id, bc.bookmark_id;
 
 -- name: GetBookmarksInCollection :many
 SELECT b.* FROM bookmarks b
 JOIN bookmark_collections bc ON b.id = bc.bookmark_id
-WHERE bc.collection_id = ?
+WHERE b.owner_id = ? AND bc.collection_id = ?
 ORDER BY b.created_at DESC
 LIMIT ? OFFSET ?;
 
 -- name: CountBookmarksInCollection :one
-SELECT COUNT(*) FROM bookmark_collections WHERE collection_id = ?;
+SELECT COUNT(*) FROM bookmark_collections bc
+JOIN collections c ON c.id = bc.collection_id
+WHERE c.owner_id = ? AND bc.collection_id = ?;
+
+-- name: ListAllBookmarkTags :many
+SELECT bt.* FROM bookmark_tags bt
+JOIN tags t ON t.id = bt.tag_id
+WHERE t.owner_id = ?
+ORDER BY bt.bookmark_id, bt.tag_id;
+
+-- name: ListBookmarkIDsMissingMetadata :many
+SELECT id FROM bookmarks
+WHERE owner_id = ? AND (image_url IS NULL OR image_url NOT LIKE '/thumbnails/%' OR summary IS NULL OR summary = ''
+    OR favicon_url IS NULL OR favicon_url NOT LIKE '/favicons/%')
+ORDER BY created_at DESC;
+
+-- name: DeleteOwnerBookmarks :exec
+DELETE FROM bookmarks WHERE owner_id = ?;
+
+-- name: DeleteOwnerTags :exec
+DELETE FROM tags WHERE owner_id = ?;
+
+-- name: DeleteOwnerCollections :exec
+DELETE FROM collections WHERE owner_id = ?;
+
+-- name: DeleteOwnerBookmarkTags :exec
+DELETE FROM bookmark_tags WHERE bookmark_id IN (SELECT id FROM bookmarks WHERE owner_id = ?);
+
+-- name: DeleteOwnerBookmarkCollections :exec
+DELETE FROM bookmark_collections WHERE bookmark_id IN (SELECT id FROM bookmarks WHERE owner_id = ?);
@@ -0,0 +1,22 @@
+-- name: UpsertEmbedding :exec
+INSERT INTO embeddings (bookmark_id, model, dims, vector, content_hash)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(bookmark_id, model) DO UPDATE SET
+    dims = excluded.dims,
+    vector = excluded.vector,
+    content_hash = excluded.content_hash,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetEmbedding :one
+SELECT * FROM embeddings WHERE bookmark_id = ? AND model = ?;
+
+-- name: ListEmbeddings :many
+SELECT e.bookmark_id, e.vector FROM embeddings e
+JOIN bookmarks b ON b.id = e.bookmark_id
+WHERE b.owner_id = ? AND e.model = ?;
+
+-- name: ListBookmarkIDsMissingEmbedding :many
+SELECT b.id FROM bookmarks b
+LEFT JOIN embeddings e ON e.bookmark_id = b.id AND e.model = ?
+WHERE b.owner_id = ? AND e.bookmark_id IS NULL
+ORDER BY b.id;
@@ -0,0 +1,23 @@
+-- name: GetFavicon :one
+SELECT * FROM favicons WHERE domain = ?;
+
+-- name: UpsertFavicon :exec
+INSERT INTO favicons (domain, hash, content_type, source_url)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(domain) DO UPDATE SET
+    hash = excluded.hash,
+    content_type = excluded.content_type,
+    source_url = excluded.source_url,
+    fetched_at = CURRENT_TIMESTAMP;
+
+-- name: ListBookmarkURLsByHost :many
+-- The host must end where the URL's authority does, so example.com doesn't
+-- match example.com.au. LIKE keeps the match case-insensitive.
+SELECT url FROM bookmarks
+WHERE owner_id = sqlc.arg(owner_id) AND (
+    url LIKE 'http://' || sqlc.arg(host) OR url LIKE 'https://' || sqlc.arg(host)
+    OR url LIKE 'http://' || sqlc.arg(host) || '/%' OR url LIKE 'https://' || sqlc.arg(host) || '/%'
+    OR url LIKE 'http://' || sqlc.arg(host) || '?%' OR url LIKE 'https://' || sqlc.arg(host) || '?%'
+    OR url LIKE 'http://' || sqlc.arg(host) || '#%' OR url LIKE 'https://' || sqlc.arg(host) || '#%'
+)
+ORDER BY created_at DESC;
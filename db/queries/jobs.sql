@@ -0,0 +1,81 @@
+-- name: CreateJob :one
+INSERT INTO jobs (owner_id, parent_id, kind, payload, max_attempts)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetJob :one
+SELECT * FROM jobs WHERE owner_id = ? AND id = ?;
+
+-- name: ListJobs :many
+SELECT * FROM jobs WHERE owner_id = ? AND parent_id IS NULL ORDER BY id DESC LIMIT ?;
+
+-- name: ClaimJob :one
+UPDATE jobs SET
+    status = 'running',
+    attempts = attempts + 1,
+    started_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = (
+    SELECT id FROM jobs
+    WHERE status = 'queued' AND run_at <= strftime('%Y-%m-%d %H:%M:%f', 'now')
+    ORDER BY run_at, id
+    LIMIT 1
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs SET
+    status = 'succeeded',
+    result = ?,
+    error = NULL,
+    updated_at = CURRENT_TIMESTAMP,
+    finished_at = CURRENT_TIMESTAMP
+WHERE id = ? AND status = 'running';
+
+-- name: RetryJob :exec
+UPDATE jobs SET
+    status = 'queued',
+    error = ?,
+    run_at = strftime('%Y-%m-%d %H:%M:%f', 'now', CAST(sqlc.arg(delay) AS TEXT)),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND status = 'running';
+
+-- name: FailJob :exec
+UPDATE jobs SET
+    status = 'failed',
+    error = ?,
+    updated_at = CURRENT_TIMESTAMP,
+    finished_at = CURRENT_TIMESTAMP
+WHERE id = ? AND status = 'running';
+
+-- name: RequeueJob :exec
+-- Returns an interrupted job to the queue without counting the attempt.
+UPDATE jobs SET
+    status = 'queued',
+    attempts = attempts - 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND status = 'running';
+
+-- name: RequeueRunningJobs :execrows
+UPDATE jobs SET
+    status = 'queued',
+    attempts = attempts - 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE status = 'running';
+
+-- name: CancelJob :execrows
+UPDATE jobs SET
+    status = 'cancelled',
+    updated_at = CURRENT_TIMESTAMP,
+    finished_at = CURRENT_TIMESTAMP
+WHERE owner_id = sqlc.arg(owner_id) AND (id = sqlc.arg(id) OR parent_id = sqlc.arg(id))
+  AND status IN ('queued', 'running');
+
+-- name: ListRunningChildJobIDs :many
+SELECT id FROM jobs WHERE owner_id = ? AND parent_id = ? AND status = 'running';
+
+-- name: CountChildJobsByStatus :many
+SELECT status, COUNT(*) AS count FROM jobs WHERE parent_id = ? GROUP BY status;
+
+-- name: CountQueuedJobs :one
+SELECT COUNT(*) FROM jobs WHERE status IN ('queued', 'running');
@@ -0,0 +1,8 @@
+-- name: CountJobsByStatus :many
+SELECT status, COUNT(*) AS count FROM jobs GROUP BY status;
+
+-- name: CountLibrary :one
+SELECT
+  (SELECT COUNT(*) FROM bookmarks) AS bookmarks,
+  (SELECT COUNT(*) FROM tags) AS tags,
+  (SELECT COUNT(*) FROM collections) AS collections;
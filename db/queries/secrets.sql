@@ -0,0 +1,15 @@
+-- name: UpsertSecret :exec
+INSERT INTO secrets (name, nonce, ciphertext) VALUES (?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+    nonce = excluded.nonce,
+    ciphertext = excluded.ciphertext,
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetSecret :one
+SELECT * FROM secrets WHERE name = ?;
+
+-- name: ListSecrets :many
+SELECT * FROM secrets ORDER BY name;
+
+-- name: DeleteSecret :execrows
+DELETE FROM secrets WHERE name = ?;
@@ -0,0 +1,18 @@
+-- name: UpsertSyncConflict :exec
+INSERT INTO sync_conflicts (owner_id, url, fields, base, ours, theirs)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(owner_id, url) DO UPDATE SET
+    fields = excluded.fields,
+    base = excluded.base,
+    ours = excluded.ours,
+    theirs = excluded.theirs,
+    created_at = CURRENT_TIMESTAMP;
+
+-- name: ListSyncConflicts :many
+SELECT * FROM sync_conflicts WHERE owner_id = ? ORDER BY created_at, id;
+
+-- name: GetSyncConflict :one
+SELECT * FROM sync_conflicts WHERE owner_id = ? AND id = ?;
+
+-- name: DeleteSyncConflict :exec
+DELETE FROM sync_conflicts WHERE owner_id = ? AND id = ?;
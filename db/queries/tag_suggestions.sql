@@ -0,0 +1,10 @@
+-- name: CreateTagSuggestion :one
+INSERT INTO tag_suggestions (bookmark_id, tag_name, source, confidence, existing, applied)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListTagSuggestions :many
+SELECT ts.* FROM tag_suggestions ts
+JOIN bookmarks b ON b.id = ts.bookmark_id
+WHERE b.owner_id = ? AND ts.bookmark_id = ?
+ORDER BY ts.created_at DESC, ts.id;
@@ -0,0 +1,6 @@
+-- name: ListThumbnails :many
+SELECT * FROM thumbnails WHERE hash = ? ORDER BY width;
+
+-- name: CreateThumbnail :exec
+INSERT OR IGNORE INTO thumbnails (hash, width, height, blob, content_type, source_url)
+VALUES (?, ?, ?, ?, ?, ?);
@@ -0,0 +1,8 @@
+-- name: UpsertUser :exec
+INSERT INTO users (id, email) VALUES (?, ?)
+ON CONFLICT(id) DO UPDATE SET
+    email = CASE WHEN excluded.email = '' THEN users.email ELSE excluded.email END,
+    last_seen_at = CURRENT_TIMESTAMP;
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = ?;
@@ -1,14 +1,22 @@
 const DEFAULT_SERVER = 'https://bookmark-manager.exe.xyz:8000';
 
 let serverUrl = DEFAULT_SERVER;
+let apiToken = '';
 
-// Load settings
+// Load settings. The token stays in local storage so it is not synced
+// to other browsers.
 chrome.storage.sync.get(['serverUrl'], (result) => {
   if (result.serverUrl) {
     serverUrl = result.serverUrl;
     document.getElementById('server-url').value = serverUrl;
   }
 });
+chrome.storage.local.get(['apiToken'], (result) => {
+  if (result.apiToken) {
+    apiToken = result.apiToken;
+    document.getElementById('api-token').value = apiToken;
+  }
+});
 
 // Get current tab info
 chrome.tabs.query({ active: true, currentWindow: true }, (tabs) => {
@@ -68,10 +76,22 @@ document.getElementById('bookmark-form').addEventListener('submit', async (e) =>
       .filter(Boolean)
   };
 
+  if (!apiToken) {
+    status.textContent = '✗ Add an API token in Settings first';
+    status.className = 'status error';
+    document.getElementById('settings-content').classList.remove('hidden');
+    saveBtn.disabled = false;
+    saveBtn.textContent = 'Save Bookmark';
+    return;
+  }
+
   try {
     const response = await fetch(`${serverUrl}/api/bookmarks`, {
       method: 'POST',
-      headers: { 'Content-Type': 'application/json' },
+      headers: {
+        'Content-Type': 'application/json',
+        'Authorization': `Bearer ${apiToken}`
+      },
       body: JSON.stringify(data)
     });
 
@@ -80,11 +100,15 @@ document.getElementById('bookmark-form').addEventListener('submit', async (e) =>
       status.className = 'status success';
       saveBtn.textContent = 'Saved!';
       setTimeout(() => window.close(), 1500);
+    } else if (response.status === 401 || response.status === 403) {
+      throw new Error('API token was rejected');
     } else {
       throw new Error('Failed to save');
     }
   } catch (err) {
-    status.textContent = '✗ Error: Could not connect to server';
+    status.textContent = err.message === 'API token was rejected'
+      ? '✗ Error: API token was rejected'
+      : '✗ Error: Could not connect to server';
     status.className = 'status error';
     saveBtn.disabled = false;
     saveBtn.textContent = 'Save Bookmark';
@@ -105,13 +129,16 @@ document.getElementById('settings-toggle').addEventListener('click', () => {
 // Save settings
 document.getElementById('save-settings').addEventListener('click', () => {
   const newUrl = document.getElementById('server-url').value.trim();
+  apiToken = document.getElementById('api-token').value.trim();
   if (newUrl) {
     serverUrl = newUrl;
-    chrome.storage.sync.set({ serverUrl: newUrl }, () => {
+  }
+  chrome.storage.sync.set({ serverUrl }, () => {
+    chrome.storage.local.set({ apiToken }, () => {
       const status = document.getElementById('status');
       status.textContent = '✓ Settings saved!';
       status.className = 'status success';
       setTimeout(() => status.className = 'status hidden', 2000);
     });
-  }
+  });
 });
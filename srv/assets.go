@@ -0,0 +1,72 @@
+package srv
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// The templates and static files are built into the binary.
+//
+//go:embed templates static
+var assets embed.FS
+
+// devSourceDir is where Dev mode finds templates and static files when
+// their directories aren't configured: the srv package, relative to the
+// repository root.
+const devSourceDir = "srv"
+
+// assetFS returns the named asset directory: dir if set, the source tree
+// in Dev mode, and otherwise the embedded copy.
+func (s *Server) assetFS(name, dir string) fs.FS {
+	switch {
+	case dir != "":
+		return os.DirFS(dir)
+	case s.Dev:
+		return os.DirFS(filepath.Join(devSourceDir, name))
+	}
+	sub, err := fs.Sub(assets, name)
+	if err != nil {
+		panic(err) // the directory is embedded above
+	}
+	return sub
+}
+
+// parseTemplates parses every template.
+func (s *Server) parseTemplates() (*template.Template, error) {
+	tmpl, err := template.ParseFS(s.assetFS("templates", s.TemplatesDir), "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("parse templates: %w", err)
+	}
+	return tmpl, nil
+}
+
+// loadTemplates parses the templates once, or on every call in Dev mode so
+// that edits show up on reload.
+func (s *Server) loadTemplates() (*template.Template, error) {
+	if s.Dev {
+		return s.parseTemplates()
+	}
+	s.templatesOnce.Do(func() {
+		s.templates, s.templatesErr = s.parseTemplates()
+	})
+	return s.templates, s.templatesErr
+}
+
+func (s *Server) renderTemplate(w http.ResponseWriter, name string, data any) error {
+	tmpl, err := s.loadTemplates()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return tmpl.ExecuteTemplate(w, name, data)
+}
+
+// staticHandler serves the static files under /static/.
+func (s *Server) staticHandler() http.Handler {
+	return http.StripPrefix("/static/", http.FileServer(http.FS(s.assetFS("static", s.StaticDir))))
+}
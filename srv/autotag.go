@@ -0,0 +1,307 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Tag suggestion sources recorded in tag_suggestions.
+const (
+	TagSourceLLM      = "llm"
+	TagSourceKeywords = "keywords"
+)
+
+// AutoTagOptions controls the auto-tag step. When Enabled, analyzing a
+// bookmark also tags it; the /autotag endpoint always runs regardless.
+type AutoTagOptions struct {
+	Enabled     bool
+	SuggestOnly bool    // record suggestions without applying them
+	Threshold   float64 // minimum confidence for a tag to be applied
+	MaxTags     int
+}
+
+var defaultAutoTag = AutoTagOptions{Threshold: 0.7, MaxTags: 5}
+
+// TagSuggestion is one proposed tag. Existing reports whether the name is
+// already in the tag vocabulary.
+type TagSuggestion struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+	Existing   bool    `json:"existing"`
+	Applied    bool    `json:"applied"`
+}
+
+type AutoTagResult struct {
+	BookmarkID  int64           `json:"bookmark_id"`
+	Source      string          `json:"source"` // which path decided the applied tags
+	SuggestOnly bool            `json:"suggest_only"`
+	Threshold   float64         `json:"threshold"`
+	LLM         []TagSuggestion `json:"llm"`
+	LLMError    string          `json:"llm_error,omitempty"`
+	Keywords    []TagSuggestion `json:"keywords"`
+	Applied     []string        `json:"applied"`
+}
+
+func (s *Server) autoTagOptions() AutoTagOptions {
+	opts := s.AutoTag
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultAutoTag.Threshold
+	}
+	if opts.MaxTags <= 0 {
+		opts.MaxTags = defaultAutoTag.MaxTags
+	}
+	return opts
+}
+
+// HandleAutoTag runs auto-tagging for one bookmark. ?suggest=1 records
+// suggestions without applying them and ?threshold= overrides the
+// configured confidence threshold.
+func (s *Server) HandleAutoTag(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	b, err := dbgen.New(s.DB).GetBookmark(r.Context(), dbgen.GetBookmarkParams{OwnerID: ownerID(r.Context()), ID: id})
+	if err != nil {
+		writeError(w, "bookmark not found", 404)
+		return
+	}
+
+	opts := s.autoTagOptions()
+	if v := r.URL.Query().Get("suggest"); v != "" {
+		opts.SuggestOnly, _ = strconv.ParseBool(v)
+	}
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || t > 1 {
+			writeError(w, "threshold must be between 0 and 1", 400)
+			return
+		}
+		opts.Threshold = t
+	}
+
+	analysis, err := s.analyzeURL(r.Context(), b.Url)
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	res, err := s.autoTagBookmark(r.Context(), b, analysis, opts)
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, res)
+}
+
+// HandleListTagSuggestions returns the recorded suggestion history for a
+// bookmark, newest first.
+func (s *Server) HandleListTagSuggestions(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	suggestions, err := dbgen.New(s.DB).ListTagSuggestions(r.Context(), dbgen.ListTagSuggestionsParams{OwnerID: ownerID(r.Context()), BookmarkID: id})
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, suggestions)
+}
+
+// autoTagBookmark asks the LLM for tags and computes the keyword fallback.
+// The LLM suggestions are applied when available, the keyword ones
+// otherwise; both sets are recorded in tag_suggestions.
+func (s *Server) autoTagBookmark(ctx context.Context, b dbgen.Bookmark, analysis *ContentAnalysis, opts AutoTagOptions) (*AutoTagResult, error) {
+	q := dbgen.New(s.DB)
+	tags, err := q.ListTags(ctx, ownerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	vocabulary := make(map[string]string, len(tags))
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		vocabulary[strings.ToLower(t.Name)] = t.Name
+		names = append(names, t.Name)
+	}
+
+	res := &AutoTagResult{
+		BookmarkID:  b.ID,
+		SuggestOnly: opts.SuggestOnly,
+		Threshold:   opts.Threshold,
+		LLM:         []TagSuggestion{},
+		Keywords:    keywordTagSuggestions(analysis.Keywords, vocabulary, opts.MaxTags),
+		Applied:     []string{},
+	}
+
+	title := analysis.title
+	if title == "" {
+		title = b.Title
+	}
+	res.Source = TagSourceKeywords
+	if s.LLM != nil {
+		llmTags, err := suggestTagsWithLLM(ctx, s.llm(), title, analysis.description, analysis.text, names, opts.MaxTags)
+		switch {
+		case errors.Is(err, ErrNoAPIKey):
+			// No key has been stored yet; keywords will do.
+		case err != nil:
+			res.LLMError = err.Error()
+		default:
+			res.Source = TagSourceLLM
+			res.LLM = resolveTagSuggestions(llmTags, vocabulary)
+		}
+	}
+
+	chosen := res.Keywords
+	if res.Source == TagSourceLLM {
+		chosen = res.LLM
+	}
+	if !opts.SuggestOnly {
+		for i := range chosen {
+			if chosen[i].Confidence < opts.Threshold {
+				continue
+			}
+			tag, err := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: chosen[i].Name, Color: strPtr("#6366f1")})
+			if err != nil {
+				return nil, fmt.Errorf("create tag %q: %w", chosen[i].Name, err)
+			}
+			if err := q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: b.ID, TagID: tag.ID}); err != nil {
+				return nil, err
+			}
+			chosen[i].Applied = true
+			res.Applied = append(res.Applied, tag.Name)
+		}
+	}
+
+	record := []struct {
+		source string
+		list   []TagSuggestion
+	}{{TagSourceLLM, res.LLM}, {TagSourceKeywords, res.Keywords}}
+	for _, r := range record {
+		for _, t := range r.list {
+			_, err := q.CreateTagSuggestion(ctx, dbgen.CreateTagSuggestionParams{
+				BookmarkID: b.ID,
+				TagName:    t.Name,
+				Source:     r.source,
+				Confidence: t.Confidence,
+				Existing:   t.Existing,
+				Applied:    t.Applied,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(res.Applied) > 0 {
+		if updated, err := q.GetBookmark(ctx, dbgen.GetBookmarkParams{OwnerID: ownerID(ctx), ID: b.ID}); err == nil {
+			s.Events.Publish(ctx, EventBookmarkUpdated, updated)
+		}
+	}
+	return res, nil
+}
+
+// suggestTagsWithLLM sends the page and the tag vocabulary to the LLM and
+// parses its JSON reply.
+func suggestTagsWithLLM(ctx context.Context, llm LLMProvider, title, description, pageText string, vocabulary []string, maxTags int) ([]TagSuggestion, error) {
+	if len(pageText) > 4000 {
+		pageText = pageText[:4000]
+	}
+	vocab := "(none yet)"
+	if len(vocabulary) > 0 {
+		vocab = strings.Join(vocabulary, ", ")
+	}
+
+	prompt := fmt.Sprintf(`Choose up to %d tags for this bookmarked webpage.
+Prefer tags from the existing vocabulary when they fit; propose a new short lowercase tag only when none fit.
+Give each tag a confidence between 0 and 1.
+
+Existing tags: %s
+
+Title: %s
+Description: %s
+Page content excerpt: %s
+
+Reply with JSON only, in the form {"tags": [{"name": "...", "confidence": 0.9}]}`, maxTags, vocab, title, description, pageText)
+
+	resp, err := llm.Complete(ctx, CompletionRequest{
+		System: "You tag bookmarks. You reply with JSON only.",
+		Prompt: prompt,
+		// Each tag is roughly 20 tokens of JSON; leave room for the wrapper
+		// so the reply isn't cut off mid-object.
+		MaxTokens: 50 + 30*maxTags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags, err := parseTagSuggestions(resp.Text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > maxTags {
+		tags = tags[:maxTags]
+	}
+	return tags, nil
+}
+
+// parseTagSuggestions extracts the {"tags": [...]} object from an LLM
+// reply, tolerating surrounding prose or code fences.
+func parseTagSuggestions(text string) ([]TagSuggestion, error) {
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in LLM reply")
+	}
+	var reply struct {
+		Tags []TagSuggestion `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &reply); err != nil {
+		return nil, fmt.Errorf("parse LLM reply: %w", err)
+	}
+	return reply.Tags, nil
+}
+
+// resolveTagSuggestions normalizes names, maps them onto the existing
+// vocabulary case-insensitively, clamps confidence and drops duplicates.
+func resolveTagSuggestions(tags []TagSuggestion, vocabulary map[string]string) []TagSuggestion {
+	seen := make(map[string]bool)
+	out := []TagSuggestion{}
+	for _, t := range tags {
+		name := strings.ToLower(strings.Join(strings.Fields(t.Name), " "))
+		if name == "" || len(name) > 40 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		t.Name, t.Existing = name, false
+		if existing, ok := vocabulary[name]; ok {
+			t.Name, t.Existing = existing, true
+		}
+		t.Confidence = min(max(t.Confidence, 0), 1)
+		t.Applied = false
+		out = append(out, t)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Confidence > out[j].Confidence })
+	return out
+}
+
+// keywordTagSuggestions is the fallback used without an LLM. Keywords that
+// match an existing tag are confident; the rest are proposed as new tags
+// with confidence decreasing by keyword rank, below the default threshold.
+func keywordTagSuggestions(keywords []string, vocabulary map[string]string, maxTags int) []TagSuggestion {
+	var matched, proposed []TagSuggestion
+	for i, kw := range keywords {
+		if existing, ok := vocabulary[strings.ToLower(kw)]; ok {
+			matched = append(matched, TagSuggestion{Name: existing, Confidence: 0.8, Existing: true})
+			continue
+		}
+		proposed = append(proposed, TagSuggestion{Name: strings.ToLower(kw), Confidence: max(0.5-0.05*float64(i), 0.1)})
+	}
+	out := append(matched, proposed...)
+	if len(out) > maxTags {
+		out = out[:maxTags]
+	}
+	if out == nil {
+		out = []TagSuggestion{}
+	}
+	return out
+}
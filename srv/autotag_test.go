@@ -0,0 +1,85 @@
+package srv
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestAutoTagBookmark(t *testing.T) {
+	ctx := context.Background()
+	server, err := New(filepath.Join(t.TempDir(), "test.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := dbgen.New(server.DB)
+	q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: "Golang", Color: strPtr("#000000")})
+	b, _ := q.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: ownerID(ctx), Url: "https://go.dev/", Title: "Go", SourceType: "web"})
+	analysis := &ContentAnalysis{Keywords: []string{"golang", "compiler"}, text: "The Go programming language"}
+
+	fake := &FakeLLM{Respond: func(CompletionRequest) string {
+		return "Sure:\n```json\n{\"tags\": [{\"name\": \"golang\", \"confidence\": 0.95}, {\"name\": \"Compilers\", \"confidence\": 0.4}, {\"name\": \"golang\", \"confidence\": 0.2}]}\n```"
+	}}
+	server.LLM = fake
+
+	t.Run("suggest only", func(t *testing.T) {
+		res, err := server.autoTagBookmark(ctx, b, analysis, AutoTagOptions{SuggestOnly: true, Threshold: 0.7, MaxTags: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Applied) != 0 {
+			t.Errorf("suggest-only applied %v", res.Applied)
+		}
+		if tags, _ := q.GetBookmarkTags(ctx, dbgen.GetBookmarkTagsParams{OwnerID: ownerID(ctx), BookmarkID: b.ID}); len(tags) != 0 {
+			t.Errorf("expected no tags, got %v", tags)
+		}
+	})
+
+	t.Run("apply above threshold", func(t *testing.T) {
+		res, err := server.autoTagBookmark(ctx, b, analysis, AutoTagOptions{Threshold: 0.7, MaxTags: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Source != TagSourceLLM || len(res.LLM) != 2 {
+			t.Fatalf("unexpected result %+v", res)
+		}
+		if res.LLM[0].Name != "Golang" || !res.LLM[0].Existing || res.LLM[1].Name != "compilers" || res.LLM[1].Existing {
+			t.Errorf("unexpected LLM suggestions %+v", res.LLM)
+		}
+		if len(res.Applied) != 1 || res.Applied[0] != "Golang" {
+			t.Errorf("expected only Golang applied, got %v", res.Applied)
+		}
+		if prompt := fake.Calls()[0].Prompt; !strings.Contains(prompt, "Existing tags: Golang") {
+			t.Errorf("prompt missing vocabulary: %s", prompt)
+		}
+		if n := fake.Calls()[0].MaxTokens; n != 50+30*5 {
+			t.Errorf("MaxTokens = %d, want it sized for 5 tags", n)
+		}
+	})
+
+	t.Run("keyword fallback", func(t *testing.T) {
+		server.LLM = nil
+		res, err := server.autoTagBookmark(ctx, b, analysis, AutoTagOptions{Threshold: 0.7, MaxTags: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Source != TagSourceKeywords || len(res.Keywords) != 2 || res.Keywords[0].Name != "Golang" {
+			t.Errorf("unexpected fallback result %+v", res)
+		}
+	})
+
+	suggestions, err := q.ListTagSuggestions(ctx, dbgen.ListTagSuggestionsParams{OwnerID: ownerID(ctx), BookmarkID: b.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bySource := map[string]int{}
+	for _, s := range suggestions {
+		bySource[s.Source]++
+	}
+	if bySource[TagSourceLLM] != 4 || bySource[TagSourceKeywords] != 6 {
+		t.Errorf("unexpected audit counts %v", bySource)
+	}
+}
@@ -0,0 +1,265 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// backupVersion is the version of the Backup document format. Bump it when
+// the document changes in a way older servers cannot restore.
+const backupVersion = 1
+
+// Backup is the full-library document produced by GET /api/export/json.
+// Keywords travel on each bookmark.
+type Backup struct {
+	Version             int                        `json:"version"`
+	SchemaVersion       int64                      `json:"schema_version"`
+	ExportedAt          time.Time                  `json:"exported_at"`
+	Bookmarks           []dbgen.Bookmark           `json:"bookmarks"`
+	Tags                []dbgen.Tag                `json:"tags"`
+	BookmarkTags        []dbgen.BookmarkTag        `json:"bookmark_tags"`
+	Collections         []dbgen.Collection         `json:"collections"`
+	BookmarkCollections []dbgen.BookmarkCollection `json:"bookmark_collections"`
+}
+
+// RestoreConflict describes a backup record that was not restored as-is.
+type RestoreConflict struct {
+	Type   string `json:"type"`
+	ID     int64  `json:"id"`
+	Key    string `json:"key,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// RestoreResult summarizes a POST /api/import/json run.
+type RestoreResult struct {
+	Mode      string            `json:"mode"`
+	Created   map[string]int    `json:"created"`
+	Conflicts []RestoreConflict `json:"conflicts"`
+}
+
+func (s *Server) HandleJSONExport(w http.ResponseWriter, r *http.Request) {
+	backup, err := s.exportBackup(r.Context())
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	filename := "bookmarks-" + backup.ExportedAt.Format("20060102") + ".json"
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	writeJSON(w, backup)
+}
+
+func (s *Server) exportBackup(ctx context.Context) (*Backup, error) {
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	owner := ownerID(ctx)
+
+	backup := &Backup{Version: backupVersion, ExportedAt: time.Now().UTC()}
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(migration_number), 0) FROM migrations").Scan(&backup.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("read schema version: %w", err)
+	}
+	if backup.Bookmarks, err = q.ListAllBookmarks(ctx, owner); err != nil {
+		return nil, err
+	}
+	if backup.Tags, err = q.ListTags(ctx, owner); err != nil {
+		return nil, err
+	}
+	if backup.BookmarkTags, err = q.ListAllBookmarkTags(ctx, owner); err != nil {
+		return nil, err
+	}
+	if backup.Collections, err = q.ListCollections(ctx, owner); err != nil {
+		return nil, err
+	}
+	if backup.BookmarkCollections, err = q.ListAllBookmarkCollections(ctx, owner); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// HandleJSONImport restores a Backup into the current user's library.
+// With ?mode=replace that library is wiped and rebuilt from the backup
+// under new IDs; the default ?mode=merge adds records that don't exist
+// yet, matching bookmarks by URL and tags and collections by name. Either
+// way the restore runs in one transaction.
+func (s *Server) HandleJSONImport(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		writeError(w, "mode must be merge or replace", 400)
+		return
+	}
+
+	var backup Backup
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<20)).Decode(&backup); err != nil {
+		writeError(w, "invalid JSON", 400)
+		return
+	}
+	if backup.Version != backupVersion {
+		writeError(w, fmt.Sprintf("unsupported backup version %d (want %d)", backup.Version, backupVersion), 400)
+		return
+	}
+
+	result, err := s.restoreBackup(r.Context(), &backup, mode)
+	if err != nil {
+		writeError(w, "restore failed: "+err.Error(), 500)
+		return
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) restoreBackup(ctx context.Context, backup *Backup, mode string) (*RestoreResult, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res := &RestoreResult{Mode: mode, Created: map[string]int{}, Conflicts: []RestoreConflict{}}
+	conflict := func(typ string, id int64, key, reason string) {
+		res.Conflicts = append(res.Conflicts, RestoreConflict{Type: typ, ID: id, Key: key, Reason: reason})
+	}
+
+	q := dbgen.New(tx)
+	owner := ownerID(ctx)
+	if mode == "replace" {
+		// Only the current user's library is cleared; IDs are reassigned
+		// on restore since other users share the ID space.
+		if err := q.DeleteOwnerBookmarkTags(ctx, owner); err != nil {
+			return nil, fmt.Errorf("clear bookmark tags: %w", err)
+		}
+		if err := q.DeleteOwnerBookmarkCollections(ctx, owner); err != nil {
+			return nil, fmt.Errorf("clear bookmark collections: %w", err)
+		}
+		if err := q.DeleteOwnerBookmarks(ctx, owner); err != nil {
+			return nil, fmt.Errorf("clear bookmarks: %w", err)
+		}
+		if err := q.DeleteOwnerTags(ctx, owner); err != nil {
+			return nil, fmt.Errorf("clear tags: %w", err)
+		}
+		if err := q.DeleteOwnerCollections(ctx, owner); err != nil {
+			return nil, fmt.Errorf("clear collections: %w", err)
+		}
+	}
+
+	tagIDs := make(map[int64]int64)
+	for _, t := range backup.Tags {
+		if t.Name == "" {
+			conflict("tag", t.ID, "", "missing name")
+			continue
+		}
+		if existing, err := q.GetTagByName(ctx, dbgen.GetTagByNameParams{OwnerID: owner, Name: t.Name}); err == nil {
+			tagIDs[t.ID] = existing.ID
+			conflict("tag", t.ID, t.Name, "tag already exists; kept existing")
+			continue
+		}
+		created, err := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: owner, Name: t.Name, Color: t.Color})
+		if err != nil {
+			conflict("tag", t.ID, t.Name, err.Error())
+			continue
+		}
+		tagIDs[t.ID] = created.ID
+		res.Created["tags"]++
+	}
+
+	collectionIDs := make(map[int64]int64)
+	for _, c := range backup.Collections {
+		if c.Name == "" {
+			conflict("collection", c.ID, "", "missing name")
+			continue
+		}
+		if existing, err := q.GetCollectionByName(ctx, dbgen.GetCollectionByNameParams{OwnerID: owner, Name: c.Name}); err == nil {
+			collectionIDs[c.ID] = existing.ID
+			conflict("collection", c.ID, c.Name, "collection already exists; kept existing")
+			continue
+		}
+		created, err := q.CreateCollection(ctx, dbgen.CreateCollectionParams{
+			OwnerID: owner,
+			Name:    c.Name, Description: c.Description, Icon: c.Icon,
+		})
+		if err != nil {
+			conflict("collection", c.ID, c.Name, err.Error())
+			continue
+		}
+		collectionIDs[c.ID] = created.ID
+		res.Created["collections"]++
+	}
+
+	bookmarkIDs := make(map[int64]int64)
+	var needImages []int64
+	for _, b := range backup.Bookmarks {
+		if b.Url == "" {
+			conflict("bookmark", b.ID, "", "missing url")
+			continue
+		}
+		if b.CreatedAt.IsZero() {
+			b.CreatedAt = time.Now().UTC()
+		}
+		if b.UpdatedAt.IsZero() {
+			b.UpdatedAt = b.CreatedAt
+		}
+		if existing, err := q.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: owner, Url: b.Url}); err == nil {
+			bookmarkIDs[b.ID] = existing.ID
+			conflict("bookmark", b.ID, b.Url, "bookmark with this url already exists; kept existing")
+			continue
+		}
+		image := localImageURL(ctx, q, deref(b.ImageUrl))
+		created, err := q.ImportBookmark(ctx, dbgen.ImportBookmarkParams{
+			OwnerID: owner,
+			Url:     b.Url, Title: b.Title, Description: b.Description, Summary: b.Summary,
+			SourceType: b.SourceType, FaviconUrl: b.FaviconUrl, ImageUrl: strPtr(image),
+			Keywords: b.Keywords, CreatedAt: b.CreatedAt, UpdatedAt: b.UpdatedAt,
+		})
+		if err != nil {
+			conflict("bookmark", b.ID, b.Url, err.Error())
+			continue
+		}
+		if image == "" && deref(b.ImageUrl) != "" {
+			needImages = append(needImages, created.ID)
+		}
+		bookmarkIDs[b.ID] = created.ID
+		res.Created["bookmarks"]++
+	}
+
+	for _, bt := range backup.BookmarkTags {
+		bid, okB := bookmarkIDs[bt.BookmarkID]
+		tid, okT := tagIDs[bt.TagID]
+		if !okB || !okT {
+			conflict("bookmark_tag", bt.BookmarkID, fmt.Sprintf("%d:%d", bt.BookmarkID, bt.TagID), "references a bookmark or tag that was not restored")
+			continue
+		}
+		if err := q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: owner, BookmarkID: bid, TagID: tid}); err != nil {
+			return nil, fmt.Errorf("restore bookmark tag: %w", err)
+		}
+		res.Created["bookmark_tags"]++
+	}
+
+	for _, bc := range backup.BookmarkCollections {
+		bid, okB := bookmarkIDs[bc.BookmarkID]
+		cid, okC := collectionIDs[bc.CollectionID]
+		if !okB || !okC {
+			conflict("bookmark_collection", bc.BookmarkID, fmt.Sprintf("%d:%d", bc.BookmarkID, bc.CollectionID), "references a bookmark or collection that was not restored")
+			continue
+		}
+		if err := q.AddBookmarkToCollection(ctx, dbgen.AddBookmarkToCollectionParams{OwnerID: owner, BookmarkID: bid, CollectionID: cid}); err != nil {
+			return nil, fmt.Errorf("restore bookmark collection: %w", err)
+		}
+		res.Created["bookmark_collections"]++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.queueMetadata(ctx, needImages)
+	return res, nil
+}
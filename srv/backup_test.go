@@ -0,0 +1,79 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestJSONBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	src, err := New(filepath.Join(t.TempDir(), "src.sqlite3"), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	q := dbgen.New(src.DB)
+	b, _ := q.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: ownerID(ctx), Url: "https://go.dev/", Title: "Go", SourceType: "web"})
+	q.UpdateBookmarkAnalysis(ctx, dbgen.UpdateBookmarkAnalysisParams{OwnerID: ownerID(ctx), ID: b.ID, Summary: strPtr("Go site"), Keywords: strPtr(`["go"]`)})
+	tag, _ := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: "lang", Color: strPtr("#ff0000")})
+	q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: b.ID, TagID: tag.ID})
+	col, _ := q.CreateCollection(ctx, dbgen.CreateCollectionParams{OwnerID: ownerID(ctx), Name: "Reading"})
+	q.AddBookmarkToCollection(ctx, dbgen.AddBookmarkToCollectionParams{OwnerID: ownerID(ctx), BookmarkID: b.ID, CollectionID: col.ID})
+
+	w := httptest.NewRecorder()
+	src.HandleJSONExport(w, httptest.NewRequest("GET", "/api/export/json", nil))
+	if w.Code != 200 {
+		t.Fatalf("export status %d: %s", w.Code, w.Body.String())
+	}
+	exported := w.Body.Bytes()
+
+	restore := func(s *Server, mode string, body []byte) RestoreResult {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.HandleJSONImport(w, httptest.NewRequest("POST", "/api/import/json?mode="+mode, bytes.NewReader(body)))
+		if w.Code != 200 {
+			t.Fatalf("import %s status %d: %s", mode, w.Code, w.Body.String())
+		}
+		var res RestoreResult
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res
+	}
+
+	dst, err := New(filepath.Join(t.TempDir(), "dst.sqlite3"), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	dq := dbgen.New(dst.DB)
+	dq.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: ownerID(ctx), Url: "https://example.com/", Title: "Existing", SourceType: "web"})
+
+	res := restore(dst, "replace", exported)
+	if res.Created["bookmarks"] != 1 || res.Created["bookmark_tags"] != 1 || len(res.Conflicts) != 0 {
+		t.Errorf("unexpected replace result %+v", res)
+	}
+	if n, _ := dq.CountBookmarks(ctx, ownerID(ctx)); n != 1 {
+		t.Errorf("replace should leave 1 bookmark, got %d", n)
+	}
+	got, err := dq.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: ownerID(ctx), Url: b.Url})
+	if err != nil || got.Keywords == nil || *got.Keywords != `["go"]` || !got.CreatedAt.Equal(b.CreatedAt) {
+		t.Errorf("bookmark not restored faithfully: %+v, %v", got, err)
+	}
+	if cols, _ := dq.GetBookmarkCollections(ctx, dbgen.GetBookmarkCollectionsParams{OwnerID: ownerID(ctx), BookmarkID: got.ID}); len(cols) != 1 {
+		t.Errorf("collection membership not restored: %v", cols)
+	}
+
+	res = restore(dst, "merge", exported)
+	if res.Created["bookmarks"] != 0 || len(res.Conflicts) != 3 {
+		t.Errorf("merge of identical backup should only report conflicts, got %+v", res)
+	}
+
+	w = httptest.NewRecorder()
+	dst.HandleJSONImport(w, httptest.NewRequest("POST", "/api/import/json", bytes.NewReader([]byte(`{"version":99}`))))
+	if w.Code != 400 {
+		t.Errorf("expected 400 for unsupported version, got %d", w.Code)
+	}
+}
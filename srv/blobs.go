@@ -0,0 +1,62 @@
+package srv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// putBlob stores data in BlobDir under its SHA-256 hash and returns the
+// hash. Storing the same content twice keeps one copy.
+func (s *Server) putBlob(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := s.blobPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// Write and rename so readers never see a partial file.
+	f, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// openBlob opens the blob stored under hash.
+func (s *Server) openBlob(hash string) (*os.File, error) {
+	if !validBlobHash(hash) {
+		return nil, errors.New("invalid blob hash")
+	}
+	return os.Open(s.blobPath(hash))
+}
+
+// blobPath spreads blobs over subdirectories named by the hash's first
+// two characters.
+func (s *Server) blobPath(hash string) string {
+	return filepath.Join(s.BlobDir, hash[:2], hash)
+}
+
+func validBlobHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
@@ -0,0 +1,326 @@
+package srv
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// EmbeddingProvider turns text into vectors. Model identifies the vector
+// space; embeddings are only compared within the same model.
+type EmbeddingProvider interface {
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbeddingConfig selects an EmbeddingProvider. Provider is "openai",
+// "openai-compatible" or "hashing"; empty means the local hashing embedder.
+type EmbeddingConfig struct {
+	Provider string
+	Model    string
+	BaseURL  string
+	APIKey   string
+	// APIKeyFunc is used when APIKey is empty; see LLMConfig.
+	APIKeyFunc APIKeyFunc
+	Timeout    time.Duration
+}
+
+const defaultOpenAIEmbeddingModel = "text-embedding-3-small"
+
+func NewEmbeddingProvider(cfg EmbeddingConfig) (EmbeddingProvider, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	switch cfg.Provider {
+	case "", "hashing":
+		return NewHashingEmbedder(512), nil
+	case "openai":
+		if cfg.APIKey == "" && cfg.APIKeyFunc == nil {
+			return nil, fmt.Errorf("openai embeddings require an API key")
+		}
+		return &openAIEmbedder{
+			baseURL: orDefault(cfg.BaseURL, defaultOpenAIBaseURL),
+			model:   orDefault(cfg.Model, defaultOpenAIEmbeddingModel),
+			apiKey:  cfg.APIKey,
+			keyFunc: cfg.APIKeyFunc,
+			client:  &http.Client{Timeout: cfg.Timeout},
+		}, nil
+	case "openai-compatible":
+		if cfg.BaseURL == "" || cfg.Model == "" {
+			return nil, fmt.Errorf("openai-compatible embeddings require a base URL and model")
+		}
+		return &openAIEmbedder{
+			baseURL: cfg.BaseURL,
+			model:   cfg.Model,
+			apiKey:  cfg.APIKey,
+			keyFunc: cfg.APIKeyFunc,
+			client:  &http.Client{Timeout: cfg.Timeout},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+}
+
+// openAIEmbedder calls the /embeddings endpoint of OpenAI or a compatible
+// server such as Ollama.
+type openAIEmbedder struct {
+	baseURL string
+	model   string
+	apiKey  string
+	keyFunc APIKeyFunc
+	client  *http.Client
+}
+
+func (e *openAIEmbedder) Model() string { return e.model }
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	apiKey, err := resolveAPIKey(ctx, e.apiKey, e.keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["Authorization"] = "Bearer " + apiKey
+	}
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	body := map[string]any{"model": e.model, "input": texts}
+	if err := postJSON(ctx, e.client, strings.TrimSuffix(e.baseURL, "/")+"/embeddings", headers, body, &result); err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings: got %d vectors for %d inputs", len(result.Data), len(texts))
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("embeddings: bad index %d", d.Index)
+		}
+		out[d.Index] = normalize(d.Embedding)
+	}
+	return out, nil
+}
+
+// HashingEmbedder is a local embedder that needs no network. It hashes
+// words and their character trigrams into a fixed number of dimensions
+// with sublinear term weighting, so "pgvector" and "vector" land close
+// together even though they are different words.
+type HashingEmbedder struct {
+	Dims int
+}
+
+func NewHashingEmbedder(dims int) *HashingEmbedder {
+	return &HashingEmbedder{Dims: dims}
+}
+
+func (e *HashingEmbedder) Model() string { return fmt.Sprintf("hashing-%d", e.Dims) }
+
+func (e *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = e.embed(text)
+	}
+	return out, nil
+}
+
+func (e *HashingEmbedder) embed(text string) []float32 {
+	counts := make(map[string]float64)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, w := range words {
+		if len(w) < 2 || stopWords[w] {
+			continue
+		}
+		counts["w:"+w]++
+		padded := []rune("<" + w + ">")
+		for i := 0; i+3 <= len(padded); i++ {
+			counts["g:"+string(padded[i:i+3])] += 0.5
+		}
+	}
+
+	vec := make([]float32, e.Dims)
+	for feature, tf := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		weight := 1 + math.Log(tf+1)
+		if sum&(1<<63) != 0 {
+			weight = -weight
+		}
+		vec[sum%uint64(e.Dims)] += float32(weight)
+	}
+	return normalize(vec)
+}
+
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= scale
+	}
+	return v
+}
+
+// cosine returns the cosine similarity of two unit vectors.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+// embeddingText is the text embedded for a bookmark: title, description,
+// summary, keywords and tag names.
+func embeddingText(b dbgen.Bookmark, tags []dbgen.Tag) string {
+	parts := []string{b.Title}
+	for _, p := range []*string{b.Description, b.Summary} {
+		if p != nil {
+			parts = append(parts, *p)
+		}
+	}
+	if b.Keywords != nil {
+		var kws []string
+		if json.Unmarshal([]byte(*b.Keywords), &kws) == nil {
+			parts = append(parts, strings.Join(kws, " "))
+		}
+	}
+	for _, t := range tags {
+		parts = append(parts, t.Name)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// embedBookmark computes and stores the bookmark's embedding unless the
+// stored one was computed from the same text.
+func (s *Server) embedBookmark(ctx context.Context, id int64) error {
+	if s.Embedder == nil {
+		return nil
+	}
+	q := dbgen.New(s.DB)
+	b, err := q.GetBookmark(ctx, dbgen.GetBookmarkParams{OwnerID: ownerID(ctx), ID: id})
+	if err != nil {
+		return err
+	}
+	tags, err := q.GetBookmarkTags(ctx, dbgen.GetBookmarkTagsParams{OwnerID: ownerID(ctx), BookmarkID: id})
+	if err != nil {
+		return err
+	}
+	text := embeddingText(b, tags)
+	sum := sha256.Sum256([]byte(text))
+	hash := hex.EncodeToString(sum[:])
+
+	model := s.Embedder.Model()
+	existing, err := q.GetEmbedding(ctx, dbgen.GetEmbeddingParams{BookmarkID: id, Model: model})
+	if err == nil && existing.ContentHash == hash {
+		return nil
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	vecs, err := s.Embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return err
+	}
+	return q.UpsertEmbedding(ctx, dbgen.UpsertEmbeddingParams{
+		BookmarkID:  id,
+		Model:       model,
+		Dims:        int64(len(vecs[0])),
+		Vector:      encodeVector(vecs[0]),
+		ContentHash: hash,
+	})
+}
+
+type scoredID struct {
+	ID    int64
+	Score float64
+}
+
+// semanticRank embeds text and returns bookmark IDs ordered by cosine
+// similarity, dropping those below minSimilarity.
+func (s *Server) semanticRank(ctx context.Context, text string, minSimilarity float64) ([]scoredID, error) {
+	if s.Embedder == nil {
+		return nil, fmt.Errorf("no embedding provider configured")
+	}
+	vecs, err := s.Embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := dbgen.New(s.DB).ListEmbeddings(ctx, dbgen.ListEmbeddingsParams{OwnerID: ownerID(ctx), Model: s.Embedder.Model()})
+	if err != nil {
+		return nil, err
+	}
+	ranked := make([]scoredID, 0, len(rows))
+	for _, row := range rows {
+		if sim := cosine(vecs[0], decodeVector(row.Vector)); sim >= minSimilarity {
+			ranked = append(ranked, scoredID{ID: row.BookmarkID, Score: sim})
+		}
+	}
+	sortScored(ranked)
+	return ranked, nil
+}
+
+func sortScored(ids []scoredID) {
+	slices.SortFunc(ids, func(a, b scoredID) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// HandleRebuildEmbeddings enqueues a job that embeds every bookmark
+// missing an embedding for the current model.
+func (s *Server) HandleRebuildEmbeddings(w http.ResponseWriter, r *http.Request) {
+	job, err := s.Jobs.Enqueue(r.Context(), JobEmbedAll, map[string]any{}, nil)
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	w.WriteHeader(202)
+	writeJSON(w, map[string]any{"job_id": job.ID, "status": job.Status})
+}
@@ -0,0 +1,196 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event types published on /api/events.
+const (
+	EventProgress        = "progress"
+	EventBookmarkCreated = "bookmark.created"
+	EventBookmarkUpdated = "bookmark.updated"
+	EventBookmarkDeleted = "bookmark.deleted"
+)
+
+// Event is a message fanned out to the owner's /api/events subscribers.
+type Event struct {
+	ID    int64  `json:"id"`
+	Type  string `json:"type"`
+	Data  any    `json:"data"`
+	Owner string `json:"-"`
+}
+
+// EventHub is an in-process publish/subscribe bus. Publishing never blocks:
+// a subscriber that falls behind by more than its buffer misses events.
+// Subscribers only receive events published for their own user.
+type EventHub struct {
+	mu     sync.Mutex
+	nextID int64
+	subs   map[chan Event]string // channel -> owner
+	closed bool
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subs: make(map[chan Event]string)}
+}
+
+func (h *EventHub) Subscribe(owner string) (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+	h.mu.Lock()
+	if h.closed {
+		close(ch)
+	} else {
+		h.subs[ch] = owner
+	}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Close ends every subscription, so that streaming handlers return and the
+// server can shut down.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		close(ch)
+		delete(h.subs, ch)
+	}
+}
+
+// Publish sends an event to subscribers of the user in ctx.
+func (h *EventHub) Publish(ctx context.Context, typ string, data any) {
+	h.publish(ownerID(ctx), typ, data)
+}
+
+func (h *EventHub) publish(owner, typ string, data any) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	e := Event{ID: h.nextID, Type: typ, Data: data, Owner: owner}
+	for ch, subOwner := range h.subs {
+		if subOwner != owner {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// HandleEvents streams events as Server-Sent Events until the client
+// disconnects or the server shuts down.
+func (s *Server) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "streaming unsupported", 500)
+		return
+	}
+	events, unsubscribe := s.Events.Subscribe(ownerID(r.Context()))
+	defer unsubscribe()
+	// The stream outlives the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// Progress is the payload of a "progress" event. Operation names the kind
+// of work (e.g. youtube_import) and OperationID identifies one run of it.
+type Progress struct {
+	Operation   string `json:"operation"`
+	OperationID string `json:"operation_id"`
+	Stage       string `json:"stage"`
+	Found       int    `json:"found"`
+	Saved       int    `json:"saved"`
+	Skipped     int    `json:"skipped"`
+	Failed      int    `json:"failed"`
+	Current     string `json:"current,omitempty"`
+	Done        bool   `json:"done"`
+}
+
+// progressReporter publishes progress events for one long-running operation.
+type progressReporter struct {
+	hub   *EventHub
+	owner string
+	Progress
+}
+
+// newProgress starts reporting for an operation. Clients may pick the
+// operation ID themselves with ?operation_id= so they can match events to
+// the request they sent before it returns.
+func (s *Server) newProgress(r *http.Request, operation string) *progressReporter {
+	id := r.URL.Query().Get("operation_id")
+	if id == "" {
+		id = newOperationID()
+	}
+	return &progressReporter{
+		hub:      s.Events,
+		owner:    ownerID(r.Context()),
+		Progress: Progress{Operation: operation, OperationID: id},
+	}
+}
+
+func newOperationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (p *progressReporter) emit(stage string) {
+	p.Stage = stage
+	p.hub.publish(p.owner, EventProgress, p.Progress)
+}
+
+func (p *progressReporter) found(n int)         { p.Found = n; p.emit("found") }
+func (p *progressReporter) item(current string) { p.Current = current; p.emit("item") }
+func (p *progressReporter) saved()              { p.Saved++; p.emit("saved") }
+func (p *progressReporter) skipped()            { p.Skipped++; p.emit("skipped") }
+func (p *progressReporter) failed()             { p.Failed++; p.emit("failed") }
+
+func (p *progressReporter) done() {
+	p.Current = ""
+	p.Done = true
+	p.emit("done")
+}
@@ -0,0 +1,332 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const (
+	// faviconSize is the icon width, in pixels, the UI looks best with:
+	// the smallest icon at least this big is preferred.
+	faviconSize = 64
+	// scalableIconSize ranks sizes="any" (usually SVG) icons.
+	scalableIconSize = 1024
+	maxFaviconBytes  = 512 << 10
+	// faviconRetry is how long a domain with no usable favicon is left
+	// alone before discovery runs again.
+	faviconRetry = 24 * time.Hour
+)
+
+// faviconDomain returns the host (and port, if any) a URL's favicon is
+// cached under, or "" for URLs that aren't http or https.
+func faviconDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// cacheFavicon makes sure the favicon for rawURL's domain is cached and
+// returns the local URL it is served from, or "" if the domain has no
+// usable icon. page, if not nil, is rawURL already fetched; otherwise it
+// is fetched when the domain isn't cached.
+func (s *Server) cacheFavicon(ctx context.Context, rawURL string, page *PageMetadata) string {
+	domain := faviconDomain(rawURL)
+	if domain == "" {
+		return ""
+	}
+	q := dbgen.New(s.DB)
+	fav, err := q.GetFavicon(ctx, domain)
+	if err != nil || faviconStale(fav) {
+		if err := s.discoverFavicon(ctx, domain, rawURL, page); err != nil {
+			slog.Debug("favicon: discovery failed", "domain", domain, "error", err)
+		}
+		fav, err = q.GetFavicon(ctx, domain)
+	}
+	if err != nil || fav.Hash == nil {
+		return ""
+	}
+	return "/favicons/" + domain
+}
+
+// cachedFavicon returns the local URL of rawURL's favicon if it is already
+// cached, without fetching anything, and "" otherwise.
+func (s *Server) cachedFavicon(ctx context.Context, rawURL string) string {
+	domain := faviconDomain(rawURL)
+	if domain == "" {
+		return ""
+	}
+	if fav, err := dbgen.New(s.DB).GetFavicon(ctx, domain); err != nil || fav.Hash == nil {
+		return ""
+	}
+	return "/favicons/" + domain
+}
+
+func faviconStale(fav dbgen.Favicon) bool {
+	return fav.Hash == nil && time.Since(fav.FetchedAt) > faviconRetry
+}
+
+// discoverFavicon finds the best icon for pageURL, downloads it and
+// records it for domain. Concurrent calls for the same domain wait for
+// the first.
+func (s *Server) discoverFavicon(ctx context.Context, domain, pageURL string, page *PageMetadata) error {
+	done := make(chan struct{})
+	if running, loaded := s.faviconLookups.LoadOrStore(domain, done); loaded {
+		select {
+		case <-running.(chan struct{}):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer func() {
+		s.faviconLookups.Delete(domain)
+		close(done)
+	}()
+
+	if page == nil {
+		var err error
+		if page, err = s.fetchHTML(ctx, pageURL); err != nil {
+			page = &PageMetadata{URL: pageURL}
+		}
+	}
+	icons := page.Favicons
+	if page.Manifest != "" {
+		icons = append(icons, s.manifestIcons(ctx, page.Manifest)...)
+	}
+	params := dbgen.UpsertFaviconParams{Domain: domain}
+	for _, icon := range faviconCandidates(page.URL, icons) {
+		data, contentType, err := s.downloadImage(ctx, icon, maxFaviconBytes)
+		if err != nil {
+			slog.Debug("favicon: download failed", "url", icon, "error", err)
+			continue
+		}
+		hash, err := s.putBlob(data)
+		if err != nil {
+			return err
+		}
+		params.Hash, params.ContentType, params.SourceUrl = &hash, &contentType, &icon
+		break
+	}
+	// Don't remember a miss that was only an interrupted request.
+	if params.Hash == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return dbgen.New(s.DB).UpsertFavicon(ctx, params)
+}
+
+// faviconCandidates returns the icon URLs worth trying, best first, ending
+// with /favicon.ico at the page's origin.
+func faviconCandidates(pageURL string, icons []PageIcon) []string {
+	icons = slices.DeleteFunc(slices.Clone(icons), func(icon PageIcon) bool {
+		// Mask icons are single-colour silhouettes.
+		return strings.Contains(icon.Rel, "mask-icon")
+	})
+	slices.SortStableFunc(icons, func(a, b PageIcon) int {
+		sa, sb := iconSize(a), iconSize(b)
+		switch fitsA, fitsB := sa >= faviconSize, sb >= faviconSize; {
+		case fitsA != fitsB:
+			if fitsA {
+				return -1
+			}
+			return 1
+		case fitsA:
+			return sa - sb
+		default:
+			return sb - sa
+		}
+	})
+	var urls []string
+	for _, icon := range icons {
+		if !slices.Contains(urls, icon.URL) {
+			urls = append(urls, icon.URL)
+		}
+	}
+	if u, err := url.Parse(pageURL); err == nil && u.Host != "" {
+		if ico := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/favicon.ico"}).String(); !slices.Contains(urls, ico) {
+			urls = append(urls, ico)
+		}
+	}
+	return urls
+}
+
+// iconSize returns the largest width in an icon's sizes attribute. Apple
+// touch icons without one are 180 pixels; other unsized icons rank last.
+func iconSize(icon PageIcon) int {
+	size := 0
+	for _, s := range strings.Fields(strings.ToLower(icon.Sizes)) {
+		if s == "any" {
+			return scalableIconSize
+		}
+		w, _, _ := strings.Cut(s, "x")
+		if n, err := strconv.Atoi(w); err == nil && n > size {
+			size = n
+		}
+	}
+	switch {
+	case size > 0:
+		return size
+	case icon.Type == "image/svg+xml":
+		return scalableIconSize
+	case strings.Contains(icon.Rel, "apple-touch-icon"):
+		return 180
+	}
+	return 0
+}
+
+// manifestIcons reads the icons listed in a web app manifest. Icons only
+// meant for masking or monochrome use are skipped.
+func (s *Server) manifestIcons(ctx context.Context, manifestURL string) []PageIcon {
+	base, err := url.Parse(manifestURL)
+	if err != nil {
+		return nil
+	}
+	req, err := s.newFetchRequest(ctx, manifestURL)
+	if err != nil {
+		return nil
+	}
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil
+	}
+	var manifest struct {
+		Icons []struct {
+			Src     string `json:"src"`
+			Sizes   string `json:"sizes"`
+			Type    string `json:"type"`
+			Purpose string `json:"purpose"`
+		} `json:"icons"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxPageBytes)).Decode(&manifest); err != nil {
+		slog.Debug("favicon: bad manifest", "url", manifestURL, "error", err)
+		return nil
+	}
+	var icons []PageIcon
+	for _, icon := range manifest.Icons {
+		if purpose := strings.Fields(icon.Purpose); len(purpose) > 0 && !slices.Contains(purpose, "any") {
+			continue
+		}
+		u, err := base.Parse(strings.TrimSpace(icon.Src))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		icons = append(icons, PageIcon{URL: u.String(), Rel: "manifest", Sizes: icon.Sizes, Type: icon.Type})
+	}
+	return icons
+}
+
+// downloadImage fetches an image of at most maxBytes and returns it with
+// its content type. The type is sniffed from the data rather than trusted
+// from the response, since servers often answer with an HTML error page.
+func (s *Server) downloadImage(ctx context.Context, imageURL string, maxBytes int64) ([]byte, string, error) {
+	req, err := s.newFetchRequest(ctx, imageURL)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("fetch %s: %s", imageURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", fmt.Errorf("fetch %s: image larger than %d bytes", imageURL, maxBytes)
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	// SVG is XML to the sniffer, so the response header is believed for it.
+	if header, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); header == "image/svg+xml" && strings.Contains(string(data), "<svg") {
+		contentType = header
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("fetch %s: not an image (%s)", imageURL, contentType)
+	}
+	return data, contentType, nil
+}
+
+// HandleFavicon serves the cached favicon for a domain the user has
+// bookmarked, looking it up if it isn't cached yet. The cache is shared,
+// so other domains are a 404 whether cached or not: otherwise the answer
+// would tell whether anyone had bookmarked them.
+func (s *Server) HandleFavicon(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(r.PathValue("domain"))
+	pageURL := ""
+	if ownerID(r.Context()) != "" {
+		pageURL = s.bookmarkURLForDomain(r.Context(), domain)
+	}
+	if pageURL == "" {
+		http.NotFound(w, r)
+		return
+	}
+	q := dbgen.New(s.DB)
+	fav, err := q.GetFavicon(r.Context(), domain)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && faviconStale(fav)) {
+		s.cacheFavicon(r.Context(), pageURL, nil)
+		fav, err = q.GetFavicon(r.Context(), domain)
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && fav.Hash == nil) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	f, err := s.openBlob(*fav.Hash)
+	if err != nil {
+		slog.Warn("favicon: missing blob", "domain", domain, "hash", *fav.Hash, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", *fav.ContentType)
+	// Private, since whether it is served depends on the user.
+	w.Header().Set("Cache-Control", "private, max-age=604800")
+	w.Header().Set("ETag", `"`+*fav.Hash+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// SVG icons can carry scripts; never run them on this origin.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	http.ServeContent(w, r, "", fav.FetchedAt, f)
+}
+
+// bookmarkURLForDomain returns the URL of one of the user's bookmarks on
+// domain, or "" if they have none.
+func (s *Server) bookmarkURLForDomain(ctx context.Context, domain string) string {
+	urls, err := dbgen.New(s.DB).ListBookmarkURLsByHost(ctx, dbgen.ListBookmarkURLsByHostParams{
+		OwnerID: ownerID(ctx),
+		Host:    &domain,
+	})
+	if err != nil {
+		return ""
+	}
+	for _, u := range urls {
+		// LIKE treats _ in the host as a wildcard.
+		if faviconDomain(u) == domain {
+			return u
+		}
+	}
+	return ""
+}
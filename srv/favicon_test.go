@@ -0,0 +1,159 @@
+package srv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestFavicons(t *testing.T) {
+	icons := []PageIcon{
+		{URL: "https://example.com/16.png", Rel: "icon", Sizes: "16x16"},
+		{URL: "https://example.com/mask.svg", Rel: "mask-icon"},
+		{URL: "https://example.com/unsized.png", Rel: "icon"},
+		{URL: "https://example.com/touch.png", Rel: "apple-touch-icon"},
+		{URL: "https://example.com/96.png", Rel: "manifest", Sizes: "48x48 96x96"},
+		{URL: "https://example.com/32.png", Rel: "icon", Sizes: "32x32"},
+	}
+	want := []string{
+		"https://example.com/96.png", "https://example.com/touch.png", "https://example.com/32.png",
+		"https://example.com/16.png", "https://example.com/unsized.png", "https://example.com/favicon.ico",
+	}
+	if got := faviconCandidates("https://example.com/post", icons); !slices.Equal(got, want) {
+		t.Errorf("candidates = %q", got)
+	}
+
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"
+	var touchHits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Page</title><meta property="og:image" content="/og.png">
+			<link rel="icon" href="/16.png" sizes="16x16"><link rel="apple-touch-icon" href="/touch.png">
+			<link rel="manifest" href="/app/site.webmanifest"></head></html>`))
+	})
+	mux.HandleFunc("/app/site.webmanifest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"icons": [{"src": "m96.png", "sizes": "96x96"}, {"src": "mask.png", "sizes": "72x72", "purpose": "maskable"}]}`))
+	})
+	mux.HandleFunc("/app/m96.png", func(w http.ResponseWriter, r *http.Request) {
+		// A soft 404: an error page served as an image.
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("<html><body>Not found</body></html>"))
+	})
+	mux.HandleFunc("/touch.png", func(w http.ResponseWriter, r *http.Request) {
+		touchHits.Add(1)
+		w.Write([]byte(png))
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+	host := strings.TrimPrefix(site.URL, "http://")
+
+	server, err := New(filepath.Join(t.TempDir(), "favicons.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ScreenshotFallback = false
+	handler := server.Handler()
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-ExeDev-UserID", "alice")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	type bookmark struct {
+		ID         int64  `json:"id"`
+		FaviconURL string `json:"favicon_url"`
+	}
+	create := func(url string) bookmark {
+		t.Helper()
+		w := do("POST", "/api/bookmarks", `{"url": "`+url+`", "title": "Page", "image_url": "/img.png"}`)
+		if w.Code != 201 {
+			t.Fatalf("create: %d %s", w.Code, w.Body)
+		}
+		var b bookmark
+		json.NewDecoder(w.Body).Decode(&b)
+		return b
+	}
+	stored := func(id int64) bookmark {
+		var got struct {
+			Bookmark bookmark `json:"bookmark"`
+		}
+		json.NewDecoder(do("GET", fmt.Sprintf("/api/bookmarks/%d", id), "").Body).Decode(&got)
+		return got.Bookmark
+	}
+	// The icon is looked for by a job rather than while creating; once it
+	// is cached, new bookmarks on the domain get it straight away.
+	first := create(site.URL + "/page")
+	if first.FaviconURL != "" {
+		t.Errorf("favicon_url before the job ran = %q", first.FaviconURL)
+	}
+	runQueuedJobs(t, server.Jobs)
+	if b := stored(first.ID); b.FaviconURL != "/favicons/"+host {
+		t.Errorf("favicon_url after the job = %q", b.FaviconURL)
+	}
+	if b := create(site.URL + "/page?again=1"); b.FaviconURL != "/favicons/"+host {
+		t.Errorf("favicon_url on a cached domain = %q", b.FaviconURL)
+	}
+	runQueuedJobs(t, server.Jobs)
+	if n := touchHits.Load(); n != 1 {
+		t.Errorf("icon downloaded %d times, want once per domain", n)
+	}
+
+	w := do("GET", "/favicons/"+url.PathEscape(host), "")
+	if w.Code != 200 || w.Body.String() != png || w.Header().Get("Content-Type") != "image/png" || w.Header().Get("Cache-Control") == "" {
+		t.Fatalf("favicon: %d %q %v", w.Code, w.Body, w.Header())
+	}
+	if w := do("GET", "/favicons/"+host, "", "If-None-Match", w.Header().Get("ETag")); w.Code != 304 {
+		t.Errorf("conditional request: %d", w.Code)
+	}
+	if w := do("GET", "/favicons/unknown.example", ""); w.Code != 404 {
+		t.Errorf("unknown domain: %d", w.Code)
+	}
+	// Only users with a bookmark on the domain learn that it is cached.
+	if w := do("GET", "/favicons/"+host, "", "X-ExeDev-UserID", "bob"); w.Code != 404 {
+		t.Errorf("favicon served to a user without a bookmark there: %d", w.Code)
+	}
+
+	// Bookmarks on lookalike domains don't hide the real one, however many
+	// there are.
+	carol := withOwner(t.Context(), "carol")
+	q := dbgen.New(server.DB)
+	q.CreateBookmark(carol, dbgen.CreateBookmarkParams{OwnerID: "carol", Url: "https://Example.com/real", Title: "Real", SourceType: "web"})
+	for i := range 25 {
+		for _, u := range []string{"https://example.com.au/%d", "https://example.community/%d", "https://example.com:8443/%d"} {
+			q.CreateBookmark(carol, dbgen.CreateBookmarkParams{OwnerID: "carol", Url: fmt.Sprintf(u, i), Title: "Lookalike", SourceType: "web"})
+		}
+	}
+	server.DB.Exec("UPDATE bookmarks SET created_at = datetime('now', '+1 day') WHERE title = 'Lookalike'")
+	if u := server.bookmarkURLForDomain(carol, "example.com"); u != "https://Example.com/real" {
+		t.Errorf("bookmarkURLForDomain = %q", u)
+	}
+
+	// A domain without an icon gets no favicon_url, so the UI shows its
+	// fallback and the icon is looked for again later.
+	bare := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<html><head><title>No icon</title></head></html>`))
+	}))
+	defer bare.Close()
+	b := create(bare.URL + "/page")
+	runQueuedJobs(t, server.Jobs)
+	if b := stored(b.ID); b.ID == 0 || b.FaviconURL != "" {
+		t.Errorf("domain without an icon: %+v", b)
+	}
+}
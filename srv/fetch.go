@@ -0,0 +1,43 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Defaults for requests made on the user's behalf: fetching bookmarked
+// pages and calling third-party APIs.
+const (
+	DefaultFetchTimeout = 15 * time.Second
+	DefaultUserAgent    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+)
+
+// httpClient returns a client that gives up after FetchTimeout and counts
+// requests in s.Metrics.
+func (s *Server) httpClient() *http.Client {
+	timeout := s.FetchTimeout
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	if s.Metrics != nil {
+		client.Transport = meteredTransport{http.DefaultTransport, s.Metrics}
+	}
+	return client
+}
+
+// newFetchRequest returns a GET request for url that identifies itself
+// with UserAgent.
+func (s *Server) newFetchRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	ua := s.UserAgent
+	if ua == "" {
+		ua = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", ua)
+	return req, nil
+}
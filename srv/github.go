@@ -1,212 +1,263 @@
 package srv
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
 const gitConfigFile = ".github-config.json"
 
+// GitHubConfig is kept in .github-config.json under Server.ProjectRoot.
+// The token lives in the secret store; Token is only read from the file
+// when it predates that. Empty fields fall back to Server.GitDefaults.
 type GitHubConfig struct {
 	Repo   string `json:"repo"`
 	Token  string `json:"token,omitempty"`
 	Branch string `json:"branch"`
+
+	// Remote is the name of the git remote, "origin" by default.
+	Remote string `json:"remote,omitempty"`
+	// Auth is "token" (HTTPS with Username and Token), "ssh" (with the
+	// private key at SSHKey, or ssh's own defaults) or "none". When empty
+	// it is guessed from the URL and whether a token is set.
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	SSHKey   string `json:"ssh_key,omitempty"`
+
+	AuthorName  string `json:"author_name,omitempty"`
+	AuthorEmail string `json:"author_email,omitempty"`
 }
 
-func (s *Server) HandleGitHubConfig(w http.ResponseWriter, r *http.Request) {
-	configPath := filepath.Join(getProjectRoot(), gitConfigFile)
-	
-	if r.Method == "GET" {
-		// Return full config including token
-		data, err := os.ReadFile(configPath)
-		if err != nil {
-			writeJSON(w, GitHubConfig{Branch: "main"})
-			return
+// Git authentication methods.
+const (
+	GitAuthToken = "token"
+	GitAuthSSH   = "ssh"
+	GitAuthNone  = "none"
+)
+
+var gitRemoteName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// withDefaults fills empty fields from defaults and then from built-in
+// values.
+func (c GitHubConfig) withDefaults(defaults GitHubConfig) GitHubConfig {
+	for _, f := range []struct {
+		field             *string
+		fallback, builtin string
+	}{
+		{&c.Branch, defaults.Branch, "main"},
+		{&c.Remote, defaults.Remote, "origin"},
+		{&c.Auth, defaults.Auth, ""},
+		{&c.Username, defaults.Username, "x-access-token"},
+		{&c.SSHKey, defaults.SSHKey, ""},
+		{&c.AuthorName, defaults.AuthorName, syncAuthorName},
+		{&c.AuthorEmail, defaults.AuthorEmail, syncAuthorEmail},
+	} {
+		if *f.field == "" {
+			*f.field = f.fallback
+		}
+		if *f.field == "" {
+			*f.field = f.builtin
 		}
-		var config GitHubConfig
-		json.Unmarshal(data, &config)
-		writeJSON(w, config)
-		return
 	}
-	
-	// POST - save config
-	var config GitHubConfig
-	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		writeError(w, "invalid JSON", 400)
-		return
+	if c.Auth == "" {
+		switch {
+		case isSSHRemote(c.Repo):
+			c.Auth = GitAuthSSH
+		case c.Token != "":
+			c.Auth = GitAuthToken
+		default:
+			c.Auth = GitAuthNone
+		}
 	}
-	
-	if config.Branch == "" {
-		config.Branch = "main"
+	return c
+}
+
+// validate rejects settings that git would misread and remote URLs that
+// carry a password, since those end up in .git/config.
+func (c GitHubConfig) validate() error {
+	if c.Remote != "" && !gitRemoteName.MatchString(c.Remote) {
+		return fmt.Errorf("invalid remote name %q", c.Remote)
 	}
-	
-	// Load existing config to preserve token if not provided
-	existingData, _ := os.ReadFile(configPath)
-	var existing GitHubConfig
-	json.Unmarshal(existingData, &existing)
-	
-	// Keep existing token if new one not provided
-	if config.Token == "" {
-		config.Token = existing.Token
+	switch c.Auth {
+	case "", GitAuthToken, GitAuthSSH, GitAuthNone:
+	default:
+		return fmt.Errorf("auth must be %s, %s or %s", GitAuthToken, GitAuthSSH, GitAuthNone)
 	}
-	
-	// Save config
-	data, _ := json.MarshalIndent(config, "", "  ")
-	if err := os.WriteFile(configPath, data, 0600); err != nil {
-		writeError(w, "failed to save config: "+err.Error(), 500)
-		return
+	if u, err := url.Parse(c.Repo); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return fmt.Errorf("remove the credentials from the repository URL and set a token instead")
+		}
 	}
-	
-	writeJSON(w, map[string]string{"status": "ok", "message": "Configuration saved!"})
+	// The URL names a git config key for the credential helper, so it
+	// can't contain "=" or line breaks.
+	if strings.HasPrefix(c.Repo, "-") || strings.HasPrefix(c.Branch, "-") || strings.ContainsAny(c.Repo, "=\r\n") {
+		return fmt.Errorf("invalid repository or branch")
+	}
+	return nil
 }
 
-func (s *Server) HandleGitHubPull(w http.ResponseWriter, r *http.Request) {
-	projectRoot := getProjectRoot()
-	config := loadGitConfig()
-	
-	if config.Repo == "" {
-		writeError(w, "No repository configured. Please save configuration first.", 400)
-		return
+// sshKeyPath resolves an ssh_key from .github-config.json. Keys must be
+// files in dir, so the setting can't point git at arbitrary server files;
+// without a dir no key can be set there.
+func sshKeyPath(dir, key string) (string, error) {
+	if key == "" {
+		return "", nil
 	}
-	
-	branch := config.Branch
-	if branch == "" {
-		branch = "main"
+	if dir == "" {
+		return "", fmt.Errorf("ssh_key can't be set: no SSH key directory is configured")
 	}
-	
-	// Setup git remote with auth
-	if err := setupGitRemote(projectRoot, config); err != nil {
-		writeError(w, "Failed to setup remote: "+err.Error(), 500)
-		return
+	path := key
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
 	}
-	
-	// Git pull
-	cmd := exec.Command("git", "pull", "origin", branch)
-	cmd.Dir = projectRoot
-	output, err := cmd.CombinedOutput()
-	
-	if err != nil {
-		writeError(w, "Pull failed: "+string(output), 500)
-		return
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("ssh_key must be a file in %s", dir)
 	}
-	
-	writeJSON(w, map[string]string{"message": "Pull successful! " + string(output)})
+	return path, nil
 }
 
-func (s *Server) HandleGitHubPush(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Message string `json:"message"`
+// isSSHRemote reports whether repo is an ssh:// URL or scp-style
+// user@host:path.
+func isSSHRemote(repo string) bool {
+	if strings.HasPrefix(repo, "ssh://") || strings.HasPrefix(repo, "git+ssh://") {
+		return true
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, "invalid JSON", 400)
+	host, _, ok := strings.Cut(repo, ":")
+	return ok && !strings.Contains(repo, "://") && strings.Contains(host, "@") && !strings.Contains(host, "/")
+}
+
+func (s *Server) HandleGitHubConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		// Settings left empty are reported empty, so that saving the form
+		// doesn't pin the current defaults.
+		config, err := s.loadGitConfigFile(r.Context())
+		if err != nil {
+			writeError(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"repo":         config.Repo,
+			"branch":       config.Branch,
+			"remote":       config.Remote,
+			"auth":         config.Auth,
+			"username":     config.Username,
+			"ssh_key":      config.SSHKey,
+			"author_name":  config.AuthorName,
+			"author_email": config.AuthorEmail,
+			"has_token":    config.Token != "",
+			"token":        maskSecret(config.Token),
+		})
 		return
 	}
 	
-	if req.Message == "" {
-		req.Message = "Update bookmark manager"
-	}
-	
-	projectRoot := getProjectRoot()
-	config := loadGitConfig()
-	
-	if config.Repo == "" {
-		writeError(w, "No repository configured. Please save configuration first.", 400)
+	// POST - save config
+	var config GitHubConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeError(w, "invalid JSON", 400)
 		return
 	}
 	
-	if config.Token == "" {
-		writeError(w, "No access token configured. Please add your GitHub token.", 400)
+	if err := config.validate(); err != nil {
+		writeError(w, err.Error(), 400)
 		return
 	}
-	
-	branch := config.Branch
-	if branch == "" {
-		branch = "main"
-	}
-	
-	// Setup git remote with auth
-	if err := setupGitRemote(projectRoot, config); err != nil {
-		writeError(w, "Failed to setup remote: "+err.Error(), 500)
+	if _, err := sshKeyPath(s.SSHKeyDir, config.SSHKey); err != nil {
+		writeError(w, err.Error(), 400)
 		return
 	}
 	
-	// Git add all changes
-	cmd := exec.Command("git", "add", "-A")
-	cmd.Dir = projectRoot
-	if output, err := cmd.CombinedOutput(); err != nil {
-		writeError(w, "Git add failed: "+string(output), 500)
-		return
+	// A new token replaces the stored one; without one the old token is kept.
+	if config.Token != "" {
+		if err := s.Secrets.Set(r.Context(), SecretGitHubToken, config.Token); err != nil {
+			writeError(w, "failed to store token: "+err.Error(), 400)
+			return
+		}
+		config.Token = ""
+	} else if !s.Secrets.Unlocked() {
+		existing, _ := s.readGitConfigFile()
+		config.Token = existing.Token
 	}
 	
-	// Git commit
-	cmd = exec.Command("git", "commit", "-m", req.Message)
-	cmd.Dir = projectRoot
-	commitOutput, _ := cmd.CombinedOutput() // May fail if nothing to commit
-	
-	// Git push
-	cmd = exec.Command("git", "push", "-u", "origin", branch)
-	cmd.Dir = projectRoot
-	output, err := cmd.CombinedOutput()
-	
-	if err != nil {
-		writeError(w, "Push failed: "+string(output), 500)
+	if err := s.saveGitConfig(config); err != nil {
+		writeError(w, "failed to save config: "+err.Error(), 500)
 		return
 	}
 	
-	result := "Push successful!"
-	if strings.Contains(string(commitOutput), "nothing to commit") {
-		result = "Nothing new to commit. " + result
-	}
-	
-	writeJSON(w, map[string]string{"message": result})
+	writeJSON(w, map[string]string{"status": "ok", "message": "Configuration saved!"})
 }
 
-func setupGitRemote(projectRoot string, config GitHubConfig) error {
-	// Build authenticated URL
-	remoteURL := config.Repo
-	if config.Token != "" && strings.HasPrefix(remoteURL, "https://github.com") {
-		// Format: https://TOKEN@github.com/user/repo.git
-		remoteURL = strings.Replace(remoteURL, "https://github.com", "https://"+config.Token+"@github.com", 1)
-	}
-	
-	// Check if origin exists
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Dir = projectRoot
-	if err := cmd.Run(); err != nil {
-		// Add remote
-		cmd = exec.Command("git", "remote", "add", "origin", remoteURL)
-		cmd.Dir = projectRoot
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to add remote: %s", output)
+// setupGitRemote points repo's remote at config.Repo. Credentials are
+// never part of the URL; gitRepo supplies them when git asks.
+func setupGitRemote(ctx context.Context, repo gitRepo, config GitHubConfig) error {
+	current, err := repo.git(ctx, "remote", "get-url", config.Remote)
+	if err != nil {
+		if _, err := repo.git(ctx, "remote", "add", config.Remote, config.Repo); err != nil {
+			return fmt.Errorf("failed to add remote: %w", err)
 		}
-	} else {
-		// Update remote URL
-		cmd = exec.Command("git", "remote", "set-url", "origin", remoteURL)
-		cmd.Dir = projectRoot
-		if output, err := cmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("failed to update remote: %s", output)
+	} else if strings.TrimSpace(current) != config.Repo {
+		if _, err := repo.git(ctx, "remote", "set-url", config.Remote, config.Repo); err != nil {
+			return fmt.Errorf("failed to update remote: %w", err)
 		}
 	}
-	
 	return nil
 }
 
-func getProjectRoot() string {
-	return "/home/exedev/bookmark-manager"
+// loadGitConfig reads the repository settings and the token, with
+// defaults applied. A plaintext token left in the file by older versions
+// is moved into the secret store.
+func (s *Server) loadGitConfig(ctx context.Context) (GitHubConfig, error) {
+	config, err := s.loadGitConfigFile(ctx)
+	if err != nil {
+		return config, err
+	}
+	if config.SSHKey, err = sshKeyPath(s.SSHKeyDir, config.SSHKey); err != nil {
+		return config, err
+	}
+	return config.withDefaults(s.GitDefaults), nil
+}
+
+func (s *Server) loadGitConfigFile(ctx context.Context) (GitHubConfig, error) {
+	config, err := s.readGitConfigFile()
+	if err != nil {
+		config = GitHubConfig{}
+	}
+	if config.Token != "" {
+		if s.Secrets.Unlocked() {
+			if err := s.Secrets.Set(ctx, SecretGitHubToken, config.Token); err != nil {
+				return config, err
+			}
+			token := config.Token
+			config.Token = ""
+			if err := s.saveGitConfig(config); err != nil {
+				return config, err
+			}
+			config.Token = token
+		}
+		return config, nil
+	}
+	config.Token, err = s.Secrets.Get(ctx, SecretGitHubToken)
+	return config, err
 }
 
-func loadGitConfig() GitHubConfig {
-	configPath := filepath.Join(getProjectRoot(), gitConfigFile)
-	data, err := os.ReadFile(configPath)
+func (s *Server) readGitConfigFile() (GitHubConfig, error) {
+	data, err := os.ReadFile(filepath.Join(s.ProjectRoot, gitConfigFile))
 	if err != nil {
-		return GitHubConfig{Branch: "main"}
+		return GitHubConfig{}, err
 	}
 	var config GitHubConfig
-	json.Unmarshal(data, &config)
-	return config
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+func (s *Server) saveGitConfig(config GitHubConfig) error {
+	data, _ := json.MarshalIndent(config, "", "  ")
+	return os.WriteFile(filepath.Join(s.ProjectRoot, gitConfigFile), data, 0600)
 }
@@ -1,14 +1,16 @@
 package srv
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"srv.exe.dev/db/dbgen"
 )
@@ -27,11 +29,13 @@ func (s *Server) HandleListBookmarks(w http.ResponseWriter, r *http.Request) {
 	var err error
 	if source != "" {
 		bookmarks, err = q.ListBookmarksBySource(r.Context(), dbgen.ListBookmarksBySourceParams{
+			OwnerID:    ownerID(r.Context()),
 			SourceType: source, Limit: limit, Offset: offset,
 		})
 	} else {
 		bookmarks, err = q.ListBookmarks(r.Context(), dbgen.ListBookmarksParams{
-			Limit: limit, Offset: offset,
+			OwnerID: ownerID(r.Context()),
+			Limit:   limit, Offset: offset,
 		})
 	}
 	if err != nil {
@@ -60,6 +64,14 @@ func (s *Server) HandleCreateBookmark(w http.ResponseWriter, r *http.Request) {
 		writeError(w, "url is required", 400)
 		return
 	}
+	// Fetch the page for a missing title
+	var page *PageMetadata
+	if req.Title == "" {
+		page, _ = s.fetchPage(r.Context(), req.URL)
+	}
+	if req.Title == "" && page != nil {
+		req.Title = page.Title
+	}
 	// Default title to URL hostname if not provided
 	if req.Title == "" {
 		if u, err := url.Parse(req.URL); err == nil {
@@ -71,14 +83,15 @@ func (s *Server) HandleCreateBookmark(w http.ResponseWriter, r *http.Request) {
 	if req.SourceType == "" {
 		req.SourceType = detectSourceType(req.URL)
 	}
-	
-	// Auto-fetch preview image if not provided
-	if req.ImageURL == "" {
-		req.ImageURL = getPreviewImage(req.URL)
-	}
-
+	// Preview images and favicons are only served from the local caches.
+	// Filling those can take several downloads, so unless they are cached
+	// already it is left to a generate_metadata job.
 	q := dbgen.New(s.DB)
+	req.ImageURL = localImageURL(r.Context(), q, req.ImageURL)
+	req.FaviconURL = s.cachedFavicon(r.Context(), req.URL)
+
 	bookmark, err := q.CreateBookmark(r.Context(), dbgen.CreateBookmarkParams{
+		OwnerID:     ownerID(r.Context()),
 		Url:         req.URL,
 		Title:       req.Title,
 		Description: strPtr(req.Description),
@@ -95,15 +108,27 @@ func (s *Server) HandleCreateBookmark(w http.ResponseWriter, r *http.Request) {
 	// Add tags
 	for _, tagName := range req.Tags {
 		tag, err := q.CreateTag(r.Context(), dbgen.CreateTagParams{
-			Name: strings.TrimSpace(tagName), Color: strPtr("#6366f1"),
+			OwnerID: ownerID(r.Context()),
+			Name:    strings.TrimSpace(tagName), Color: strPtr("#6366f1"),
 		})
 		if err == nil {
 			q.AddTagToBookmark(r.Context(), dbgen.AddTagToBookmarkParams{
+				OwnerID:    ownerID(r.Context()),
 				BookmarkID: bookmark.ID, TagID: tag.ID,
 			})
 		}
 	}
 
+	if page != nil {
+		if err := s.saveBookmarkMetadata(r.Context(), bookmark.ID, page); err != nil {
+			slog.Warn("save page metadata", "bookmark", bookmark.ID, "error", err)
+		}
+	}
+
+	s.Events.Publish(r.Context(), EventBookmarkCreated, bookmark)
+	if req.ImageURL == "" || req.FaviconURL == "" {
+		s.queueMetadata(r.Context(), []int64{bookmark.ID})
+	}
 	w.WriteHeader(201)
 	writeJSON(w, bookmark)
 }
@@ -111,13 +136,17 @@ func (s *Server) HandleCreateBookmark(w http.ResponseWriter, r *http.Request) {
 func (s *Server) HandleGetBookmark(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	q := dbgen.New(s.DB)
-	bookmark, err := q.GetBookmark(r.Context(), id)
+	bookmark, err := q.GetBookmark(r.Context(), dbgen.GetBookmarkParams{OwnerID: ownerID(r.Context()), ID: id})
 	if err != nil {
 		writeError(w, "not found", 404)
 		return
 	}
-	tags, _ := q.GetBookmarkTags(r.Context(), id)
-	writeJSON(w, map[string]any{"bookmark": bookmark, "tags": tags})
+	tags, _ := q.GetBookmarkTags(r.Context(), dbgen.GetBookmarkTagsParams{OwnerID: ownerID(r.Context()), BookmarkID: id})
+	structured, oembed, err := s.loadBookmarkMetadata(r.Context(), id)
+	if err != nil {
+		slog.Warn("load page metadata", "bookmark", id, "error", err)
+	}
+	writeJSON(w, map[string]any{"bookmark": bookmark, "tags": tags, "structured": structured, "oembed": oembed})
 }
 
 func (s *Server) HandleUpdateBookmark(w http.ResponseWriter, r *http.Request) {
@@ -133,7 +162,8 @@ func (s *Server) HandleUpdateBookmark(w http.ResponseWriter, r *http.Request) {
 	}
 	q := dbgen.New(s.DB)
 	bookmark, err := q.UpdateBookmark(r.Context(), dbgen.UpdateBookmarkParams{
-		ID: id, Title: req.Title,
+		OwnerID: ownerID(r.Context()),
+		ID:      id, Title: req.Title,
 		Description: strPtr(req.Description),
 		Summary:     strPtr(req.Summary),
 	})
@@ -141,22 +171,24 @@ func (s *Server) HandleUpdateBookmark(w http.ResponseWriter, r *http.Request) {
 		writeError(w, err.Error(), 500)
 		return
 	}
+	s.Events.Publish(r.Context(), EventBookmarkUpdated, bookmark)
 	writeJSON(w, bookmark)
 }
 
 func (s *Server) HandleDeleteBookmark(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	q := dbgen.New(s.DB)
-	if err := q.DeleteBookmark(r.Context(), id); err != nil {
+	if err := q.DeleteBookmark(r.Context(), dbgen.DeleteBookmarkParams{OwnerID: ownerID(r.Context()), ID: id}); err != nil {
 		writeError(w, err.Error(), 500)
 		return
 	}
+	s.Events.Publish(r.Context(), EventBookmarkDeleted, map[string]int64{"id": id})
 	w.WriteHeader(204)
 }
 
 func (s *Server) HandleListTags(w http.ResponseWriter, r *http.Request) {
 	q := dbgen.New(s.DB)
-	tags, err := q.ListTags(r.Context())
+	tags, err := q.ListTags(r.Context(), ownerID(r.Context()))
 	if err != nil {
 		writeError(w, err.Error(), 500)
 		return
@@ -174,7 +206,7 @@ func (s *Server) HandleCreateTag(w http.ResponseWriter, r *http.Request) {
 		req.Color = "#6366f1"
 	}
 	q := dbgen.New(s.DB)
-	tag, err := q.CreateTag(r.Context(), dbgen.CreateTagParams{Name: req.Name, Color: &req.Color})
+	tag, err := q.CreateTag(r.Context(), dbgen.CreateTagParams{OwnerID: ownerID(r.Context()), Name: req.Name, Color: &req.Color})
 	if err != nil {
 		writeError(w, err.Error(), 500)
 		return
@@ -185,7 +217,7 @@ func (s *Server) HandleCreateTag(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) HandleListCollections(w http.ResponseWriter, r *http.Request) {
 	q := dbgen.New(s.DB)
-	collections, err := q.ListCollections(r.Context())
+	collections, err := q.ListCollections(r.Context(), ownerID(r.Context()))
 	if err != nil {
 		writeError(w, err.Error(), 500)
 		return
@@ -205,7 +237,8 @@ func (s *Server) HandleCreateCollection(w http.ResponseWriter, r *http.Request)
 	}
 	q := dbgen.New(s.DB)
 	col, err := q.CreateCollection(r.Context(), dbgen.CreateCollectionParams{
-		Name: req.Name, Description: strPtr(req.Description), Icon: strPtr(req.Icon),
+		OwnerID: ownerID(r.Context()),
+		Name:    req.Name, Description: strPtr(req.Description), Icon: strPtr(req.Icon),
 	})
 	if err != nil {
 		writeError(w, err.Error(), 500)
@@ -226,6 +259,7 @@ func (s *Server) HandleGetCollectionBookmarks(w http.ResponseWriter, r *http.Req
 	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	q := dbgen.New(s.DB)
 	bookmarks, err := q.GetBookmarksInCollection(r.Context(), dbgen.GetBookmarksInCollectionParams{
+		OwnerID:      ownerID(r.Context()),
 		CollectionID: id,
 		Limit:        1000,
 		Offset:       0,
@@ -246,6 +280,7 @@ func (s *Server) HandleAddBookmarksToCollection(w http.ResponseWriter, r *http.R
 	q := dbgen.New(s.DB)
 	for _, bid := range req.BookmarkIDs {
 		q.AddBookmarkToCollection(r.Context(), dbgen.AddBookmarkToCollectionParams{
+			OwnerID:      ownerID(r.Context()),
 			BookmarkID:   bid,
 			CollectionID: id,
 		})
@@ -263,6 +298,7 @@ func (s *Server) HandleRemoveBookmarksFromCollection(w http.ResponseWriter, r *h
 	q := dbgen.New(s.DB)
 	for _, bid := range req.BookmarkIDs {
 		q.RemoveBookmarkFromCollection(r.Context(), dbgen.RemoveBookmarkFromCollectionParams{
+			OwnerID:      ownerID(r.Context()),
 			BookmarkID:   bid,
 			CollectionID: id,
 		})
@@ -278,96 +314,189 @@ func (s *Server) HandleBulkUpdateBookmarks(w http.ResponseWriter, r *http.Reques
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 	
+	q := dbgen.New(s.DB)
 	for _, bid := range req.BookmarkIDs {
-		s.DB.ExecContext(r.Context(), "UPDATE bookmarks SET source_type = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", req.SourceType, bid)
+		s.DB.ExecContext(r.Context(), "UPDATE bookmarks SET source_type = ?, updated_at = CURRENT_TIMESTAMP WHERE owner_id = ? AND id = ?", req.SourceType, ownerID(r.Context()), bid)
+		if b, err := q.GetBookmark(r.Context(), dbgen.GetBookmarkParams{OwnerID: ownerID(r.Context()), ID: bid}); err == nil {
+			s.Events.Publish(r.Context(), EventBookmarkUpdated, b)
+		}
 	}
 	w.WriteHeader(200)
 	writeJSON(w, map[string]string{"status": "ok"})
 }
 
+// HandleGenerateAllMetadata enqueues a generate_all job that fans out into
+// one generate_metadata job per bookmark missing a preview image or summary.
+// Poll GET /api/jobs/{id} for progress.
 func (s *Server) HandleGenerateAllMetadata(w http.ResponseWriter, r *http.Request) {
-	q := dbgen.New(s.DB)
-	
-	// Get all bookmarks
-	bookmarks, err := q.ListBookmarks(r.Context(), dbgen.ListBookmarksParams{
-		Limit: 1000, Offset: 0,
-	})
+	job, err := s.Jobs.Enqueue(r.Context(), JobGenerateAll, struct{}{}, nil)
 	if err != nil {
 		writeError(w, err.Error(), 500)
 		return
 	}
-	
-	updated := 0
-	for _, b := range bookmarks {
-		needsUpdate := false
-		newImageURL := b.ImageUrl
-		newSummary := b.Summary
-		
-		// Check if preview image is missing
-		if b.ImageUrl == nil || *b.ImageUrl == "" {
-			img := getPreviewImage(b.Url)
-			if img != "" {
-				newImageURL = &img
-				needsUpdate = true
-			}
+	w.WriteHeader(202)
+	writeJSON(w, map[string]any{"job_id": job.ID, "status": job.Status})
+}
+
+// generateBookmarkMetadata fills in a missing summary and moves the
+// preview image and favicon to the local caches. What could be fetched is
+// saved even if the summary step fails, so a retry only redoes the missing
+// parts.
+func (s *Server) generateBookmarkMetadata(ctx context.Context, id int64) (bool, error) {
+	b, err := dbgen.New(s.DB).GetBookmark(ctx, dbgen.GetBookmarkParams{OwnerID: ownerID(ctx), ID: id})
+	if err != nil {
+		return false, err
+	}
+
+	needsUpdate := false
+	newImageURL := b.ImageUrl
+	newSummary := b.Summary
+	var newFaviconURL *string
+	needsImage := b.ImageUrl == nil || !strings.HasPrefix(*b.ImageUrl, "/thumbnails/")
+	needsSummary := b.Summary == nil || *b.Summary == ""
+	needsFavicon := b.FaviconUrl == nil || !strings.HasPrefix(*b.FaviconUrl, "/favicons/")
+	if !needsImage && !needsSummary && !needsFavicon {
+		return false, nil
+	}
+
+	// The page is fetched once for both.
+	page, fetchErr := s.fetchPage(ctx, b.Url)
+	if fetchErr == nil {
+		if err := s.saveBookmarkMetadata(ctx, id, page); err != nil {
+			slog.Warn("save page metadata", "bookmark", id, "error", err)
+		}
+	}
+	if needsImage {
+		// An image set elsewhere, by an import for instance, is kept if
+		// it can be downloaded.
+		var images []string
+		if b.ImageUrl != nil && *b.ImageUrl != "" {
+			images = append(images, *b.ImageUrl)
 		}
-		
-		// Check if summary is missing
-		if b.Summary == nil || *b.Summary == "" {
-			analysis, err := analyzeURL(b.Url)
-			if err == nil && analysis.Summary != "" {
-				newSummary = &analysis.Summary
-				needsUpdate = true
-			}
+		if fetchErr == nil {
+			images = append(images, page.Images...)
 		}
-		
-		if needsUpdate {
-			// Update the bookmark
-			_, err := s.DB.ExecContext(r.Context(), `
-				UPDATE bookmarks SET 
-					image_url = COALESCE(?, image_url),
-					summary = COALESCE(?, summary),
-					updated_at = CURRENT_TIMESTAMP
-				WHERE id = ?`,
-				newImageURL, newSummary, b.ID)
-			if err == nil {
-				updated++
-			}
+		if img := s.previewImage(ctx, b.Url, images); img != "" {
+			newImageURL = &img
+			needsUpdate = true
+		} else if b.ImageUrl != nil && *b.ImageUrl != "" && ctx.Err() == nil {
+			// A third-party image that can't be cached isn't shown.
+			newImageURL = new(string)
+			needsUpdate = true
 		}
 	}
-	
-	writeJSON(w, map[string]any{
-		"total":   len(bookmarks),
-		"updated": updated,
-	})
+	if needsFavicon {
+		cached := page
+		if fetchErr != nil {
+			cached = nil
+		}
+		newFaviconURL = strPtr(s.cacheFavicon(ctx, b.Url, cached))
+		needsUpdate = needsUpdate || newFaviconURL != nil
+	}
+
+	var analyzeErr error
+	if needsSummary && ctx.Err() == nil {
+		if fetchErr != nil {
+			analyzeErr = fmt.Errorf("analyze %s: %w", b.Url, fetchErr)
+		} else if analysis := s.analyzePage(ctx, page); analysis.Summary != "" {
+			newSummary = &analysis.Summary
+			needsUpdate = true
+		}
+	}
+
+	if needsUpdate {
+		_, err := s.DB.ExecContext(ctx, `
+			UPDATE bookmarks SET 
+				image_url = NULLIF(COALESCE(?, image_url), ''),
+				summary = COALESCE(?, summary),
+				favicon_url = COALESCE(?, favicon_url),
+				updated_at = CURRENT_TIMESTAMP
+			WHERE owner_id = ? AND id = ?`,
+			newImageURL, newSummary, newFaviconURL, ownerID(ctx), b.ID)
+		if err != nil {
+			return false, err
+		}
+		if updated, err := dbgen.New(s.DB).GetBookmark(ctx, dbgen.GetBookmarkParams{OwnerID: ownerID(ctx), ID: id}); err == nil {
+			s.Events.Publish(ctx, EventBookmarkUpdated, updated)
+		}
+		if err := s.embedBookmark(ctx, id); err != nil {
+			slog.Warn("embeddings: failed", "bookmark", id, "error", err)
+		}
+	}
+	return needsUpdate, analyzeErr
 }
 
+// queueMetadata enqueues a generate_metadata job for each bookmark. Imports
+// use it for bookmarks whose preview image couldn't be kept.
+func (s *Server) queueMetadata(ctx context.Context, ids []int64) {
+	for _, id := range ids {
+		if _, err := s.Jobs.Enqueue(ctx, JobGenerateMetadata, map[string]int64{"bookmark_id": id}, nil); err != nil {
+			slog.Warn("queue metadata", "bookmark", id, "error", err)
+		}
+	}
+}
+
+// HandleAnalyzeBookmark analyzes a bookmark and returns the result. With
+// ?async=1 it enqueues an analyze_bookmark job instead and returns its ID.
 func (s *Server) HandleAnalyzeBookmark(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		if _, err := dbgen.New(s.DB).GetBookmark(r.Context(), dbgen.GetBookmarkParams{OwnerID: ownerID(r.Context()), ID: id}); err != nil {
+			writeError(w, "bookmark not found", 404)
+			return
+		}
+		job, err := s.Jobs.Enqueue(r.Context(), JobAnalyzeBookmark, map[string]int64{"bookmark_id": id}, nil)
+		if err != nil {
+			writeError(w, err.Error(), 500)
+			return
+		}
+		w.WriteHeader(202)
+		writeJSON(w, map[string]any{"job_id": job.ID, "status": job.Status})
+		return
+	}
+
+	updated, keywords, err := s.analyzeBookmark(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, "bookmark not found", 404)
+		return
+	}
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"bookmark": updated,
+		"keywords": keywords,
+	})
+}
+
+func (s *Server) analyzeBookmark(ctx context.Context, id int64) (dbgen.Bookmark, []string, error) {
 	q := dbgen.New(s.DB)
 	
-	bookmark, err := q.GetBookmark(r.Context(), id)
+	bookmark, err := q.GetBookmark(ctx, dbgen.GetBookmarkParams{OwnerID: ownerID(ctx), ID: id})
 	if err != nil {
-		writeError(w, "bookmark not found", 404)
-		return
+		return dbgen.Bookmark{}, nil, err
 	}
 	
-	analysis, err := analyzeURL(bookmark.Url)
+	analysis, err := s.analyzeURL(ctx, bookmark.Url)
 	if err != nil {
-		writeError(w, "failed to analyze: "+err.Error(), 500)
-		return
+		return dbgen.Bookmark{}, nil, fmt.Errorf("failed to analyze: %w", err)
 	}
 	
 	// Update bookmark with analysis
 	keywordsJSON, _ := json.Marshal(analysis.Keywords)
-	updated, err := q.UpdateBookmarkAnalysis(r.Context(), dbgen.UpdateBookmarkAnalysisParams{
+	updated, err := q.UpdateBookmarkAnalysis(ctx, dbgen.UpdateBookmarkAnalysisParams{
+		OwnerID:  ownerID(ctx),
 		ID:       id,
 		Summary:  &analysis.Summary,
 		Keywords: strPtr(string(keywordsJSON)),
 	})
 	if err != nil {
-		writeError(w, "failed to save: "+err.Error(), 500)
-		return
+		return dbgen.Bookmark{}, nil, fmt.Errorf("failed to save: %w", err)
+	}
+	if err := s.saveBookmarkMetadata(ctx, id, analysis.page); err != nil {
+		slog.Warn("save page metadata", "bookmark", id, "error", err)
 	}
 	
 	// If title is empty or just hostname, generate from summary
@@ -377,7 +506,10 @@ func (s *Server) HandleAnalyzeBookmark(w http.ResponseWriter, r *http.Request) {
 			needsTitle = bookmark.Title == u.Host
 		}
 	}
-	if needsTitle && analysis.Summary != "" {
+	if needsTitle && analysis.page.Title != "" {
+		s.DB.ExecContext(ctx, "UPDATE bookmarks SET title = ? WHERE owner_id = ? AND id = ?", analysis.page.Title, ownerID(ctx), id)
+		updated.Title = analysis.page.Title
+	} else if needsTitle && analysis.Summary != "" {
 		// Generate a short title from summary (first sentence, max 60 chars)
 		title := analysis.Summary
 		if idx := strings.Index(title, "."); idx > 0 && idx < 80 {
@@ -385,14 +517,22 @@ func (s *Server) HandleAnalyzeBookmark(w http.ResponseWriter, r *http.Request) {
 		} else if len(title) > 60 {
 			title = title[:57] + "..."
 		}
-		s.DB.ExecContext(r.Context(), "UPDATE bookmarks SET title = ? WHERE id = ?", title, id)
+		s.DB.ExecContext(ctx, "UPDATE bookmarks SET title = ? WHERE owner_id = ? AND id = ?", title, ownerID(ctx), id)
 		updated.Title = title
 	}
 	
-	writeJSON(w, map[string]any{
-		"bookmark": updated,
-		"keywords": analysis.Keywords,
-	})
+	if opts := s.autoTagOptions(); opts.Enabled {
+		if _, err := s.autoTagBookmark(ctx, updated, analysis, opts); err != nil {
+			slog.Warn("autotag: failed", "bookmark", id, "error", err)
+		}
+	}
+	if err := s.embedBookmark(ctx, id); err != nil {
+		slog.Warn("embeddings: failed", "bookmark", id, "error", err)
+	}
+
+	s.Events.Publish(ctx, EventBookmarkUpdated, updated)
+	
+	return updated, analysis.Keywords, nil
 }
 
 func detectSourceType(url string) string {
@@ -408,90 +548,12 @@ func detectSourceType(url string) string {
 	return "web"
 }
 
-// getPreviewImage fetches og:image or other preview image for a URL
-func getPreviewImage(pageURL string) string {
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", pageURL, nil)
-	if err != nil {
-		return getScreenshotService(pageURL)
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	
-	resp, err := client.Do(req)
-	if err != nil {
-		return getScreenshotService(pageURL)
-	}
-	defer resp.Body.Close()
-	
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 100000)) // 100KB should be enough for meta tags
-	html := string(body)
-	
-	// Try og:image first (most reliable for preview)
-	ogImage := extractMeta(html, "og:image")
-	if ogImage != "" {
-		return makeAbsoluteURL(ogImage, pageURL)
-	}
-	
-	// Try twitter:image
-	twitterImage := extractMeta(html, "twitter:image")
-	if twitterImage != "" {
-		return makeAbsoluteURL(twitterImage, pageURL)
-	}
-	
-	// Try twitter:image:src
-	twitterImageSrc := extractMeta(html, "twitter:image:src")
-	if twitterImageSrc != "" {
-		return makeAbsoluteURL(twitterImageSrc, pageURL)
-	}
-	
-	// Fallback to screenshot service
-	return getScreenshotService(pageURL)
-}
-
-// extractMeta extracts content from meta tags
-func extractMeta(html, property string) string {
-	// Try property attribute
-	patterns := []string{
-		`(?i)<meta[^>]+property=["']` + property + `["'][^>]+content=["']([^"']+)["']`,
-		`(?i)<meta[^>]+content=["']([^"']+)["'][^>]+property=["']` + property + `["']`,
-		`(?i)<meta[^>]+name=["']` + property + `["'][^>]+content=["']([^"']+)["']`,
-		`(?i)<meta[^>]+content=["']([^"']+)["'][^>]+name=["']` + property + `["']`,
-	}
-	
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		if m := re.FindStringSubmatch(html); len(m) > 1 {
-			return strings.TrimSpace(m[1])
-		}
-	}
-	return ""
-}
-
-// makeAbsoluteURL converts relative URLs to absolute
-func makeAbsoluteURL(imgURL, pageURL string) string {
-	if strings.HasPrefix(imgURL, "http://") || strings.HasPrefix(imgURL, "https://") {
-		return imgURL
-	}
-	
-	parsed, err := url.Parse(pageURL)
-	if err != nil {
-		return imgURL
-	}
-	
-	if strings.HasPrefix(imgURL, "//") {
-		return parsed.Scheme + ":" + imgURL
-	}
-	
-	if strings.HasPrefix(imgURL, "/") {
-		return parsed.Scheme + "://" + parsed.Host + imgURL
-	}
-	
-	return parsed.Scheme + "://" + parsed.Host + "/" + imgURL
-}
+// screenshotService is prefixed to a page URL to get a screenshot of it.
+var screenshotService = "https://image.thum.io/get/width/600/crop/400/"
 
 // getScreenshotService returns a URL for a screenshot/thumbnail service
 func getScreenshotService(pageURL string) string {
 	// Use thumbnail.ws or similar service for page screenshots
 	// This provides a visual preview of the page
-	return "https://image.thum.io/get/width/600/crop/400/" + pageURL
+	return screenshotService + pageURL
 }
@@ -112,12 +112,17 @@ func (s *Server) HandleInstagramImport(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save bookmarks
+	progress := s.newProgress(r, "instagram_import")
+	defer progress.done()
+	progress.found(len(urls))
 	q := dbgen.New(s.DB)
 	saved := 0
 	for i, url := range urls {
+		progress.item(url)
 		// Check if already exists
-		_, err := q.GetBookmarkByURL(r.Context(), url)
+		_, err := q.GetBookmarkByURL(r.Context(), dbgen.GetBookmarkByURLParams{OwnerID: ownerID(r.Context()), Url: url})
 		if err == nil {
+			progress.skipped()
 			continue
 		}
 
@@ -126,20 +131,26 @@ func (s *Server) HandleInstagramImport(w http.ResponseWriter, r *http.Request) {
 			title = titles[i]
 		}
 
-		_, err = q.CreateBookmark(r.Context(), dbgen.CreateBookmarkParams{
+		bookmark, err := q.CreateBookmark(r.Context(), dbgen.CreateBookmarkParams{
+			OwnerID:    ownerID(r.Context()),
 			Url:        url,
 			Title:      title,
 			SourceType: "instagram",
 		})
-		if err == nil {
-			saved++
+		if err != nil {
+			progress.failed()
+			continue
 		}
+		saved++
+		progress.saved()
+		s.Events.Publish(r.Context(), EventBookmarkCreated, bookmark)
 	}
 
 	writeJSON(w, map[string]any{
-		"found":   len(urls),
-		"saved":   saved,
-		"skipped": len(urls) - saved,
+		"found":        len(urls),
+		"saved":        saved,
+		"skipped":      len(urls) - saved,
+		"operation_id": progress.OperationID,
 	})
 }
 
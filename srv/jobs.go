@@ -0,0 +1,431 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Job kinds
+const (
+	JobAnalyzeBookmark  = "analyze_bookmark"
+	JobGenerateMetadata = "generate_metadata"
+	JobGenerateAll      = "generate_all"
+	JobEmbedAll         = "embed_all"
+)
+
+// JobHandler runs one attempt of a job. The returned result is stored as
+// JSON; a returned error schedules a retry until max_attempts is reached.
+type JobHandler func(ctx context.Context, job dbgen.Job) (any, error)
+
+// JobQueue runs jobs stored in the jobs table on a pool of worker
+// goroutines. Jobs survive restarts: anything left running when the
+// process stopped is requeued on Start.
+type JobQueue struct {
+	DB           *sql.DB
+	PollInterval time.Duration
+	// Backoff is the delay before the first retry; it doubles per attempt.
+	Backoff     time.Duration
+	MaxAttempts int64
+	// Events, if set, receives progress events as jobs finish.
+	Events *EventHub
+
+	handlers map[string]JobHandler
+	wake     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	stopping chan struct{} // closed by Drain
+
+	mu      sync.Mutex
+	running map[int64]context.CancelFunc
+}
+
+func NewJobQueue(db *sql.DB) *JobQueue {
+	return &JobQueue{
+		DB:           db,
+		PollInterval: 2 * time.Second,
+		Backoff:      10 * time.Second,
+		MaxAttempts:  3,
+		handlers:     make(map[string]JobHandler),
+		wake:         make(chan struct{}, 1),
+		stopping:     make(chan struct{}),
+		running:      make(map[int64]context.CancelFunc),
+	}
+}
+
+// Handle registers the handler for a job kind. It must be called before Start.
+func (jq *JobQueue) Handle(kind string, h JobHandler) {
+	jq.handlers[kind] = h
+}
+
+// Start requeues interrupted jobs and launches n workers that run until ctx
+// is cancelled. Wait blocks until they have all stopped.
+func (jq *JobQueue) Start(ctx context.Context, n int) error {
+	requeued, err := dbgen.New(jq.DB).RequeueRunningJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("requeue interrupted jobs: %w", err)
+	}
+	if requeued > 0 {
+		slog.Info("jobs: requeued interrupted jobs", "count", requeued)
+	}
+	for i := 0; i < n; i++ {
+		jq.wg.Add(1)
+		go jq.worker(ctx)
+	}
+	return nil
+}
+
+// Wait blocks until all workers have exited.
+func (jq *JobQueue) Wait() {
+	jq.wg.Wait()
+}
+
+// Drain stops workers from claiming new jobs and waits for the running
+// ones to finish. If ctx ends first it returns ctx.Err(); cancelling the
+// context passed to Start then interrupts the remaining jobs, which are
+// requeued.
+func (jq *JobQueue) Drain(ctx context.Context) error {
+	jq.stopOnce.Do(func() { close(jq.stopping) })
+	done := make(chan struct{})
+	go func() {
+		jq.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Enqueue adds a job and wakes an idle worker.
+func (jq *JobQueue) Enqueue(ctx context.Context, kind string, payload any, parentID *int64) (dbgen.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return dbgen.Job{}, fmt.Errorf("marshal job payload: %w", err)
+	}
+	job, err := dbgen.New(jq.DB).CreateJob(ctx, dbgen.CreateJobParams{
+		OwnerID:  ownerID(ctx),
+		ParentID: parentID, Kind: kind, Payload: string(data), MaxAttempts: jq.MaxAttempts,
+	})
+	if err != nil {
+		return dbgen.Job{}, err
+	}
+	select {
+	case jq.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Cancel marks a job and its queued or running children as cancelled and
+// interrupts any of them that are currently running. Only the current
+// user's jobs are touched: running jobs are interrupted only once their
+// cancellation has been recorded, since run leaves the status to Cancel.
+func (jq *JobQueue) Cancel(ctx context.Context, id int64) (bool, error) {
+	q := dbgen.New(jq.DB)
+	// Listed first: once cancelled, the children are no longer running.
+	children, err := q.ListRunningChildJobIDs(ctx, dbgen.ListRunningChildJobIDsParams{OwnerID: ownerID(ctx), ParentID: &id})
+	if err != nil {
+		return false, err
+	}
+	n, err := q.CancelJob(ctx, dbgen.CancelJobParams{OwnerID: ownerID(ctx), ID: id})
+	if err != nil || n == 0 {
+		return false, err
+	}
+	jq.mu.Lock()
+	for _, jobID := range append(children, id) {
+		if cancel, ok := jq.running[jobID]; ok {
+			cancel()
+		}
+	}
+	jq.mu.Unlock()
+	return true, nil
+}
+
+func (jq *JobQueue) worker(ctx context.Context) {
+	defer jq.wg.Done()
+	q := dbgen.New(jq.DB)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-jq.stopping:
+			return
+		default:
+		}
+		job, err := q.ClaimJob(ctx)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+				slog.Warn("jobs: claim", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-jq.stopping:
+				return
+			case <-jq.wake:
+			case <-time.After(jq.PollInterval):
+			}
+			continue
+		}
+		jq.run(ctx, job)
+	}
+}
+
+func (jq *JobQueue) run(ctx context.Context, job dbgen.Job) {
+	// Status updates use a context that outlives shutdown so an interrupted
+	// job can still be put back in the queue.
+	// Handlers act as the user who enqueued the job.
+	ctx = withOwner(ctx, job.OwnerID)
+	q := dbgen.New(jq.DB)
+	bg := context.WithoutCancel(ctx)
+
+	handler, ok := jq.handlers[job.Kind]
+	if !ok {
+		msg := "unknown job kind " + job.Kind
+		q.FailJob(bg, dbgen.FailJobParams{ID: job.ID, Error: &msg})
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	jq.mu.Lock()
+	jq.running[job.ID] = cancel
+	jq.mu.Unlock()
+	defer func() {
+		jq.mu.Lock()
+		delete(jq.running, job.ID)
+		jq.mu.Unlock()
+		cancel()
+	}()
+
+	result, err := runJobHandler(jobCtx, handler, job)
+	switch {
+	case err == nil:
+		data, _ := json.Marshal(result)
+		q.CompleteJob(bg, dbgen.CompleteJobParams{ID: job.ID, Result: strPtr(string(data))})
+		jq.Progress(bg, job, "saved", "")
+	case ctx.Err() != nil:
+		// Shutting down: try again after restart.
+		q.RequeueJob(bg, job.ID)
+	case jobCtx.Err() != nil:
+		// Cancelled; CancelJob already recorded the status.
+		jq.Progress(bg, job, "skipped", "")
+	case job.Attempts < job.MaxAttempts:
+		msg := err.Error()
+		delay := jq.Backoff * time.Duration(1<<(job.Attempts-1))
+		q.RetryJob(bg, dbgen.RetryJobParams{
+			ID: job.ID, Error: &msg, Delay: fmt.Sprintf("+%.3f seconds", delay.Seconds()),
+		})
+		slog.Info("jobs: retrying", "id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "delay", delay, "error", err)
+	default:
+		msg := err.Error()
+		q.FailJob(bg, dbgen.FailJobParams{ID: job.ID, Error: &msg})
+		jq.Progress(bg, job, "failed", "")
+		slog.Warn("jobs: failed", "id", job.ID, "kind", job.Kind, "error", err)
+	}
+}
+
+// Progress publishes a progress event for job. Child jobs report on behalf
+// of their parent, with counts taken from all of the parent's children, so
+// a batch such as generate_all appears as a single operation.
+func (jq *JobQueue) Progress(ctx context.Context, job dbgen.Job, stage, current string) {
+	if jq.Events == nil {
+		return
+	}
+	id := job.ID
+	if job.ParentID != nil {
+		id = *job.ParentID
+	}
+	st, err := jq.Status(ctx, id)
+	if err != nil {
+		return
+	}
+	p := Progress{
+		Operation:   st.Kind,
+		OperationID: strconv.FormatInt(id, 10),
+		Stage:       stage,
+		Current:     current,
+		Done:        st.Done,
+	}
+	if st.Children != nil {
+		for status, n := range st.Children {
+			p.Found += int(n)
+			switch status {
+			case "succeeded":
+				p.Saved = int(n)
+			case "failed":
+				p.Failed = int(n)
+			case "cancelled":
+				p.Skipped = int(n)
+			}
+		}
+	} else {
+		p.Found = 1
+		switch st.Status {
+		case "succeeded":
+			p.Saved = 1
+		case "failed":
+			p.Failed = 1
+		case "cancelled":
+			p.Skipped = 1
+		}
+	}
+	jq.Events.Publish(ctx, EventProgress, p)
+}
+
+func runJobHandler(ctx context.Context, h JobHandler, job dbgen.Job) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// JobStatus is the API view of a job. Children counts child jobs by status
+// for batch jobs such as generate_all.
+type JobStatus struct {
+	dbgen.Job
+	Payload  json.RawMessage  `json:"payload"`
+	Result   json.RawMessage  `json:"result,omitempty"`
+	Children map[string]int64 `json:"children,omitempty"`
+	Done     bool             `json:"done"`
+}
+
+func (jq *JobQueue) Status(ctx context.Context, id int64) (*JobStatus, error) {
+	q := dbgen.New(jq.DB)
+	job, err := q.GetJob(ctx, dbgen.GetJobParams{OwnerID: ownerID(ctx), ID: id})
+	if err != nil {
+		return nil, err
+	}
+	st := &JobStatus{Job: job, Payload: json.RawMessage(job.Payload)}
+	if job.Result != nil {
+		st.Result = json.RawMessage(*job.Result)
+	}
+	st.Done = job.Status == "succeeded" || job.Status == "failed" || job.Status == "cancelled"
+
+	counts, err := q.CountChildJobsByStatus(ctx, &id)
+	if err != nil {
+		return nil, err
+	}
+	if len(counts) > 0 {
+		st.Children = make(map[string]int64)
+		for _, c := range counts {
+			st.Children[c.Status] = c.Count
+			if c.Status == "queued" || c.Status == "running" {
+				st.Done = false
+			}
+		}
+	}
+	return st, nil
+}
+
+func (s *Server) HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := dbgen.New(s.DB).ListJobs(r.Context(), dbgen.ListJobsParams{OwnerID: ownerID(r.Context()), Limit: 50})
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, jobs)
+}
+
+func (s *Server) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	st, err := s.Jobs.Status(r.Context(), id)
+	if err != nil {
+		writeError(w, "not found", 404)
+		return
+	}
+	writeJSON(w, st)
+}
+
+func (s *Server) HandleCancelJob(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	cancelled, err := s.Jobs.Cancel(r.Context(), id)
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	if !cancelled {
+		writeError(w, "job not found or already finished", 409)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "cancelled"})
+}
+
+// registerJobHandlers wires the job kinds the server knows how to run.
+func (s *Server) registerJobHandlers() {
+	s.Jobs.Handle(JobAnalyzeBookmark, func(ctx context.Context, job dbgen.Job) (any, error) {
+		var p struct {
+			BookmarkID int64 `json:"bookmark_id"`
+		}
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			return nil, err
+		}
+		if b, err := dbgen.New(s.DB).GetBookmark(ctx, dbgen.GetBookmarkParams{OwnerID: ownerID(ctx), ID: p.BookmarkID}); err == nil {
+			s.Jobs.Progress(ctx, job, "item", b.Url)
+		}
+		bookmark, keywords, err := s.analyzeBookmark(ctx, p.BookmarkID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"bookmark": bookmark, "keywords": keywords}, nil
+	})
+
+	s.Jobs.Handle(JobGenerateMetadata, func(ctx context.Context, job dbgen.Job) (any, error) {
+		var p struct {
+			BookmarkID int64 `json:"bookmark_id"`
+		}
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			return nil, err
+		}
+		if b, err := dbgen.New(s.DB).GetBookmark(ctx, dbgen.GetBookmarkParams{OwnerID: ownerID(ctx), ID: p.BookmarkID}); err == nil {
+			s.Jobs.Progress(ctx, job, "item", b.Url)
+		}
+		updated, err := s.generateBookmarkMetadata(ctx, p.BookmarkID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"bookmark_id": p.BookmarkID, "updated": updated}, nil
+	})
+
+	s.Jobs.Handle(JobGenerateAll, func(ctx context.Context, job dbgen.Job) (any, error) {
+		ids, err := dbgen.New(s.DB).ListBookmarkIDsMissingMetadata(ctx, ownerID(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if _, err := s.Jobs.Enqueue(ctx, JobGenerateMetadata, map[string]int64{"bookmark_id": id}, &job.ID); err != nil {
+				return nil, err
+			}
+		}
+		return map[string]int{"total": len(ids)}, nil
+	})
+
+	s.Jobs.Handle(JobEmbedAll, func(ctx context.Context, job dbgen.Job) (any, error) {
+		ids, err := dbgen.New(s.DB).ListBookmarkIDsMissingEmbedding(ctx, dbgen.ListBookmarkIDsMissingEmbeddingParams{OwnerID: ownerID(ctx), Model: s.Embedder.Model()})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if err := s.embedBookmark(ctx, id); err != nil {
+				return nil, err
+			}
+		}
+		return map[string]any{"model": s.Embedder.Model(), "embedded": len(ids)}, nil
+	})
+}
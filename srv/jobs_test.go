@@ -0,0 +1,125 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestJobQueue(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "jobs.sqlite3"), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	jq := NewJobQueue(server.DB)
+	jq.PollInterval = 10 * time.Millisecond
+	jq.Backoff = 10 * time.Millisecond
+
+	var flakyCalls atomic.Int32
+	jq.Handle("ok", func(ctx context.Context, job dbgen.Job) (any, error) {
+		return map[string]string{"echo": job.Payload}, nil
+	})
+	jq.Handle("flaky", func(ctx context.Context, job dbgen.Job) (any, error) {
+		if flakyCalls.Add(1) < 2 {
+			return nil, errors.New("temporary")
+		}
+		return "ok", nil
+	})
+	jq.Handle("broken", func(ctx context.Context, job dbgen.Job) (any, error) {
+		return nil, errors.New("permanent")
+	})
+	started := make(chan struct{})
+	var interrupted atomic.Bool
+	jq.Handle("block", func(ctx context.Context, job dbgen.Job) (any, error) {
+		close(started)
+		<-ctx.Done()
+		interrupted.Store(true)
+		return nil, ctx.Err()
+	})
+	if err := jq.Start(ctx, 2); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() {
+		cancel()
+		jq.Wait()
+	}()
+
+	waitDone := func(id int64) *JobStatus {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			st, err := jq.Status(ctx, id)
+			if err != nil {
+				t.Fatalf("status: %v", err)
+			}
+			if st.Done {
+				return st
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("job %d did not finish", id)
+		return nil
+	}
+
+	okJob, _ := jq.Enqueue(ctx, "ok", map[string]int{"n": 1}, nil)
+	flaky, _ := jq.Enqueue(ctx, "flaky", nil, nil)
+	broken, _ := jq.Enqueue(ctx, "broken", nil, nil)
+
+	if st := waitDone(okJob.ID); st.Status != "succeeded" || string(st.Result) != `{"echo":"{\"n\":1}"}` {
+		t.Errorf("ok job: status %s result %s", st.Status, st.Result)
+	}
+	if st := waitDone(flaky.ID); st.Status != "succeeded" || st.Attempts != 2 {
+		t.Errorf("flaky job: status %s after %d attempts", st.Status, st.Attempts)
+	}
+	if st := waitDone(broken.ID); st.Status != "failed" || st.Attempts != 3 || *st.Error != "permanent" {
+		t.Errorf("broken job: status %s after %d attempts", st.Status, st.Attempts)
+	}
+
+	parent, _ := jq.Enqueue(ctx, "ok", nil, nil)
+	waitDone(parent.ID)
+	child, _ := jq.Enqueue(ctx, "block", nil, &parent.ID)
+	<-started
+	if st, _ := jq.Status(ctx, parent.ID); st.Done || st.Children["running"] != 1 {
+		t.Errorf("parent should wait for running child: %+v", st)
+	}
+	// Another user can neither cancel nor interrupt the job.
+	for _, id := range []int64{parent.ID, child.ID} {
+		if ok, err := jq.Cancel(withOwner(ctx, "mallory"), id); ok || err != nil {
+			t.Errorf("another user cancelled job %d: %v %v", id, ok, err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if interrupted.Load() {
+		t.Error("child interrupted by another user")
+	}
+	if ok, err := jq.Cancel(ctx, parent.ID); !ok || err != nil {
+		t.Fatalf("cancel: %v %v", ok, err)
+	}
+	if st := waitDone(child.ID); st.Status != "cancelled" {
+		t.Errorf("child status %s, want cancelled", st.Status)
+	}
+}
+
+// runQueuedJobs runs every job that is due, one at a time, the way a
+// worker would.
+func runQueuedJobs(t *testing.T, jq *JobQueue) {
+	t.Helper()
+	q := dbgen.New(jq.DB)
+	for {
+		job, err := q.ClaimJob(context.Background())
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		jq.run(context.Background(), job)
+	}
+}
@@ -0,0 +1,384 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LLMProvider generates text from a prompt. Implementations exist for the
+// OpenAI API, any OpenAI-compatible server (Ollama, llama.cpp, vLLM), the
+// Anthropic messages API, and a deterministic fake for offline tests.
+type LLMProvider interface {
+	Name() string
+	Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error)
+}
+
+type CompletionRequest struct {
+	System    string
+	Prompt    string
+	MaxTokens int // 0 uses the provider default
+}
+
+type CompletionResponse struct {
+	Text         string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+}
+
+// LLMConfig selects and configures an LLMProvider.
+type LLMConfig struct {
+	// Provider is one of "openai", "openai-compatible", "anthropic" or
+	// "fake". Empty disables LLM features.
+	Provider string
+	Model    string
+	BaseURL  string
+	APIKey   string
+	// APIKeyFunc, used when APIKey is empty, looks the key up for each
+	// request, so a key kept in the secret store can be rotated live.
+	APIKeyFunc APIKeyFunc
+	Timeout    time.Duration
+	MaxTokens  int
+}
+
+// APIKeyFunc returns the API key to send with a request.
+type APIKeyFunc func(ctx context.Context) (string, error)
+
+// ErrNoAPIKey is returned when an APIKeyFunc has no key to give.
+var ErrNoAPIKey = errors.New("no API key set")
+
+// resolveAPIKey returns key, or the result of fn when key is empty.
+func resolveAPIKey(ctx context.Context, key string, fn APIKeyFunc) (string, error) {
+	if key != "" || fn == nil {
+		return key, nil
+	}
+	key, err := fn(ctx)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", ErrNoAPIKey
+	}
+	return key, nil
+}
+
+const (
+	defaultOpenAIBaseURL    = "https://api.openai.com/v1"
+	defaultOpenAIModel      = "gpt-4o-mini"
+	defaultAnthropicBaseURL = "https://api.anthropic.com/v1"
+	defaultAnthropicModel   = "claude-3-5-haiku-latest"
+)
+
+// NewLLMProvider builds the provider described by cfg. It returns nil
+// without error when cfg.Provider is empty.
+func NewLLMProvider(cfg LLMConfig) (LLMProvider, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = 150
+	}
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "openai":
+		if cfg.APIKey == "" && cfg.APIKeyFunc == nil {
+			return nil, fmt.Errorf("openai provider requires an API key")
+		}
+		return &openAIProvider{
+			name:      "openai",
+			baseURL:   orDefault(cfg.BaseURL, defaultOpenAIBaseURL),
+			model:     orDefault(cfg.Model, defaultOpenAIModel),
+			apiKey:    cfg.APIKey,
+			keyFunc:   cfg.APIKeyFunc,
+			maxTokens: cfg.MaxTokens,
+			client:    client,
+		}, nil
+	case "openai-compatible":
+		if cfg.BaseURL == "" || cfg.Model == "" {
+			return nil, fmt.Errorf("openai-compatible provider requires a base URL and model")
+		}
+		return &openAIProvider{
+			name:      "openai-compatible",
+			baseURL:   cfg.BaseURL,
+			model:     cfg.Model,
+			apiKey:    cfg.APIKey,
+			keyFunc:   cfg.APIKeyFunc,
+			maxTokens: cfg.MaxTokens,
+			client:    client,
+		}, nil
+	case "anthropic":
+		if cfg.APIKey == "" && cfg.APIKeyFunc == nil {
+			return nil, fmt.Errorf("anthropic provider requires an API key")
+		}
+		return &anthropicProvider{
+			baseURL:   orDefault(cfg.BaseURL, defaultAnthropicBaseURL),
+			model:     orDefault(cfg.Model, defaultAnthropicModel),
+			apiKey:    cfg.APIKey,
+			keyFunc:   cfg.APIKeyFunc,
+			maxTokens: cfg.MaxTokens,
+			client:    client,
+		}, nil
+	case "fake":
+		return &FakeLLM{}, nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// postJSON sends body to url and decodes the response into out. Non-2xx
+// responses are returned as errors carrying the start of the body.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out any) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncate(string(data), 300))
+	}
+	return json.Unmarshal(data, out)
+}
+
+type openAIProvider struct {
+	name      string
+	baseURL   string
+	model     string
+	apiKey    string
+	keyFunc   APIKeyFunc
+	maxTokens int
+	client    *http.Client
+}
+
+type openaiMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openaiRequest struct {
+	Model     string          `json:"model"`
+	Messages  []openaiMessage `json:"messages"`
+	MaxTokens int             `json:"max_tokens,omitempty"`
+}
+
+type openaiResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (p *openAIProvider) Name() string { return p.name }
+
+func (p *openAIProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	body := openaiRequest{Model: p.model, MaxTokens: req.MaxTokens}
+	if body.MaxTokens <= 0 {
+		body.MaxTokens = p.maxTokens
+	}
+	if req.System != "" {
+		body.Messages = append(body.Messages, openaiMessage{Role: "system", Content: req.System})
+	}
+	body.Messages = append(body.Messages, openaiMessage{Role: "user", Content: req.Prompt})
+
+	apiKey, err := resolveAPIKey(ctx, p.apiKey, p.keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.name, err)
+	}
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["Authorization"] = "Bearer " + apiKey
+	}
+	var result openaiResponse
+	if err := postJSON(ctx, p.client, strings.TrimSuffix(p.baseURL, "/")+"/chat/completions", headers, body, &result); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.name, err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("%s error: %s", p.name, result.Error.Message)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("no response from %s", p.name)
+	}
+	return &CompletionResponse{
+		Text:         strings.TrimSpace(result.Choices[0].Message.Content),
+		Model:        orDefault(result.Model, p.model),
+		InputTokens:  result.Usage.PromptTokens,
+		OutputTokens: result.Usage.CompletionTokens,
+	}, nil
+}
+
+type anthropicProvider struct {
+	baseURL   string
+	model     string
+	apiKey    string
+	keyFunc   APIKeyFunc
+	maxTokens int
+	client    *http.Client
+}
+
+type anthropicRequest struct {
+	Model     string          `json:"model"`
+	System    string          `json:"system,omitempty"`
+	Messages  []openaiMessage `json:"messages"`
+	MaxTokens int             `json:"max_tokens"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (p *anthropicProvider) Name() string { return "anthropic" }
+
+func (p *anthropicProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	body := anthropicRequest{
+		Model:     p.model,
+		System:    req.System,
+		Messages:  []openaiMessage{{Role: "user", Content: req.Prompt}},
+		MaxTokens: req.MaxTokens,
+	}
+	if body.MaxTokens <= 0 {
+		body.MaxTokens = p.maxTokens
+	}
+	apiKey, err := resolveAPIKey(ctx, p.apiKey, p.keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	headers := map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+	var result anthropicResponse
+	if err := postJSON(ctx, p.client, strings.TrimSuffix(p.baseURL, "/")+"/messages", headers, body, &result); err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	var text strings.Builder
+	for _, c := range result.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no response from anthropic")
+	}
+	return &CompletionResponse{
+		Text:         strings.TrimSpace(text.String()),
+		Model:        orDefault(result.Model, p.model),
+		InputTokens:  result.Usage.InputTokens,
+		OutputTokens: result.Usage.OutputTokens,
+	}, nil
+}
+
+// FakeLLM is a deterministic offline provider. Respond, if set, produces
+// the reply; otherwise the reply is derived from a hash of the prompt.
+type FakeLLM struct {
+	Respond func(req CompletionRequest) string
+
+	mu    sync.Mutex
+	calls []CompletionRequest
+}
+
+func (f *FakeLLM) Name() string { return "fake" }
+
+func (f *FakeLLM) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, req)
+	f.mu.Unlock()
+
+	var text string
+	if f.Respond != nil {
+		text = f.Respond(req)
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(req.System + "\x00" + req.Prompt))
+		text = fmt.Sprintf("Fake summary %08x.", h.Sum32())
+	}
+	return &CompletionResponse{
+		Text:         text,
+		Model:        "fake",
+		InputTokens:  len(strings.Fields(req.Prompt)),
+		OutputTokens: len(strings.Fields(text)),
+	}, nil
+}
+
+// Calls returns the requests the fake has received.
+func (f *FakeLLM) Calls() []CompletionRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]CompletionRequest(nil), f.calls...)
+}
+
+func summarizeWithLLM(ctx context.Context, llm LLMProvider, title, description, pageText, url string) (string, error) {
+	if llm == nil {
+		return "", fmt.Errorf("no LLM provider configured")
+	}
+
+	// Truncate page text to avoid token limits
+	if len(pageText) > 4000 {
+		pageText = pageText[:4000]
+	}
+
+	prompt := fmt.Sprintf(`Summarize this webpage in 1-2 concise sentences. Focus on what it is and why someone would bookmark it.
+
+URL: %s
+Title: %s
+Description: %s
+Page content excerpt: %s
+
+Summary:`, url, title, description, pageText)
+
+	resp, err := llm.Complete(ctx, CompletionRequest{Prompt: prompt})
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
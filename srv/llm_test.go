@@ -0,0 +1,119 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var got openaiRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("expected no Authorization header without a key")
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"model":"llama3","choices":[{"message":{"content":" A local summary. "}}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+	}))
+	defer ts.Close()
+
+	llm, err := NewLLMProvider(LLMConfig{Provider: "openai-compatible", BaseURL: ts.URL + "/v1", Model: "llama3", MaxTokens: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := llm.Complete(context.Background(), CompletionRequest{System: "be brief", Prompt: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "A local summary." || resp.InputTokens != 12 || resp.OutputTokens != 3 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if got.Model != "llama3" || got.MaxTokens != 64 || len(got.Messages) != 2 || got.Messages[0].Role != "system" {
+		t.Errorf("unexpected request %+v", got)
+	}
+
+	// Summaries use the configured limit.
+	if _, err := summarizeWithLLM(context.Background(), llm, "Title", "", "text", "https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got.MaxTokens != 64 {
+		t.Errorf("summary max_tokens = %d, want the configured 64", got.MaxTokens)
+	}
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	var got anthropicRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" || r.Header.Get("x-api-key") != "secret" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"content":[{"type":"text","text":"Claude summary."}],"usage":{"input_tokens":5,"output_tokens":2}}`))
+	}))
+	defer ts.Close()
+
+	llm, err := NewLLMProvider(LLMConfig{Provider: "anthropic", BaseURL: ts.URL, APIKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := llm.Complete(context.Background(), CompletionRequest{System: "sys", Prompt: "hello", MaxTokens: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "Claude summary." || resp.Model != defaultAnthropicModel {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if got.System != "sys" || got.MaxTokens != 20 || got.Messages[0].Content != "hello" {
+		t.Errorf("unexpected request %+v", got)
+	}
+}
+
+func TestNewLLMProviderValidation(t *testing.T) {
+	if llm, err := NewLLMProvider(LLMConfig{}); llm != nil || err != nil {
+		t.Errorf("empty provider should disable LLM, got %v, %v", llm, err)
+	}
+	for _, cfg := range []LLMConfig{
+		{Provider: "openai"},
+		{Provider: "openai-compatible", BaseURL: "http://localhost:11434/v1"},
+		{Provider: "anthropic"},
+		{Provider: "bogus"},
+	} {
+		if _, err := NewLLMProvider(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestAnalyzeURLWithFakeLLM(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Gardening Tips</title></head><body><p>Tomatoes tomatoes need sunlight and water.</p></body></html>`))
+	}))
+	defer page.Close()
+
+	server, err := New(filepath.Join(t.TempDir(), "test.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &FakeLLM{}
+	server.LLM = fake
+
+	first, err := server.analyzeURL(context.Background(), page.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := server.analyzeURL(context.Background(), page.URL)
+	if !strings.HasPrefix(first.Summary, "Fake summary") || first.Summary != second.Summary {
+		t.Errorf("expected deterministic fake summary, got %q and %q", first.Summary, second.Summary)
+	}
+	calls := fake.Calls()
+	if len(calls) != 2 || !strings.Contains(calls[0].Prompt, "Gardening Tips") {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+}
@@ -0,0 +1,315 @@
+package srv
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db"
+	"srv.exe.dev/db/dbgen"
+)
+
+// Metrics holds the counters and histograms served at /metrics in the
+// Prometheus text format. Library and job queue gauges are read from the
+// database when scraped.
+type Metrics struct {
+	httpRequests *metricVec
+	httpDuration *metricVec
+	fetches      *metricVec
+	fetchErrors  *metricVec
+	llmRequests  *metricVec
+	llmTokens    *metricVec
+	llmDuration  *metricVec
+}
+
+var (
+	httpBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	llmBuckets  = []float64{.25, .5, 1, 2.5, 5, 10, 20, 30, 60}
+)
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		httpRequests: newCounter("http_requests_total", "HTTP requests by route and status code.", "route", "code"),
+		httpDuration: newHistogram("http_request_duration_seconds", "HTTP request latency by route.", httpBuckets, "route"),
+		fetches:      newCounter("fetch_requests_total", "Outbound HTTP requests by host.", "host"),
+		fetchErrors:  newCounter("fetch_errors_total", "Outbound HTTP requests that failed or returned a 4xx or 5xx status, by host.", "host"),
+		llmRequests:  newCounter("llm_requests_total", "LLM completions by provider, model and result.", "provider", "model", "result"),
+		llmTokens:    newCounter("llm_tokens_total", "LLM tokens used by provider, model and direction.", "provider", "model", "direction"),
+		llmDuration:  newHistogram("llm_request_duration_seconds", "LLM completion latency by provider.", llmBuckets, "provider"),
+	}
+}
+
+// metricVec is a counter or histogram family keyed by label values.
+type metricVec struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64 // histograms only
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	sum    float64  // counter value, or histogram sum
+	counts []uint64 // observations per bucket (not cumulative)
+	n      uint64
+}
+
+func newCounter(name, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, kind: "counter", labels: labels, series: map[string]*series{}}
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, kind: "histogram", labels: labels, buckets: buckets, series: map[string]*series{}}
+}
+
+// get returns the series for values, creating it. v.mu must be held.
+func (v *metricVec) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: slices.Clone(values), counts: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+	return s
+}
+
+// Add increments a counter.
+func (v *metricVec) Add(delta float64, values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.get(values).sum += delta
+}
+
+// Observe records x in a histogram.
+func (v *metricVec) Observe(x float64, values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s := v.get(values)
+	s.sum += x
+	s.n++
+	if i := sort.SearchFloat64s(v.buckets, x); i < len(v.buckets) {
+		s.counts[i]++
+	}
+}
+
+func (v *metricVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := v.series[k]
+		if v.kind == "counter" {
+			fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, s.values), formatValue(s.sum))
+			continue
+		}
+		var cumulative uint64
+		for i, le := range v.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, formatLabels(append(slices.Clone(v.labels), "le"), append(slices.Clone(s.values), formatValue(le))), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, formatLabels(append(slices.Clone(v.labels), "le"), append(slices.Clone(s.values), "+Inf")), s.n)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, formatLabels(v.labels, s.values), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, formatLabels(v.labels, s.values), s.n)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeGauge(w io.Writer, name, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
+// instrument counts requests and their latency by the mux pattern that
+// serves them, so that path parameters don't multiply the series.
+func (m *Metrics) instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: 200}
+		next.ServeHTTP(rec, r)
+		m.httpRequests.Add(1, route, strconv.Itoa(rec.status))
+		m.httpDuration.Observe(time.Since(start).Seconds(), route)
+	})
+}
+
+// statusRecorder remembers the response status. It passes Flush through
+// and unwraps for http.ResponseController, which /api/events relies on.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = code, true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	r.wroteHeader = true
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// meteredTransport counts outbound requests and failures by host.
+type meteredTransport struct {
+	base    http.RoundTripper
+	metrics *Metrics
+}
+
+func (t meteredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	t.metrics.fetches.Add(1, host)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode >= 400 {
+		t.metrics.fetchErrors.Add(1, host)
+	}
+	return resp, err
+}
+
+// meteredLLM records calls, token usage and latency for an LLMProvider.
+type meteredLLM struct {
+	LLMProvider
+	metrics *Metrics
+}
+
+func (l meteredLLM) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	provider := l.Name()
+	start := time.Now()
+	resp, err := l.LLMProvider.Complete(ctx, req)
+	l.metrics.llmDuration.Observe(time.Since(start).Seconds(), provider)
+	if err != nil {
+		l.metrics.llmRequests.Add(1, provider, "", "error")
+		return resp, err
+	}
+	l.metrics.llmRequests.Add(1, provider, resp.Model, "ok")
+	l.metrics.llmTokens.Add(float64(resp.InputTokens), provider, resp.Model, "input")
+	l.metrics.llmTokens.Add(float64(resp.OutputTokens), provider, resp.Model, "output")
+	return resp, nil
+}
+
+// llm returns s.LLM instrumented with s.Metrics, or nil without a provider.
+func (s *Server) llm() LLMProvider {
+	if s.LLM == nil || s.Metrics == nil {
+		return s.LLM
+	}
+	return meteredLLM{s.LLM, s.Metrics}
+}
+
+// HandleHealthz reports that the process is up.
+func (s *Server) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// HandleReadyz reports whether the database is reachable and migrated.
+func (s *Server) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if err := s.DB.PingContext(ctx); err != nil {
+		writeError(w, "database unreachable: "+err.Error(), 503)
+		return
+	}
+	pending, err := db.PendingMigrations(s.DB)
+	if err != nil {
+		writeError(w, err.Error(), 503)
+		return
+	}
+	if len(pending) > 0 {
+		writeError(w, "migrations not applied: "+strings.Join(pending, ", "), 503)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// HandleMetrics serves all metrics in the Prometheus text format. Totals
+// cover every user's data.
+func (s *Server) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	library, err := q.CountLibrary(r.Context())
+	if err != nil {
+		slog.Warn("metrics: count library", "error", err)
+	}
+	jobs, err := q.CountJobsByStatus(r.Context())
+	if err != nil {
+		slog.Warn("metrics: count jobs", "error", err)
+	}
+	byStatus := map[string]int64{"queued": 0, "running": 0}
+	for _, j := range jobs {
+		byStatus[j.Status] = j.Count
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	for _, v := range []*metricVec{
+		s.Metrics.httpRequests, s.Metrics.httpDuration,
+		s.Metrics.fetches, s.Metrics.fetchErrors,
+		s.Metrics.llmRequests, s.Metrics.llmTokens, s.Metrics.llmDuration,
+	} {
+		v.write(bw)
+	}
+	writeGauge(bw, "job_queue_depth", "Jobs waiting for a worker.", byStatus["queued"])
+	fmt.Fprintf(bw, "# HELP jobs Jobs by status.\n# TYPE jobs gauge\n")
+	statuses := make([]string, 0, len(byStatus))
+	for status := range byStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(bw, "jobs%s %d\n", formatLabels([]string{"status"}, []string{status}), byStatus[status])
+	}
+	writeGauge(bw, "library_bookmarks", "Bookmarks across all users.", library.Bookmarks)
+	writeGauge(bw, "library_tags", "Tags across all users.", library.Tags)
+	writeGauge(bw, "library_collections", "Collections across all users.", library.Collections)
+}
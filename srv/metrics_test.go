@@ -0,0 +1,85 @@
+package srv
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHealthAndMetrics(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "metrics.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.LLM = &FakeLLM{}
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		req.Header.Set("X-ExeDev-UserID", "alice")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if code, body := get(path); code != 200 || body != "ok\n" {
+			t.Errorf("%s = %d %q", path, code, body)
+		}
+	}
+	get("/api/bookmarks/1")
+	get("/api/bookmarks/2")
+
+	// Event streams still flush through the instrumented writer.
+	req, _ := http.NewRequest("GET", ts.URL+"/api/events", nil)
+	req.Header.Set("X-ExeDev-UserID", "alice")
+	events, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := bufio.NewReader(events.Body).ReadString('\n'); line != "retry: 3000\n" {
+		t.Errorf("event stream started with %q", line)
+	}
+	events.Body.Close()
+
+	resp, err := server.httpClient().Get(ts.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, err := server.llm().Complete(context.Background(), CompletionRequest{Prompt: "three word prompt"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := withOwner(context.Background(), "alice")
+	server.Jobs.Enqueue(ctx, "missing", nil, nil)
+
+	_, metrics := get("/metrics")
+	for _, want := range []string{
+		`http_requests_total{route="GET /api/bookmarks/{id}",code="404"} 2`,
+		`http_requests_total{route="GET /readyz",code="200"} 1`,
+		`http_request_duration_seconds_count{route="GET /api/bookmarks/{id}"} 2`,
+		`fetch_requests_total{host="127.0.0.1"} 1`,
+		`fetch_errors_total{host="127.0.0.1"} 1`,
+		`llm_requests_total{provider="fake",model="fake",result="ok"} 1`,
+		`llm_tokens_total{provider="fake",model="fake",direction="input"} 3`,
+		`llm_request_duration_seconds_bucket{provider="fake",le="+Inf"} 1`,
+		"job_queue_depth 1",
+		`jobs{status="running"} 0`,
+		"library_bookmarks 0",
+	} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, metrics)
+		}
+	}
+}
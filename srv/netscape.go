@@ -0,0 +1,368 @@
+package srv
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// NetscapeBookmark is a single link parsed from a Netscape bookmark file.
+type NetscapeBookmark struct {
+	URL          string
+	Title        string
+	Description  string
+	Folder       string
+	Tags         []string
+	IconURL      string
+	AddDate      time.Time
+	LastModified time.Time
+}
+
+var (
+	netscapeTagRe  = regexp.MustCompile(`(?is)<(/?)(dl|h3|a|dd)\b([^>]*)>`)
+	netscapeAttrRe = regexp.MustCompile(`(?is)([a-z_]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// parseNetscapeBookmarks parses the bookmarks.html format exported by
+// browsers and most bookmark services. Each link is attributed to the
+// innermost <H3> folder that contains it.
+func parseNetscapeBookmarks(doc string) []NetscapeBookmark {
+	var bookmarks []NetscapeBookmark
+	var folders []string
+	pendingFolder := ""
+
+	matches := netscapeTagRe.FindAllStringSubmatchIndex(doc, -1)
+	for i, m := range matches {
+		closing := m[3] > m[2]
+		tag := strings.ToLower(doc[m[4]:m[5]])
+		attrs := doc[m[6]:m[7]]
+		// Text runs until the next recognized tag (or any tag for <DD>).
+		rest := doc[m[1]:]
+		if i+1 < len(matches) {
+			rest = doc[m[1]:matches[i+1][0]]
+		}
+
+		switch {
+		case tag == "dl" && !closing:
+			folders = append(folders, pendingFolder)
+			pendingFolder = ""
+		case tag == "dl" && closing:
+			if len(folders) > 0 {
+				folders = folders[:len(folders)-1]
+			}
+		case tag == "h3" && !closing:
+			pendingFolder = netscapeText(rest)
+		case tag == "a" && !closing:
+			a := parseNetscapeAttrs(attrs)
+			b := NetscapeBookmark{
+				URL:          strings.TrimSpace(a["href"]),
+				Title:        netscapeText(rest),
+				IconURL:      a["icon_uri"],
+				AddDate:      parseUnixAttr(a["add_date"]),
+				LastModified: parseUnixAttr(a["last_modified"]),
+			}
+			for _, t := range strings.Split(a["tags"], ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					b.Tags = append(b.Tags, t)
+				}
+			}
+			for j := len(folders) - 1; j >= 0; j-- {
+				if folders[j] != "" {
+					b.Folder = folders[j]
+					break
+				}
+			}
+			bookmarks = append(bookmarks, b)
+		case tag == "dd" && !closing && len(bookmarks) > 0:
+			if idx := strings.Index(rest, "<"); idx >= 0 {
+				rest = rest[:idx]
+			}
+			bookmarks[len(bookmarks)-1].Description = strings.TrimSpace(html.UnescapeString(rest))
+		}
+	}
+	return bookmarks
+}
+
+// netscapeText returns the unescaped text before the first closing tag.
+func netscapeText(s string) string {
+	if idx := strings.Index(s, "</"); idx >= 0 {
+		s = s[:idx]
+	}
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+func parseNetscapeAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range netscapeAttrRe.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+	return attrs
+}
+
+func parseUnixAttr(s string) time.Time {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	// Some exporters (e.g. Firefox JSON-derived tools) write microseconds.
+	if n > 1e14 {
+		n /= 1e6
+	} else if n > 1e11 {
+		n /= 1e3
+	}
+	return time.Unix(n, 0).UTC()
+}
+
+func (s *Server) HandleNetscapeImport(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, "File too large or invalid form", 400)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, "No file uploaded", 400)
+			return
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		if err != nil {
+			writeError(w, "Could not read file", 500)
+			return
+		}
+	} else {
+		var err error
+		data, err = io.ReadAll(io.LimitReader(r.Body, 32<<20))
+		if err != nil {
+			writeError(w, "Could not read body", 400)
+			return
+		}
+	}
+
+	items := parseNetscapeBookmarks(string(data))
+	if len(items) == 0 {
+		writeError(w, "No bookmarks found. Make sure you uploaded a bookmarks.html export.", 400)
+		return
+	}
+
+	progress := s.newProgress(r, "netscape_import")
+	defer progress.done()
+	progress.found(len(items))
+
+	result, err := s.importNetscapeBookmarks(r.Context(), items, progress)
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, result)
+}
+
+type netscapeImportResult struct {
+	Found       int    `json:"found"`
+	Saved       int    `json:"saved"`
+	Skipped     int    `json:"skipped"`
+	Failed      int    `json:"failed"`
+	Collections int    `json:"collections"`
+	OperationID string `json:"operation_id"`
+}
+
+func (s *Server) importNetscapeBookmarks(ctx context.Context, items []NetscapeBookmark, progress *progressReporter) (*netscapeImportResult, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+
+	res := &netscapeImportResult{Found: len(items), OperationID: progress.OperationID}
+	var created []dbgen.Bookmark
+	collections := make(map[string]int64)
+	// Links that appear in several folders of the same file are created once
+	// and added to every folder's collection.
+	imported := make(map[string]int64)
+
+	for _, item := range items {
+		progress.item(item.URL)
+		if !strings.HasPrefix(item.URL, "http://") && !strings.HasPrefix(item.URL, "https://") {
+			res.Skipped++
+			progress.skipped()
+			continue
+		}
+
+		id, seen := imported[item.URL]
+		if !seen {
+			if _, err := q.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: ownerID(ctx), Url: item.URL}); err == nil {
+				res.Skipped++
+				progress.skipped()
+				continue
+			}
+			b, err := q.ImportBookmark(ctx, netscapeImportParams(item))
+			if err != nil {
+				res.Failed++
+				progress.failed()
+				continue
+			}
+			id = b.ID
+			imported[item.URL] = id
+			created = append(created, b)
+			res.Saved++
+			progress.saved()
+
+			for _, name := range item.Tags {
+				tag, err := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: name, Color: strPtr("#6366f1")})
+				if err == nil {
+					q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: id, TagID: tag.ID})
+				}
+			}
+		} else {
+			res.Skipped++
+			progress.skipped()
+		}
+
+		if item.Folder == "" {
+			continue
+		}
+		colID, ok := collections[item.Folder]
+		if !ok {
+			col, err := q.GetCollectionByName(ctx, dbgen.GetCollectionByNameParams{OwnerID: ownerID(ctx), Name: item.Folder})
+			if err != nil {
+				col, err = q.CreateCollection(ctx, dbgen.CreateCollectionParams{
+					OwnerID: ownerID(ctx),
+					Name:    item.Folder, Icon: strPtr("📁"),
+				})
+				if err != nil {
+					return nil, fmt.Errorf("create collection %q: %w", item.Folder, err)
+				}
+				res.Collections++
+			}
+			colID = col.ID
+			collections[item.Folder] = colID
+		}
+		q.AddBookmarkToCollection(ctx, dbgen.AddBookmarkToCollectionParams{OwnerID: ownerID(ctx), BookmarkID: id, CollectionID: colID})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, b := range created {
+		s.Events.Publish(ctx, EventBookmarkCreated, b)
+	}
+	return res, nil
+}
+
+func netscapeImportParams(item NetscapeBookmark) dbgen.ImportBookmarkParams {
+	title := item.Title
+	if title == "" {
+		title = item.URL
+	}
+	created := item.AddDate
+	if created.IsZero() {
+		created = time.Now().UTC()
+	}
+	updated := item.LastModified
+	if updated.IsZero() {
+		updated = created
+	}
+	var favicon *string
+	if strings.HasPrefix(item.IconURL, "http://") || strings.HasPrefix(item.IconURL, "https://") {
+		favicon = &item.IconURL
+	}
+	return dbgen.ImportBookmarkParams{
+		Url:         item.URL,
+		Title:       title,
+		Description: strPtr(item.Description),
+		SourceType:  detectSourceType(item.URL),
+		FaviconUrl:  favicon,
+		CreatedAt:   created,
+		UpdatedAt:   updated,
+	}
+}
+
+func (s *Server) HandleNetscapeExport(w http.ResponseWriter, r *http.Request) {
+	q := dbgen.New(s.DB)
+	bookmarks, err := q.ListAllBookmarks(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	tagRows, err := q.ListAllBookmarkTagNames(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	collections, err := q.ListCollections(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	memberships, err := q.ListAllBookmarkCollections(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+
+	tags := make(map[int64][]string)
+	for _, t := range tagRows {
+		tags[t.BookmarkID] = append(tags[t.BookmarkID], t.Name)
+	}
+	byID := make(map[int64]dbgen.Bookmark, len(bookmarks))
+	for _, b := range bookmarks {
+		byID[b.ID] = b
+	}
+	inCollection := make(map[int64][]int64)
+	filed := make(map[int64]bool)
+	for _, m := range memberships {
+		inCollection[m.CollectionID] = append(inCollection[m.CollectionID], m.BookmarkID)
+		filed[m.BookmarkID] = true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="bookmarks.html"`)
+
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	sb.WriteString("<!-- This is an automatically generated file.\n     It will be read and overwritten.\n     DO NOT EDIT! -->\n")
+	sb.WriteString(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n")
+	sb.WriteString("<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n")
+	for _, c := range collections {
+		ids := inCollection[c.ID]
+		fmt.Fprintf(&sb, "    <DT><H3 ADD_DATE=\"%d\">%s</H3>\n    <DL><p>\n", c.CreatedAt.Unix(), html.EscapeString(c.Name))
+		for _, id := range ids {
+			if b, ok := byID[id]; ok {
+				writeNetscapeBookmark(&sb, "        ", b, tags[id])
+			}
+		}
+		sb.WriteString("    </DL><p>\n")
+	}
+	for _, b := range bookmarks {
+		if !filed[b.ID] {
+			writeNetscapeBookmark(&sb, "    ", b, tags[b.ID])
+		}
+	}
+	sb.WriteString("</DL><p>\n")
+	io.WriteString(w, sb.String())
+}
+
+func writeNetscapeBookmark(sb *strings.Builder, indent string, b dbgen.Bookmark, tags []string) {
+	fmt.Fprintf(sb, `%s<DT><A HREF="%s" ADD_DATE="%d" LAST_MODIFIED="%d"`,
+		indent, html.EscapeString(b.Url), b.CreatedAt.Unix(), b.UpdatedAt.Unix())
+	if len(tags) > 0 {
+		fmt.Fprintf(sb, ` TAGS="%s"`, html.EscapeString(strings.Join(tags, ",")))
+	}
+	if b.FaviconUrl != nil && *b.FaviconUrl != "" {
+		fmt.Fprintf(sb, ` ICON_URI="%s"`, html.EscapeString(*b.FaviconUrl))
+	}
+	fmt.Fprintf(sb, ">%s</A>\n", html.EscapeString(b.Title))
+	if b.Description != nil && *b.Description != "" {
+		fmt.Fprintf(sb, "%s<DD>%s\n", indent, html.EscapeString(*b.Description))
+	}
+}
@@ -0,0 +1,110 @@
+package srv
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+const sampleNetscape = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><H3>Go &amp; Rust</H3>
+        <DL><p>
+            <DT><A HREF="https://go.dev/" ADD_DATE="1600000000" TAGS="go,lang">The Go Programming Language</A>
+            <DD>Build simple, secure, scalable systems
+        </DL><p>
+        <DT><A HREF="https://news.ycombinator.com/" ADD_DATE="1600000100">Hacker News</A>
+    </DL><p>
+    <DT><A href='https://example.com/?a=1&amp;b=2'>Example</A>
+    <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+</DL><p>
+`
+
+func TestParseNetscapeBookmarks(t *testing.T) {
+	got := parseNetscapeBookmarks(sampleNetscape)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 bookmarks, got %d: %+v", len(got), got)
+	}
+	want := NetscapeBookmark{
+		URL:         "https://go.dev/",
+		Title:       "The Go Programming Language",
+		Description: "Build simple, secure, scalable systems",
+		Folder:      "Go & Rust",
+		Tags:        []string{"go", "lang"},
+		AddDate:     time.Unix(1600000000, 0).UTC(),
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("got %+v, want %+v", got[0], want)
+	}
+	if got[1].Folder != "Bookmarks bar" {
+		t.Errorf("expected outer folder after nested DL closes, got %q", got[1].Folder)
+	}
+	if got[2].URL != "https://example.com/?a=1&b=2" || got[2].Folder != "" {
+		t.Errorf("unexpected top-level bookmark %+v", got[2])
+	}
+}
+
+func TestNetscapeRoundTrip(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "netscape.sqlite3"), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+	q.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: ownerID(ctx), Url: "https://news.ycombinator.com/", Title: "HN", SourceType: "web"})
+
+	req := httptest.NewRequest("POST", "/api/import/netscape", strings.NewReader(sampleNetscape))
+	req.Header.Set("Content-Type", "text/html")
+	w := httptest.NewRecorder()
+	server.HandleNetscapeImport(w, req)
+	if w.Code != 200 {
+		t.Fatalf("import status %d: %s", w.Code, w.Body.String())
+	}
+	for _, s := range []string{`"saved":2`, `"skipped":2`, `"collections":1`} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("import result missing %s: %s", s, w.Body.String())
+		}
+	}
+
+	b, err := q.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: ownerID(ctx), Url: "https://go.dev/"})
+	if err != nil {
+		t.Fatalf("imported bookmark missing: %v", err)
+	}
+	if !b.CreatedAt.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("created_at = %v, want ADD_DATE", b.CreatedAt)
+	}
+	if tags, _ := q.GetBookmarkTags(ctx, dbgen.GetBookmarkTagsParams{OwnerID: ownerID(ctx), BookmarkID: b.ID}); len(tags) != 2 {
+		t.Errorf("expected 2 tags, got %v", tags)
+	}
+	if cols, _ := q.GetBookmarkCollections(ctx, dbgen.GetBookmarkCollectionsParams{OwnerID: ownerID(ctx), BookmarkID: b.ID}); len(cols) != 1 || cols[0].Name != "Go & Rust" {
+		t.Errorf("expected collection Go & Rust, got %v", cols)
+	}
+
+	w = httptest.NewRecorder()
+	server.HandleNetscapeExport(w, httptest.NewRequest("GET", "/api/export/netscape", nil))
+	exported := parseNetscapeBookmarks(w.Body.String())
+	if len(exported) != 3 {
+		t.Fatalf("expected 3 exported bookmarks, got %d:\n%s", len(exported), w.Body.String())
+	}
+	var goDev NetscapeBookmark
+	for _, e := range exported {
+		if e.URL == "https://go.dev/" {
+			goDev = e
+		}
+	}
+	if goDev.Folder != "Go & Rust" || !reflect.DeepEqual(goDev.Tags, []string{"go", "lang"}) ||
+		goDev.Description == "" || !goDev.AddDate.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("export did not round-trip: %+v", goDev)
+	}
+}
@@ -0,0 +1,168 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// OEmbed is a provider's oEmbed response (https://oembed.com). HTML is the
+// provider's embed markup for video and rich types; it is third-party
+// markup and must only be rendered sandboxed.
+type OEmbed struct {
+	Type         string    `json:"type"` // photo, video, link or rich
+	Title        string    `json:"title,omitempty"`
+	AuthorName   string    `json:"author_name,omitempty"`
+	AuthorURL    string    `json:"author_url,omitempty"`
+	ProviderName string    `json:"provider_name,omitempty"`
+	ProviderURL  string    `json:"provider_url,omitempty"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	URL          string    `json:"url,omitempty"` // the image, for photos
+	HTML         string    `json:"html,omitempty"`
+	Width        oembedInt `json:"width,omitempty"`
+	Height       oembedInt `json:"height,omitempty"`
+}
+
+// oembedInt accepts the numbers some providers send as strings.
+type oembedInt int
+
+func (n *oembedInt) UnmarshalJSON(b []byte) error {
+	v, _ := strconv.Atoi(strings.Trim(string(b), `"`))
+	*n = oembedInt(v)
+	return nil
+}
+
+// oembedProvider is a site whose oEmbed endpoint is known without
+// discovery.
+type oembedProvider struct {
+	Name     string
+	Hosts    []string // the host or any subdomain of it matches
+	Endpoint string
+}
+
+var oembedProviders = []oembedProvider{
+	{"YouTube", []string{"youtube.com", "youtu.be"}, "https://www.youtube.com/oembed"},
+	{"Vimeo", []string{"vimeo.com"}, "https://vimeo.com/api/oembed.json"},
+	{"SoundCloud", []string{"soundcloud.com"}, "https://soundcloud.com/oembed"},
+	{"Twitter", []string{"twitter.com", "x.com"}, "https://publish.twitter.com/oembed"},
+	{"Flickr", []string{"flickr.com", "flic.kr"}, "https://www.flickr.com/services/oembed/"},
+	{"Spotify", []string{"open.spotify.com"}, "https://open.spotify.com/oembed"},
+	{"TikTok", []string{"tiktok.com"}, "https://www.tiktok.com/oembed"},
+	{"Dailymotion", []string{"dailymotion.com", "dai.ly"}, "https://www.dailymotion.com/services/oembed"},
+	{"Reddit", []string{"reddit.com"}, "https://www.reddit.com/oembed"},
+	{"Mixcloud", []string{"mixcloud.com"}, "https://app.mixcloud.com/oembed/"},
+}
+
+// oembedEndpoint returns the registry endpoint URL for pageURL, or "" if
+// no known provider serves it.
+func oembedEndpoint(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, p := range oembedProviders {
+		if slices.ContainsFunc(p.Hosts, func(h string) bool { return host == h || strings.HasSuffix(host, "."+h) }) {
+			return p.Endpoint + "?format=json&url=" + url.QueryEscape(pageURL)
+		}
+	}
+	return ""
+}
+
+// fetchOEmbed requests an oEmbed endpoint URL, which already carries the
+// page URL.
+func (s *Server) fetchOEmbed(ctx context.Context, endpoint string) (*OEmbed, error) {
+	req, err := s.newFetchRequest(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("oembed %s: %s", endpoint, resp.Status)
+	}
+	var oe OEmbed
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxPageBytes)).Decode(&oe); err != nil {
+		return nil, fmt.Errorf("oembed %s: %w", endpoint, err)
+	}
+	return &oe, nil
+}
+
+// addOEmbed looks up the page in the provider registry, or uses the
+// endpoint the page advertises, and merges the response into page. oEmbed
+// titles and authors are better than scraped ones, so they win.
+func (s *Server) addOEmbed(ctx context.Context, page *PageMetadata, pageURL string) bool {
+	endpoint := oembedEndpoint(pageURL)
+	if endpoint == "" {
+		endpoint = page.OEmbedURL
+	}
+	if endpoint == "" {
+		return false
+	}
+	oe, err := s.fetchOEmbed(ctx, endpoint)
+	if err != nil {
+		slog.Debug("oembed: failed", "url", pageURL, "error", err)
+		return false
+	}
+	page.OEmbed = oe
+	if oe.Title != "" {
+		page.Title = oe.Title
+	}
+	if oe.AuthorName != "" {
+		page.Author = oe.AuthorName
+	}
+	if page.SiteName == "" {
+		page.SiteName = oe.ProviderName
+	}
+	// Posts (tweets, for instance) carry their text in the embed markup.
+	if page.Description == "" && oe.HTML != "" {
+		if doc, err := html.Parse(strings.NewReader(oe.HTML)); err == nil {
+			page.Description = truncate(collapseSpace(nodeText(doc)), 400)
+		}
+	}
+	var images []string
+	if oe.Type == "photo" {
+		images = append(images, oe.URL)
+	}
+	images = append(images, oe.ThumbnailURL)
+	images = slices.DeleteFunc(images, func(img string) bool {
+		return !strings.HasPrefix(img, "https://") && !strings.HasPrefix(img, "http://")
+	})
+	for _, img := range page.Images {
+		if !slices.Contains(images, img) {
+			images = append(images, img)
+		}
+	}
+	page.Images = images
+	return true
+}
+
+// describeOEmbed summarizes an embed, as in `Video "Title" by Author on
+// YouTube.`
+func describeOEmbed(oe *OEmbed) string {
+	kind := map[string]string{"video": "Video", "photo": "Photo", "rich": "Post", "link": "Link"}[oe.Type]
+	if kind == "" {
+		kind = "Content"
+	}
+	if oe.Title != "" {
+		kind += ` "` + oe.Title + `"`
+	}
+	if oe.AuthorName != "" {
+		kind += " by " + oe.AuthorName
+	}
+	if oe.ProviderName != "" {
+		kind += " on " + oe.ProviderName
+	}
+	return kind + "."
+}
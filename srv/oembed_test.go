@@ -0,0 +1,80 @@
+package srv
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOEmbed(t *testing.T) {
+	for pageURL, want := range map[string]string{
+		"https://www.youtube.com/watch?v=abc": "https://www.youtube.com/oembed?format=json&url=https%3A%2F%2Fwww.youtube.com%2Fwatch%3Fv%3Dabc",
+		"https://x.com/alice/status/1":        "https://publish.twitter.com/oembed?format=json&url=https%3A%2F%2Fx.com%2Falice%2Fstatus%2F1",
+		"https://box.com/x":                   "",
+		"https://example.com/":                "",
+	} {
+		if got := oembedEndpoint(pageURL); got != want {
+			t.Errorf("oembedEndpoint(%q) = %q, want %q", pageURL, got, want)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/video/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Watch - Tube</title>
+			<link rel="alternate" type="application/json+oembed" href="/oembed?url=%2Fvideo%2F1"></head></html>`))
+	})
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type": "video", "version": "1.0", "title": "Knitting Basics", "author_name": "Dana",
+			"provider_name": "Tube", "thumbnail_url": "http://` + r.Host + `/1.png", "width": "640", "height": 360,
+			"html": "<iframe src=\"https://tube.example.com/embed/1\"></iframe>"}`))
+	})
+	mux.HandleFunc("/1.png", func(w http.ResponseWriter, r *http.Request) {
+		png.Encode(w, image.NewGray(image.Rect(0, 0, 64, 36)))
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	server, err := New(filepath.Join(t.TempDir(), "oembed.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := server.Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-ExeDev-UserID", "alice")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("POST", "/api/bookmarks", `{"url": "`+site.URL+`/video/1"}`); w.Code != 201 {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	runQueuedJobs(t, server.Jobs)
+	var got struct {
+		Bookmark struct {
+			Title    string `json:"title"`
+			ImageURL string `json:"image_url"`
+		} `json:"bookmark"`
+		OEmbed *OEmbed `json:"oembed"`
+	}
+	json.NewDecoder(do("GET", "/api/bookmarks/1", "").Body).Decode(&got)
+	if got.Bookmark.Title != "Knitting Basics" || !strings.HasPrefix(got.Bookmark.ImageURL, "/thumbnails/") {
+		t.Errorf("bookmark %+v", got.Bookmark)
+	}
+	if got.OEmbed == nil || got.OEmbed.AuthorName != "Dana" || got.OEmbed.Width != 640 || !strings.Contains(got.OEmbed.HTML, "/embed/1") {
+		t.Errorf("oembed %+v", got.OEmbed)
+	}
+
+	page, err := server.fetchPage(t.Context(), site.URL+"/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := generateSummary(page); summary != `Video "Knitting Basics" by Dana on Tube.` {
+		t.Errorf("summary = %q", summary)
+	}
+}
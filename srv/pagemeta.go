@@ -0,0 +1,392 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"srv.exe.dev/db/dbgen"
+)
+
+// maxPageBytes caps how much of a page is read for metadata.
+const maxPageBytes = 1 << 20
+
+// PageMetadata is everything the server reads from a fetched page. URLs
+// are absolute. Fields are empty when the page doesn't provide them.
+type PageMetadata struct {
+	URL          string     `json:"url"` // after redirects
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	CanonicalURL string     `json:"canonical_url,omitempty"`
+	SiteName     string     `json:"site_name,omitempty"`
+	Type         string     `json:"type,omitempty"` // og:type
+	Author       string     `json:"author,omitempty"`
+	Published    string     `json:"published,omitempty"` // as written on the page
+	Images       []string   `json:"images,omitempty"`    // preferred first
+	Favicons     []PageIcon `json:"favicons,omitempty"`
+	Manifest     string     `json:"manifest,omitempty"` // web app manifest URL
+	Language     string     `json:"language,omitempty"`
+	Text         string     `json:"-"` // main text, whitespace collapsed
+
+	Structured *StructuredData `json:"structured,omitempty"` // JSON-LD or microdata
+	OEmbed     *OEmbed         `json:"oembed,omitempty"`
+	OEmbedURL  string          `json:"oembed_url,omitempty"` // discovered endpoint
+
+	lead string // first substantial paragraph
+}
+
+// PageIcon is a <link rel="icon"> or similar.
+type PageIcon struct {
+	URL   string `json:"url"`
+	Rel   string `json:"rel"`
+	Sizes string `json:"sizes,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// fetchPage fetches rawURL and extracts its metadata, adding the oEmbed
+// response for sites that have one.
+func (s *Server) fetchPage(ctx context.Context, rawURL string) (*PageMetadata, error) {
+	page, err := s.fetchHTML(ctx, rawURL)
+	if err != nil {
+		// Some providers serve no usable page but do answer oEmbed.
+		page = &PageMetadata{URL: rawURL}
+		if !s.addOEmbed(ctx, page, rawURL) {
+			return nil, err
+		}
+		return page, nil
+	}
+	s.addOEmbed(ctx, page, rawURL)
+	return page, nil
+}
+
+// fetchHTML fetches rawURL and parses it. Responses that aren't HTML give
+// metadata with only URL set.
+func (s *Server) fetchHTML(ctx context.Context, rawURL string) (*PageMetadata, error) {
+	req, err := s.newFetchRequest(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("fetch %s: %s", rawURL, resp.Status)
+	}
+
+	page := &PageMetadata{URL: resp.Request.URL.String()}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); contentType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return page, nil
+	}
+	body, err := charset.NewReader(io.LimitReader(resp.Body, maxPageBytes), contentType)
+	if err != nil {
+		return nil, err
+	}
+	if err := page.parse(body); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", rawURL, err)
+	}
+	return page, nil
+}
+
+// parse reads an HTML document into p, resolving links against p.URL or
+// the document's <base>.
+func (p *PageMetadata) parse(r io.Reader) error {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return err
+	}
+	base, _ := url.Parse(p.URL)
+	if base == nil {
+		base = &url.URL{}
+	}
+	if b := findElement(doc, atom.Base); b != nil {
+		if href, err := base.Parse(attr(b, "href")); err == nil && attr(b, "href") != "" {
+			base = href
+		}
+	}
+	resolve := func(ref string) string {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			return ""
+		}
+		u, err := base.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		return u.String()
+	}
+
+	meta := map[string]string{} // first value for each name, property or itemprop
+	var title, canonical, imageSrc string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Html:
+				p.Language = strings.TrimSpace(attr(n, "lang"))
+			case atom.Title:
+				if title == "" && n.Namespace == "" {
+					title = nodeText(n)
+				}
+			case atom.Meta:
+				content := strings.TrimSpace(attr(n, "content"))
+				for _, key := range []string{attr(n, "property"), attr(n, "name"), attr(n, "itemprop"), attr(n, "http-equiv")} {
+					key = strings.ToLower(strings.TrimSpace(key))
+					if key != "" && content != "" && meta[key] == "" {
+						meta[key] = content
+					}
+				}
+			case atom.Link:
+				href := resolve(attr(n, "href"))
+				if href == "" {
+					break
+				}
+				rels := strings.Fields(strings.ToLower(attr(n, "rel")))
+				if linkType := strings.ToLower(attr(n, "type")); slices.Contains(rels, "alternate") &&
+					(linkType == "application/json+oembed" || linkType == "text/json+oembed") && p.OEmbedURL == "" {
+					p.OEmbedURL = href
+				}
+				for _, rel := range rels {
+					switch rel {
+					case "canonical":
+						if canonical == "" {
+							canonical = href
+						}
+					case "image_src":
+						if imageSrc == "" {
+							imageSrc = href
+						}
+					case "manifest":
+						if p.Manifest == "" {
+							p.Manifest = href
+						}
+					case "icon", "apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon":
+						p.Favicons = append(p.Favicons, PageIcon{
+							URL:   href,
+							Rel:   strings.Join(rels, " "),
+							Sizes: strings.TrimSpace(attr(n, "sizes")),
+							Type:  strings.TrimSpace(attr(n, "type")),
+						})
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := strings.TrimSpace(meta[k]); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	p.Title = first("og:title", "twitter:title")
+	if p.Title == "" {
+		p.Title = title
+	}
+	p.Description = first("og:description", "description", "twitter:description")
+	p.CanonicalURL = canonical
+	if p.CanonicalURL == "" {
+		p.CanonicalURL = resolve(meta["og:url"])
+	}
+	p.SiteName = first("og:site_name", "application-name")
+	p.Type = first("og:type")
+	p.Author = first("author", "article:author", "twitter:creator")
+	p.Published = first("article:published_time", "datepublished", "date", "dc.date.issued", "dc.date")
+	if p.Language == "" {
+		p.Language = first("content-language", "og:locale")
+	}
+	for _, ref := range []string{meta["og:image"], meta["og:image:url"], meta["og:image:secure_url"], meta["twitter:image"], meta["twitter:image:src"], imageSrc} {
+		if img := resolve(ref); img != "" && !slices.Contains(p.Images, img) {
+			p.Images = append(p.Images, img)
+		}
+	}
+
+	// Structured data fills in what the meta tags leave out.
+	if sd := findStructuredData(doc); sd != nil {
+		for _, ref := range []*string{&sd.Image, &sd.URL, &sd.EmbedURL, &sd.CodeRepository} {
+			*ref = resolve(*ref)
+		}
+		p.Structured = sd
+		if p.Title == "" {
+			p.Title = sd.Name
+		}
+		if p.Description == "" {
+			p.Description = sd.Description
+		}
+		if p.Author == "" {
+			p.Author = sd.Author
+		}
+		if p.Published == "" {
+			p.Published = sd.Published
+		}
+		if sd.Image != "" && !slices.Contains(p.Images, sd.Image) {
+			p.Images = append(p.Images, sd.Image)
+		}
+	}
+
+	content := mainContent(doc)
+	p.Text = collapseSpace(nodeText(content))
+	p.lead = leadParagraph(content)
+	return nil
+}
+
+// mainContent returns the page's <article>, <main> or role="main" element
+// when it has a reasonable amount of text, and <body> otherwise.
+func mainContent(doc *html.Node) *html.Node {
+	for _, find := range []func(*html.Node) bool{
+		func(n *html.Node) bool { return n.DataAtom == atom.Article },
+		func(n *html.Node) bool { return n.DataAtom == atom.Main || attr(n, "role") == "main" },
+	} {
+		if n := findNode(doc, find); n != nil && len(collapseSpace(nodeText(n))) >= 200 {
+			return n
+		}
+	}
+	if body := findElement(doc, atom.Body); body != nil {
+		return body
+	}
+	return doc
+}
+
+// leadParagraph returns the first <p> under n that reads like prose.
+func leadParagraph(n *html.Node) string {
+	var lead string
+	findNode(n, func(c *html.Node) bool {
+		if c.DataAtom != atom.P {
+			return false
+		}
+		text := collapseSpace(nodeText(c))
+		if len(text) < 100 || strings.Contains(text, "{") || strings.Contains(text, "function") || strings.Contains(text, "var ") {
+			return false
+		}
+		lead = truncate(text, 300)
+		return true
+	})
+	return lead
+}
+
+// skippedElements hold no readable page text.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Iframe: true, atom.Nav: true, atom.Footer: true,
+	atom.Header: true, atom.Aside: true, atom.Menu: true, atom.Form: true,
+}
+
+// nodeText returns the text under n, with elements separated by spaces.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && skippedElements[n.DataAtom]:
+			return
+		case n.Type == html.ElementNode:
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	return findNode(n, func(c *html.Node) bool { return c.DataAtom == a })
+}
+
+// findNode returns the first element under n, depth first, for which
+// match is true.
+func findNode(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && match(c) {
+			return c
+		}
+		if found := findNode(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// saveBookmarkMetadata stores the page's structured data and oEmbed
+// response for a bookmark, clearing what the page no longer has.
+func (s *Server) saveBookmarkMetadata(ctx context.Context, bookmarkID int64, page *PageMetadata) error {
+	params := dbgen.UpsertBookmarkMetadataParams{BookmarkID: bookmarkID}
+	for _, f := range []struct {
+		dst   **string
+		value any
+		set   bool
+	}{
+		{&params.StructuredData, page.Structured, page.Structured != nil},
+		{&params.Oembed, page.OEmbed, page.OEmbed != nil},
+	} {
+		if !f.set {
+			continue
+		}
+		b, err := json.Marshal(f.value)
+		if err != nil {
+			return err
+		}
+		*f.dst = strPtr(string(b))
+	}
+	return dbgen.New(s.DB).UpsertBookmarkMetadata(ctx, params)
+}
+
+// loadBookmarkMetadata returns the structured data and oEmbed response
+// stored for a bookmark. Either is nil when there is none.
+func (s *Server) loadBookmarkMetadata(ctx context.Context, bookmarkID int64) (*StructuredData, *OEmbed, error) {
+	m, err := dbgen.New(s.DB).GetBookmarkMetadata(ctx, dbgen.GetBookmarkMetadataParams{OwnerID: ownerID(ctx), BookmarkID: bookmarkID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	var sd *StructuredData
+	var oe *OEmbed
+	if m.StructuredData != nil {
+		if err := json.Unmarshal([]byte(*m.StructuredData), &sd); err != nil {
+			return nil, nil, err
+		}
+	}
+	if m.Oembed != nil {
+		if err := json.Unmarshal([]byte(*m.Oembed), &oe); err != nil {
+			return nil, nil, err
+		}
+	}
+	return sd, oe, nil
+}
@@ -0,0 +1,87 @@
+package srv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFetchPage(t *testing.T) {
+	article := strings.Repeat("Sourdough needs a lively starter and patience. ", 8)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/posts/bread", 302)
+	})
+	mux.HandleFunc("/posts/bread", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte(`<!doctype html><html lang="en-GB"><head>
+<title>Bread &amp; Butter</title>
+<meta content="Caf` + "\xe9" + ` baking notes" name="description">
+<meta CONTENT="The Bakery" PROPERTY="og:site_name">
+<meta property="og:type" content="article">
+<meta name="author" content="Ann Baker">
+<meta property="article:published_time" content="2024-03-01T09:00:00Z">
+<meta property="og:image" content="/img/loaf.jpg">
+<meta name="twitter:image" content="https://cdn.example.com/loaf.png">
+<link rel="canonical" href="https://bakery.example.com/bread">
+<link rel="icon" href="/favicon-32.png" sizes="32x32" type="image/png">
+<link rel="apple-touch-icon" href="/touch.png" sizes="180x180">
+<script type="application/ld+json">{"@type": "Article"}</script>
+</head><body><nav>Home About</nav>
+<article><p>` + article + `</p></article>
+<footer>Copyright</footer></body></html>`))
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4"))
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	server, err := New(filepath.Join(t.TempDir(), "page.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := server.fetchPage(context.Background(), site.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PageMetadata{
+		URL:          site.URL + "/posts/bread",
+		Title:        "Bread & Butter",
+		Description:  "Café baking notes",
+		CanonicalURL: "https://bakery.example.com/bread",
+		SiteName:     "The Bakery",
+		Type:         "article",
+		Author:       "Ann Baker",
+		Published:    "2024-03-01T09:00:00Z",
+		Language:     "en-GB",
+		Structured:   &StructuredData{Type: "Article"},
+	}
+	got := *page
+	got.Images, got.Favicons, got.Text, got.lead = nil, nil, "", ""
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if len(page.Images) != 2 || page.Images[0] != site.URL+"/img/loaf.jpg" || page.Images[1] != "https://cdn.example.com/loaf.png" {
+		t.Errorf("images = %q", page.Images)
+	}
+	if len(page.Favicons) != 2 || page.Favicons[0] != (PageIcon{URL: site.URL + "/favicon-32.png", Rel: "icon", Sizes: "32x32", Type: "image/png"}) {
+		t.Errorf("favicons = %+v", page.Favicons)
+	}
+	if page.Text != strings.TrimSpace(article) {
+		t.Errorf("text = %q", page.Text)
+	}
+	if summary := generateSummary(page); !strings.HasPrefix(summary, "From The Bakery. Article. Café baking notes By Ann Baker. Published March 1, 2024.") {
+		t.Errorf("summary = %q", summary)
+	}
+
+	pdf, err := server.fetchPage(context.Background(), site.URL+"/file.pdf")
+	if err != nil || pdf.Title != "" || pdf.URL != site.URL+"/file.pdf" {
+		t.Errorf("non-HTML page: %+v, %v", pdf, err)
+	}
+}
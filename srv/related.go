@@ -0,0 +1,194 @@
+package srv
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// relatedWeights scale each similarity signal (all in [0, 1]) into the
+// combined score. Shared tags are the strongest hint of a shared topic.
+var relatedWeights = struct {
+	Tags, Collections, Keywords, Domain, Embedding float64
+}{Tags: 0.35, Collections: 0.15, Keywords: 0.2, Domain: 0.1, Embedding: 0.2}
+
+// RelatedScore is the per-signal breakdown of a related bookmark's score.
+// Each field is that signal's weighted contribution.
+type RelatedScore struct {
+	Tags        float64 `json:"tags"`
+	Collections float64 `json:"collections"`
+	Keywords    float64 `json:"keywords"`
+	Domain      float64 `json:"domain"`
+	Embedding   float64 `json:"embedding"`
+}
+
+type RelatedBookmark struct {
+	Bookmark          dbgen.Bookmark `json:"bookmark"`
+	Score             float64        `json:"score"`
+	Breakdown         RelatedScore   `json:"breakdown"`
+	SharedTags        []string       `json:"shared_tags"`
+	SharedCollections []string       `json:"shared_collections"`
+	SharedKeywords    []string       `json:"shared_keywords"`
+}
+
+// HandleRelatedBookmarks returns the bookmarks most similar to {id}.
+func (s *Server) HandleRelatedBookmarks(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	q := dbgen.New(s.DB)
+	if _, err := q.GetBookmark(r.Context(), dbgen.GetBookmarkParams{OwnerID: ownerID(r.Context()), ID: id}); err != nil {
+		writeError(w, "not found", 404)
+		return
+	}
+
+	bookmarks, err := q.ListAllBookmarks(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	tagRows, err := q.ListAllBookmarkTagNames(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	collections, err := q.ListCollections(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	collectionRows, err := q.ListAllBookmarkCollections(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	vectors := make(map[int64][]float32)
+	if s.Embedder != nil {
+		rows, err := q.ListEmbeddings(r.Context(), dbgen.ListEmbeddingsParams{OwnerID: ownerID(r.Context()), Model: s.Embedder.Model()})
+		if err != nil {
+			writeError(w, err.Error(), 500)
+			return
+		}
+		for _, row := range rows {
+			vectors[row.BookmarkID] = decodeVector(row.Vector)
+		}
+	}
+
+	tags := make(map[int64][]string)
+	for _, row := range tagRows {
+		tags[row.BookmarkID] = append(tags[row.BookmarkID], row.Name)
+	}
+	collectionNames := make(map[int64]string, len(collections))
+	for _, c := range collections {
+		collectionNames[c.ID] = c.Name
+	}
+	inCollections := make(map[int64][]string)
+	for _, row := range collectionRows {
+		inCollections[row.BookmarkID] = append(inCollections[row.BookmarkID], collectionNames[row.CollectionID])
+	}
+
+	var target dbgen.Bookmark
+	for _, b := range bookmarks {
+		if b.ID == id {
+			target = b
+			break
+		}
+	}
+	targetKeywords := bookmarkKeywords(target)
+	targetHost := bookmarkHost(target.Url)
+
+	related := []RelatedBookmark{}
+	for _, b := range bookmarks {
+		if b.ID == id {
+			continue
+		}
+		var rb RelatedBookmark
+		var tagScore, collectionScore, keywordScore float64
+		rb.SharedTags, tagScore = overlap(tags[id], tags[b.ID])
+		rb.SharedCollections, collectionScore = overlap(inCollections[id], inCollections[b.ID])
+		rb.SharedKeywords, keywordScore = overlap(targetKeywords, bookmarkKeywords(b))
+
+		rb.Breakdown.Tags = relatedWeights.Tags * tagScore
+		rb.Breakdown.Collections = relatedWeights.Collections * collectionScore
+		rb.Breakdown.Keywords = relatedWeights.Keywords * keywordScore
+		if targetHost != "" && bookmarkHost(b.Url) == targetHost {
+			rb.Breakdown.Domain = relatedWeights.Domain
+		}
+		if a, ok := vectors[id]; ok {
+			if v, ok := vectors[b.ID]; ok {
+				rb.Breakdown.Embedding = relatedWeights.Embedding * max(cosine(a, v), 0)
+			}
+		}
+
+		rb.Score = rb.Breakdown.Tags + rb.Breakdown.Collections + rb.Breakdown.Keywords +
+			rb.Breakdown.Domain + rb.Breakdown.Embedding
+		if rb.Score <= 0 {
+			continue
+		}
+		rb.Bookmark = b
+		related = append(related, rb)
+	}
+
+	slices.SortFunc(related, func(a, b RelatedBookmark) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Bookmark.ID, b.Bookmark.ID)
+	})
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	writeJSON(w, map[string]any{"bookmark_id": id, "related": related})
+}
+
+// overlap returns the case-insensitive intersection of a and b and their
+// Jaccard similarity.
+func overlap(a, b []string) ([]string, float64) {
+	shared := []string{}
+	if len(a) == 0 || len(b) == 0 {
+		return shared, 0
+	}
+	set := make(map[string]bool, len(a))
+	for _, s := range a {
+		set[strings.ToLower(s)] = true
+	}
+	union := len(set)
+	seen := make(map[string]bool, len(b))
+	for _, s := range b {
+		key := strings.ToLower(s)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if set[key] {
+			shared = append(shared, s)
+		} else {
+			union++
+		}
+	}
+	return shared, float64(len(shared)) / float64(union)
+}
+
+func bookmarkKeywords(b dbgen.Bookmark) []string {
+	var kws []string
+	if b.Keywords != nil {
+		json.Unmarshal([]byte(*b.Keywords), &kws)
+	}
+	return kws
+}
+
+func bookmarkHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
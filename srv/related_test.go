@@ -0,0 +1,62 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestRelatedBookmarks(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "related.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+
+	create := func(u, title, keywords string) dbgen.Bookmark {
+		b, err := q.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: ownerID(ctx), Url: u, Title: title, SourceType: "web"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.UpdateBookmarkAnalysis(ctx, dbgen.UpdateBookmarkAnalysisParams{OwnerID: ownerID(ctx), ID: b.ID, Keywords: strPtr(keywords)})
+		return b
+	}
+	target := create("https://go.dev/blog/generics", "Generics in Go", `["generics","types","go"]`)
+	sameTopic := create("https://example.com/go-types", "Go type parameters", `["types","go"]`)
+	sameSite := create("https://www.go.dev/doc", "Go docs", `["documentation"]`)
+	create("https://example.org/cake", "Chocolate cake", `["baking"]`)
+
+	tag, _ := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: "golang", Color: strPtr("#000000")})
+	q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: target.ID, TagID: tag.ID})
+	q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: sameTopic.ID, TagID: tag.ID})
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/bookmarks/%d/related", target.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(target.ID))
+	w := httptest.NewRecorder()
+	server.HandleRelatedBookmarks(w, req)
+	if w.Code != 200 {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Related []RelatedBookmark `json:"related"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Related) != 2 {
+		t.Fatalf("expected 2 related bookmarks, got %+v", resp.Related)
+	}
+	first, second := resp.Related[0], resp.Related[1]
+	if first.Bookmark.ID != sameTopic.ID || first.Breakdown.Tags == 0 || len(first.SharedKeywords) != 2 {
+		t.Errorf("unexpected top result %+v", first)
+	}
+	if second.Bookmark.ID != sameSite.ID || second.Breakdown.Domain == 0 || second.Breakdown.Tags != 0 {
+		t.Errorf("unexpected second result %+v", second)
+	}
+}
@@ -1,52 +1,362 @@
 package srv
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
+	"html"
 	"net/http"
 	"net/url"
-	"regexp"
+	"strconv"
 	"strings"
-	"time"
 
 	"srv.exe.dev/db/dbgen"
 )
 
+// bookmarkColumns lists the bookmarks columns in dbgen.Bookmark scan order.
+const bookmarkColumns = `b.id, b.url, b.title, b.description, b.summary, b.source_type,
+	b.favicon_url, b.image_url, b.created_at, b.updated_at, b.keywords`
+
+// urlHostSQL extracts the lower-cased host from b.url.
+const urlHostSQL = `lower(substr(substr(b.url, instr(b.url, '://') + 3), 1,
+	instr(substr(b.url, instr(b.url, '://') + 3) || '/', '/') - 1))`
+
+// ftsWeights are the bm25 column weights for bookmarks_fts columns
+// (title, description, summary, keywords, url, tags).
+const ftsWeights = "10.0, 4.0, 2.0, 3.0, 1.0, 5.0"
+
+// SearchResult is a bookmark matched by HandleSearch. TitleHighlight and
+// Snippet are HTML-escaped with matches wrapped in <mark> tags.
+type SearchResult struct {
+	dbgen.Bookmark
+	TitleHighlight string  `json:"title_highlight,omitempty"`
+	Snippet        string  `json:"snippet,omitempty"`
+	Score          float64 `json:"score"`
+	Similarity     float64 `json:"similarity,omitempty"` // cosine similarity in semantic and hybrid modes
+}
+
 func (s *Server) HandleSearch(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
-	if query == "" {
+	if strings.TrimSpace(query) == "" {
 		writeError(w, "query required", 400)
 		return
 	}
+	sq, err := parseSearchQuery(query)
+	if err != nil {
+		writeError(w, err.Error(), 400)
+		return
+	}
+	if sq.empty() {
+		writeError(w, "query required", 400)
+		return
+	}
+
+	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
 
-	// Search including keywords
-	like := "%" + query + "%"
-	rows, err := s.DB.QueryContext(r.Context(), `
-		SELECT * FROM bookmarks 
-		WHERE title LIKE ? 
-		   OR description LIKE ? 
-		   OR summary LIKE ? 
-		   OR keywords LIKE ?
-		ORDER BY created_at DESC
-		LIMIT 50
-	`, like, like, like, like)
+	mode := r.URL.Query().Get("mode")
+	if len(sq.Terms) == 0 {
+		// Filter-only queries have nothing to embed.
+		mode = "keyword"
+	}
+	var results []SearchResult
+	var total int64
+	switch mode {
+	case "", "keyword":
+		mode = "keyword"
+		results, total, err = searchBookmarks(r.Context(), s.DB, sq, limit, offset)
+	case "semantic":
+		results, total, err = s.semanticSearch(r.Context(), sq, limit, offset)
+	case "hybrid":
+		results, total, err = s.hybridSearch(r.Context(), sq, limit, offset)
+	default:
+		writeError(w, "mode must be keyword, semantic or hybrid", 400)
+		return
+	}
 	if err != nil {
 		writeError(w, err.Error(), 500)
 		return
 	}
+	writeJSON(w, map[string]any{
+		"bookmarks": results,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+		"mode":      mode,
+	})
+}
+
+// searchBookmarks runs sq against bookmarks_fts, ranking by bm25 when the
+// query has text terms and by recency when it only has filters.
+func searchBookmarks(ctx context.Context, db *sql.DB, sq searchQuery, limit, offset int64) ([]SearchResult, int64, error) {
+	from := "bookmarks b"
+	extra := "NULL, NULL, 0"
+	order := "b.created_at DESC"
+	var where []string
+	var args []any
+
+	if len(sq.Terms) > 0 {
+		from = "bookmarks_fts JOIN bookmarks b ON b.id = bookmarks_fts.rowid"
+		// Title matches weigh most, then tags, description, keywords,
+		// summary and finally URL.
+		extra = `highlight(bookmarks_fts, 0, char(2), char(3)),
+			snippet(bookmarks_fts, -1, char(2), char(3), '…', 16),
+			-bm25(bookmarks_fts, ` + ftsWeights + `)`
+		order = "bm25(bookmarks_fts, " + ftsWeights + "), b.created_at DESC"
+		where = append(where, "bookmarks_fts MATCH ?")
+		args = append(args, ftsMatch(sq.Terms, "AND"))
+	}
+	cwhere, cargs := searchConditions(ctx, sq)
+	where = append(where, cwhere...)
+	args = append(args, cargs...)
+	whereSQL := "WHERE " + strings.Join(where, " AND ")
+
+	var total int64
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+from+" "+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count search results: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s, %s FROM %s %s ORDER BY %s LIMIT ? OFFSET ?",
+		bookmarkColumns, extra, from, whereSQL, order,
+	), append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		var title, snippet *string
+		b := &res.Bookmark
+		if err := rows.Scan(&b.ID, &b.Url, &b.Title, &b.Description, &b.Summary,
+			&b.SourceType, &b.FaviconUrl, &b.ImageUrl, &b.CreatedAt, &b.UpdatedAt, &b.Keywords,
+			&title, &snippet, &res.Score); err != nil {
+			return nil, 0, err
+		}
+		if title != nil {
+			res.TitleHighlight = markHighlights(*title)
+		}
+		if snippet != nil {
+			res.Snippet = markHighlights(*snippet)
+		}
+		results = append(results, res)
+	}
+	return results, total, rows.Err()
+}
+
+// searchConditions returns the WHERE clauses limiting results to the
+// current user's bookmarks and applying sq's excluded terms and filters.
+func searchConditions(ctx context.Context, sq searchQuery) ([]string, []any) {
+	where := []string{"b.owner_id = ?"}
+	args := []any{ownerID(ctx)}
+	if len(sq.Excluded) > 0 {
+		where = append(where, "b.id NOT IN (SELECT rowid FROM bookmarks_fts WHERE bookmarks_fts MATCH ?)")
+		args = append(args, ftsMatch(sq.Excluded, "OR"))
+	}
+	for _, f := range sq.Filters {
+		clause, fargs := searchFilterSQL(f)
+		if f.Negate {
+			clause = "NOT " + clause
+		}
+		where = append(where, clause)
+		args = append(args, fargs...)
+	}
+	return where, args
+}
+
+// minSemanticSimilarity drops semantic matches that are mostly noise.
+const minSemanticSimilarity = 0.1
+
+// hybridCandidates bounds how many keyword results feed into hybrid ranking.
+const hybridCandidates = 200
+
+// semanticSearch ranks bookmarks by embedding similarity to the query
+// terms, honouring exclusions and filters.
+func (s *Server) semanticSearch(ctx context.Context, sq searchQuery, limit, offset int64) ([]SearchResult, int64, error) {
+	ranked, err := s.filteredSemanticRank(ctx, sq)
+	if err != nil {
+		return nil, 0, err
+	}
+	page := pageScored(ranked, limit, offset)
+	bookmarks, err := bookmarksByID(ctx, s.DB, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	results := []SearchResult{}
+	for _, r := range page {
+		if b, ok := bookmarks[r.ID]; ok {
+			results = append(results, SearchResult{Bookmark: b, Score: r.Score, Similarity: r.Score})
+		}
+	}
+	return results, int64(len(ranked)), nil
+}
+
+// hybridSearch merges keyword and semantic rankings with reciprocal rank
+// fusion, so a bookmark ranked well by either appears near the top.
+func (s *Server) hybridSearch(ctx context.Context, sq searchQuery, limit, offset int64) ([]SearchResult, int64, error) {
+	const k = 60.0
+	keyword, _, err := searchBookmarks(ctx, s.DB, sq, hybridCandidates, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	semantic, err := s.filteredSemanticRank(ctx, sq)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	scores := make(map[int64]float64)
+	similarity := make(map[int64]float64)
+	byID := make(map[int64]SearchResult)
+	for i, r := range keyword {
+		scores[r.ID] += 1 / (k + float64(i+1))
+		byID[r.ID] = r
+	}
+	for i, r := range semantic {
+		scores[r.ID] += 1 / (k + float64(i+1))
+		similarity[r.ID] = r.Score
+	}
+	merged := make([]scoredID, 0, len(scores))
+	for id, score := range scores {
+		merged = append(merged, scoredID{ID: id, Score: score})
+	}
+	sortScored(merged)
+
+	page := pageScored(merged, limit, offset)
+	var missing []scoredID
+	for _, r := range page {
+		if _, ok := byID[r.ID]; !ok {
+			missing = append(missing, r)
+		}
+	}
+	bookmarks, err := bookmarksByID(ctx, s.DB, missing)
+	if err != nil {
+		return nil, 0, err
+	}
+	results := []SearchResult{}
+	for _, r := range page {
+		res, ok := byID[r.ID]
+		if !ok {
+			b, found := bookmarks[r.ID]
+			if !found {
+				continue
+			}
+			res = SearchResult{Bookmark: b}
+		}
+		res.Score = r.Score
+		res.Similarity = similarity[r.ID]
+		results = append(results, res)
+	}
+	return results, int64(len(merged)), nil
+}
+
+// filteredSemanticRank ranks bookmarks against the query terms and drops
+// those excluded by sq's exclusions and filters.
+func (s *Server) filteredSemanticRank(ctx context.Context, sq searchQuery) ([]scoredID, error) {
+	texts := make([]string, len(sq.Terms))
+	for i, t := range sq.Terms {
+		texts[i] = t.Text
+	}
+	ranked, err := s.semanticRank(ctx, strings.Join(texts, " "), minSemanticSimilarity)
+	if err != nil {
+		return nil, err
+	}
+	where, args := searchConditions(ctx, sq)
+	rows, err := s.DB.QueryContext(ctx, "SELECT b.id FROM bookmarks b WHERE "+strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
+	allowed := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		allowed[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	filtered := ranked[:0]
+	for _, r := range ranked {
+		if allowed[r.ID] {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
 
-	var bookmarks []dbgen.Bookmark
+func pageScored(ids []scoredID, limit, offset int64) []scoredID {
+	if offset >= int64(len(ids)) {
+		return nil
+	}
+	return ids[offset:min(offset+limit, int64(len(ids)))]
+}
+
+// bookmarksByID loads the given bookmarks keyed by ID.
+func bookmarksByID(ctx context.Context, db *sql.DB, ids []scoredID) (map[int64]dbgen.Bookmark, error) {
+	out := make(map[int64]dbgen.Bookmark, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := []any{ownerID(ctx)}
+	for i, r := range ids {
+		placeholders[i] = "?"
+		args = append(args, r.ID)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT "+bookmarkColumns+" FROM bookmarks b WHERE b.owner_id = ? AND b.id IN ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 	for rows.Next() {
 		var b dbgen.Bookmark
-		var keywords *string
 		if err := rows.Scan(&b.ID, &b.Url, &b.Title, &b.Description, &b.Summary,
-			&b.SourceType, &b.FaviconUrl, &b.ImageUrl, &b.CreatedAt, &b.UpdatedAt, &keywords); err == nil {
-			bookmarks = append(bookmarks, b)
+			&b.SourceType, &b.FaviconUrl, &b.ImageUrl, &b.CreatedAt, &b.UpdatedAt, &b.Keywords); err != nil {
+			return nil, err
 		}
+		out[b.ID] = b
 	}
-	writeJSON(w, map[string]any{"bookmarks": bookmarks})
+	return out, rows.Err()
+}
+
+func searchFilterSQL(f searchFilter) (string, []any) {
+	switch f.Field {
+	case "tag":
+		return `EXISTS (SELECT 1 FROM bookmark_tags bt JOIN tags t ON t.id = bt.tag_id
+			WHERE bt.bookmark_id = b.id AND t.name = ? COLLATE NOCASE)`, []any{f.Value}
+	case "collection":
+		return `EXISTS (SELECT 1 FROM bookmark_collections bc JOIN collections c ON c.id = bc.collection_id
+			WHERE bc.bookmark_id = b.id AND c.name = ? COLLATE NOCASE)`, []any{f.Value}
+	case "source":
+		return "b.source_type = ? COLLATE NOCASE", []any{f.Value}
+	case "domain":
+		domain := strings.ToLower(strings.TrimPrefix(f.Value, "www."))
+		return "(" + urlHostSQL + " = ? OR " + urlHostSQL + " LIKE ?)", []any{domain, "%." + domain}
+	case "before":
+		return "b.created_at < ?", []any{f.Value}
+	case "after":
+		return "b.created_at >= ?", []any{f.Value}
+	}
+	return "1", nil
+}
+
+// markHighlights HTML-escapes FTS5 output and turns the \x02/\x03 match
+// markers into <mark> tags.
+func markHighlights(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, "\x02", "<mark>")
+	return strings.ReplaceAll(s, "\x03", "</mark>")
 }
 
 type Metadata struct {
@@ -66,7 +376,7 @@ func (s *Server) HandleFetchMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	meta, err := fetchMetadata(req.URL)
+	meta, err := s.fetchMetadata(r.Context(), req.URL)
 	if err != nil {
 		writeError(w, err.Error(), 500)
 		return
@@ -74,46 +384,20 @@ func (s *Server) HandleFetchMetadata(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, meta)
 }
 
-func fetchMetadata(rawURL string) (*Metadata, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(rawURL)
+func (s *Server) fetchMetadata(ctx context.Context, rawURL string) (*Metadata, error) {
+	page, err := s.fetchPage(ctx, rawURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20)) // 1MB max
-	html := string(body)
-
-	parsedURL, _ := url.Parse(rawURL)
 	meta := &Metadata{
-		SourceType: detectSourceType(rawURL),
-		Favicon:    fmt.Sprintf("%s://%s/favicon.ico", parsedURL.Scheme, parsedURL.Host),
-	}
-
-	// Extract title
-	if m := regexp.MustCompile(`<title[^>]*>([^<]+)</title>`).FindStringSubmatch(html); len(m) > 1 {
-		meta.Title = strings.TrimSpace(m[1])
-	}
-
-	// Extract og:title
-	if m := regexp.MustCompile(`<meta[^>]+property=["']og:title["'][^>]+content=["']([^"']+)["']`).FindStringSubmatch(html); len(m) > 1 {
-		meta.Title = strings.TrimSpace(m[1])
-	}
-
-	// Extract description
-	if m := regexp.MustCompile(`<meta[^>]+name=["']description["'][^>]+content=["']([^"']+)["']`).FindStringSubmatch(html); len(m) > 1 {
-		meta.Description = strings.TrimSpace(m[1])
+		Title:       page.Title,
+		Description: page.Description,
+		SourceType:  detectSourceType(rawURL),
 	}
-	if m := regexp.MustCompile(`<meta[^>]+property=["']og:description["'][^>]+content=["']([^"']+)["']`).FindStringSubmatch(html); len(m) > 1 {
-		meta.Description = strings.TrimSpace(m[1])
+	if len(page.Images) > 0 {
+		meta.Image = page.Images[0]
 	}
-
-	// Extract og:image
-	if m := regexp.MustCompile(`<meta[^>]+property=["']og:image["'][^>]+content=["']([^"']+)["']`).FindStringSubmatch(html); len(m) > 1 {
-		meta.Image = strings.TrimSpace(m[1])
-	}
-
+	meta.Favicon = s.cacheFavicon(ctx, rawURL, page)
 	return meta, nil
 }
 
@@ -126,9 +410,13 @@ func (s *Server) HandleWebSearch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use DuckDuckGo instant answers API (no API key needed)
-	client := &http.Client{Timeout: 10 * time.Second}
 	searchURL := fmt.Sprintf("https://api.duckduckgo.com/?q=%s&format=json&no_html=1", url.QueryEscape(query))
-	resp, err := client.Get(searchURL)
+	searchReq, err := s.newFetchRequest(r.Context(), searchURL)
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	resp, err := s.httpClient().Do(searchReq)
 	if err != nil {
 		writeError(w, err.Error(), 500)
 		return
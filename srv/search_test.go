@@ -0,0 +1,310 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"srv.exe.dev/db"
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  searchQuery
+	}{
+		{
+			input: `golang -java`,
+			want: searchQuery{
+				Terms:    []searchTerm{{Text: "golang"}},
+				Excluded: []searchTerm{{Text: "java"}},
+			},
+		},
+		{
+			input: `"vector database" tag:go -source:youtube`,
+			want: searchQuery{
+				Terms: []searchTerm{{Text: "vector database", Phrase: true}},
+				Filters: []searchFilter{
+					{Field: "tag", Value: "go"},
+					{Field: "source", Value: "youtube", Negate: true},
+				},
+			},
+		},
+		{
+			input: `collection:"Reading List" domain:github.com after:2025-01-01`,
+			want: searchQuery{
+				Filters: []searchFilter{
+					{Field: "collection", Value: "Reading List"},
+					{Field: "domain", Value: "github.com"},
+					{Field: "after", Value: "2025-01-01"},
+				},
+			},
+		},
+		{
+			input: `http://example.com - ""`,
+			want: searchQuery{
+				Terms: []searchTerm{{Text: "http://example.com"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseSearchQuery(tt.input)
+		if err != nil {
+			t.Errorf("parseSearchQuery(%q) error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSearchQuery(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := parseSearchQuery("before:yesterday"); err == nil {
+		t.Error("expected error for invalid date")
+	}
+}
+
+func TestFTSMatchQuotesInput(t *testing.T) {
+	got := ftsMatch([]searchTerm{{Text: `a"b`}, {Text: "NEAR(x", Phrase: true}}, "AND")
+	want := `"a""b"* AND "NEAR(x"`
+	if got != want {
+		t.Errorf("ftsMatch = %q, want %q", got, want)
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "search.sqlite3"), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+
+	create := func(u, title, desc, source string) dbgen.Bookmark {
+		b, err := q.CreateBookmark(ctx, dbgen.CreateBookmarkParams{
+			OwnerID: ownerID(ctx),
+			Url:     u, Title: title, Description: strPtr(desc), SourceType: source,
+		})
+		if err != nil {
+			t.Fatalf("create bookmark: %v", err)
+		}
+		return b
+	}
+	goRepo := create("https://github.com/golang/go", "The Go programming language", "Go source tree", "web")
+	goTalk := create("https://www.youtube.com/watch?v=abc", "Concurrency is not parallelism", "A Go talk by Rob Pike", "youtube")
+	create("https://docs.python.org/3/", "Python docs", "Python <3> documentation", "web")
+
+	tag, _ := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: "go", Color: strPtr("#6366f1")})
+	q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: goTalk.ID, TagID: tag.ID})
+	col, _ := q.CreateCollection(ctx, dbgen.CreateCollectionParams{OwnerID: ownerID(ctx), Name: "Reading List"})
+	q.AddBookmarkToCollection(ctx, dbgen.AddBookmarkToCollectionParams{OwnerID: ownerID(ctx), BookmarkID: goRepo.ID, CollectionID: col.ID})
+
+	search := func(query string) (ids []int64, total int64, first SearchResult) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/search?q="+url.QueryEscape(query), nil)
+		w := httptest.NewRecorder()
+		server.HandleSearch(w, req)
+		if w.Code != 200 {
+			t.Fatalf("search %q: status %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Bookmarks []SearchResult `json:"bookmarks"`
+			Total     int64          `json:"total"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		for _, b := range resp.Bookmarks {
+			ids = append(ids, b.ID)
+		}
+		if len(resp.Bookmarks) > 0 {
+			first = resp.Bookmarks[0]
+		}
+		return ids, resp.Total, first
+	}
+
+	ids, total, first := search("go")
+	if total != 2 || len(ids) != 2 {
+		t.Fatalf("search go: got %v (total %d), want 2 results", ids, total)
+	}
+	if first.ID != goRepo.ID {
+		t.Errorf("expected title match ranked first, got %d", first.ID)
+	}
+	if first.TitleHighlight != "The <mark>Go</mark> programming language" {
+		t.Errorf("unexpected highlight %q", first.TitleHighlight)
+	}
+
+	if ids, _, _ := search("go -pike"); !reflect.DeepEqual(ids, []int64{goRepo.ID}) {
+		t.Errorf("negation: got %v", ids)
+	}
+	if ids, _, _ := search("tag:go"); !reflect.DeepEqual(ids, []int64{goTalk.ID}) {
+		t.Errorf("tag filter: got %v", ids)
+	}
+	if ids, _, _ := search(`collection:"reading list"`); !reflect.DeepEqual(ids, []int64{goRepo.ID}) {
+		t.Errorf("collection filter: got %v", ids)
+	}
+	if ids, _, _ := search("domain:youtube.com"); !reflect.DeepEqual(ids, []int64{goTalk.ID}) {
+		t.Errorf("domain filter: got %v", ids)
+	}
+	if ids, _, _ := search("go source:youtube"); !reflect.DeepEqual(ids, []int64{goTalk.ID}) {
+		t.Errorf("source filter: got %v", ids)
+	}
+	if _, total, _ := search("before:2000-01-01"); total != 0 {
+		t.Errorf("before filter: got %d results", total)
+	}
+	if _, _, first := search("documentation"); first.Snippet != "Python &lt;3&gt; <mark>documentation</mark>" {
+		t.Errorf("snippet not escaped: %q", first.Snippet)
+	}
+}
+
+func TestSearchIndexesKeywordsURLAndTags(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "fts.sqlite3"), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+
+	b, err := q.CreateBookmark(ctx, dbgen.CreateBookmarkParams{
+		OwnerID: ownerID(ctx),
+		Url:     "https://pkg.go.dev/golang.org/x/net/html", Title: "html package", SourceType: "web",
+	})
+	if err != nil {
+		t.Fatalf("create bookmark: %v", err)
+	}
+
+	count := func(query string) int64 {
+		t.Helper()
+		sq, err := parseSearchQuery(query)
+		if err != nil {
+			t.Fatalf("parse %q: %v", query, err)
+		}
+		_, total, err := searchBookmarks(ctx, server.DB, sq, 10, 0)
+		if err != nil {
+			t.Fatalf("search %q: %v", query, err)
+		}
+		return total
+	}
+
+	if n := count("pkg"); n != 1 {
+		t.Errorf("url not indexed: got %d", n)
+	}
+	if n := count("tokenizer"); n != 0 {
+		t.Errorf("unexpected keyword match before analysis: got %d", n)
+	}
+	if _, err := q.UpdateBookmarkAnalysis(ctx, dbgen.UpdateBookmarkAnalysisParams{
+		OwnerID: ownerID(ctx),
+		ID:      b.ID, Summary: strPtr("HTML parsing"), Keywords: strPtr(`["tokenizer","parser"]`),
+	}); err != nil {
+		t.Fatalf("update analysis: %v", err)
+	}
+	if n := count("tokenizer"); n != 1 {
+		t.Errorf("keywords not indexed after UpdateBookmarkAnalysis: got %d", n)
+	}
+
+	tag, _ := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: "scraping"})
+	q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: b.ID, TagID: tag.ID})
+	if n := count("scraping"); n != 1 {
+		t.Errorf("tag not indexed after AddTagToBookmark: got %d", n)
+	}
+	q.RemoveTagFromBookmark(ctx, dbgen.RemoveTagFromBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: b.ID, TagID: tag.ID})
+	if n := count("scraping"); n != 0 {
+		t.Errorf("tag still indexed after RemoveTagFromBookmark: got %d", n)
+	}
+
+	q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: ownerID(ctx), BookmarkID: b.ID, TagID: tag.ID})
+	server.DB.Exec("DELETE FROM bookmarks_fts")
+	if n, err := db.RebuildSearchIndex(server.DB); err != nil || n != 1 {
+		t.Fatalf("RebuildSearchIndex = %d, %v", n, err)
+	}
+	if n := count("scraping tokenizer pkg"); n != 1 {
+		t.Errorf("rebuilt index missing fields: got %d", n)
+	}
+
+	q.DeleteBookmark(ctx, dbgen.DeleteBookmarkParams{OwnerID: ownerID(ctx), ID: b.ID})
+	if n := count("html"); n != 0 {
+		t.Errorf("deleted bookmark still indexed: got %d", n)
+	}
+}
+
+func TestSemanticAndHybridSearch(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "semantic.sqlite3"), "test")
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+
+	var ids []int64
+	for _, b := range []struct{ url, title, desc string }{
+		{"https://example.com/pgvector", "pgvector tutorial", "Store embeddings in Postgres"},
+		{"https://example.com/bread", "Sourdough bread recipe", "Baking with a starter"},
+		{"https://example.com/vectors", "Choosing a vector database", "Comparing vector stores"},
+	} {
+		created, err := q.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: ownerID(ctx), Url: b.url, Title: b.title, Description: strPtr(b.desc), SourceType: "web"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := server.embedBookmark(ctx, created.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, created.ID)
+	}
+
+	search := func(query, mode string) []SearchResult {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/search?mode="+mode+"&q="+url.QueryEscape(query), nil)
+		w := httptest.NewRecorder()
+		server.HandleSearch(w, req)
+		if w.Code != 200 {
+			t.Fatalf("search %q: status %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp struct {
+			Bookmarks []SearchResult `json:"bookmarks"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp.Bookmarks
+	}
+
+	// Keyword search for "vector databases" misses the pgvector bookmark.
+	for _, r := range search("vector databases", "keyword") {
+		if r.ID == ids[0] {
+			t.Errorf("keyword search unexpectedly matched pgvector")
+		}
+	}
+
+	semantic := search("vector databases", "semantic")
+	found := map[int64]bool{}
+	for _, r := range semantic {
+		found[r.ID] = true
+		if r.Similarity <= 0 {
+			t.Errorf("expected positive similarity, got %+v", r)
+		}
+	}
+	if !found[ids[0]] || !found[ids[2]] || found[ids[1]] {
+		t.Errorf("unexpected semantic results %v", found)
+	}
+
+	hybrid := search("vector", "hybrid")
+	if len(hybrid) < 2 || hybrid[0].ID != ids[2] {
+		t.Errorf("expected keyword match first in hybrid results, got %+v", hybrid)
+	}
+
+	filtered := search("vector -pgvector", "semantic")
+	for _, r := range filtered {
+		if r.ID == ids[0] {
+			t.Errorf("excluded term should drop pgvector from semantic results")
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/search?mode=bogus&q=x", nil)
+	w := httptest.NewRecorder()
+	server.HandleSearch(w, req)
+	if w.Code != 400 {
+		t.Errorf("expected 400 for unknown mode, got %d", w.Code)
+	}
+}
@@ -0,0 +1,166 @@
+package srv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// searchQuery is the parsed form of the /api/search query language.
+//
+// Supported syntax:
+//
+//	golang tutorial         words (prefix-matched, all must match)
+//	"exact phrase"          phrase match
+//	-word, -"some phrase"   exclude matching bookmarks
+//	tag:go                  bookmark has tag
+//	source:youtube          source_type equals
+//	collection:"Reading"    bookmark is in collection
+//	domain:github.com       URL host is the domain or a subdomain of it
+//	before:2025-01-01       created before the date (exclusive)
+//	after:2025-01-01        created on or after the date
+//
+// Any filter can be negated with a leading '-'.
+type searchQuery struct {
+	Terms    []searchTerm
+	Excluded []searchTerm
+	Filters  []searchFilter
+}
+
+type searchTerm struct {
+	Text   string
+	Phrase bool
+}
+
+type searchFilter struct {
+	Field  string
+	Value  string
+	Negate bool
+}
+
+var searchFilterFields = map[string]bool{
+	"tag": true, "source": true, "collection": true,
+	"domain": true, "before": true, "after": true,
+}
+
+func (sq searchQuery) empty() bool {
+	return len(sq.Terms) == 0 && len(sq.Excluded) == 0 && len(sq.Filters) == 0
+}
+
+// parseSearchQuery tokenizes input into terms, phrases and field filters.
+// Unknown field prefixes (e.g. "http:") are treated as plain words.
+func parseSearchQuery(input string) (searchQuery, error) {
+	var sq searchQuery
+	runes := []rune(input)
+	i := 0
+	for i < len(runes) {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negate := false
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			negate = true
+			i++
+		}
+
+		if runes[i] == '"' {
+			text, next := readQuoted(runes, i)
+			i = next
+			if text = strings.TrimSpace(text); text != "" {
+				sq.addTerm(searchTerm{Text: text, Phrase: true}, negate)
+			}
+			continue
+		}
+
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != ':' {
+			i++
+		}
+		word := string(runes[start:i])
+		field := strings.ToLower(word)
+		if i < len(runes) && runes[i] == ':' && searchFilterFields[field] {
+			i++
+			var value string
+			if i < len(runes) && runes[i] == '"' {
+				value, i = readQuoted(runes, i)
+			} else {
+				vstart := i
+				for i < len(runes) && !unicode.IsSpace(runes[i]) {
+					i++
+				}
+				value = string(runes[vstart:i])
+			}
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			if field == "before" || field == "after" {
+				if _, err := time.Parse("2006-01-02", value); err != nil {
+					return sq, fmt.Errorf("invalid date for %s: %q (want YYYY-MM-DD)", field, value)
+				}
+			}
+			sq.Filters = append(sq.Filters, searchFilter{Field: field, Value: value, Negate: negate})
+			continue
+		}
+
+		// Not a known filter: consume the rest of the word including any colons.
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		word = string(runes[start:i])
+		if hasSearchableChars(word) {
+			sq.addTerm(searchTerm{Text: word}, negate)
+		}
+	}
+	return sq, nil
+}
+
+func (sq *searchQuery) addTerm(t searchTerm, negate bool) {
+	if negate {
+		sq.Excluded = append(sq.Excluded, t)
+	} else {
+		sq.Terms = append(sq.Terms, t)
+	}
+}
+
+// readQuoted reads a double-quoted string starting at runes[start] == '"'.
+// An unterminated quote runs to the end of input.
+func readQuoted(runes []rune, start int) (string, int) {
+	i := start + 1
+	for i < len(runes) && runes[i] != '"' {
+		i++
+	}
+	text := string(runes[start+1 : i])
+	if i < len(runes) {
+		i++ // closing quote
+	}
+	return text, i
+}
+
+func hasSearchableChars(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ftsMatch builds an FTS5 MATCH expression joining terms with op: "AND"
+// requires every term, "OR" matches any of them.
+// Every term is quoted so user input can never inject FTS5 operators;
+// bare words are prefix-matched so results appear while typing.
+func ftsMatch(terms []searchTerm, op string) string {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		q := `"` + strings.ReplaceAll(t.Text, `"`, `""`) + `"`
+		if !t.Phrase {
+			q += "*"
+		}
+		parts = append(parts, q)
+	}
+	return strings.Join(parts, " "+op+" ")
+}
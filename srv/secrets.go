@@ -0,0 +1,206 @@
+package srv
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Names of the credentials kept in the secret store.
+const (
+	SecretGitHubToken   = "github_token"
+	SecretOpenAIKey     = "openai_api_key"
+	SecretYouTubeAPIKey = "youtube_api_key"
+)
+
+var knownSecrets = []string{SecretGitHubToken, SecretOpenAIKey, SecretYouTubeAPIKey}
+
+// ErrSecretsLocked is returned when storing a secret without SECRETS_KEY.
+var ErrSecretsLocked = errors.New("secret store is locked: set SECRETS_KEY")
+
+// SecretStore keeps credentials in the secrets table, encrypted with
+// AES-256-GCM. Without a key it is locked: nothing can be stored or read.
+type SecretStore struct {
+	db   *sql.DB
+	aead cipher.AEAD
+}
+
+// NewSecretStore returns a store whose key is the SHA-256 of key, so any
+// long random string will do. An empty key gives a locked store.
+func NewSecretStore(db *sql.DB, key string) (*SecretStore, error) {
+	s := &SecretStore{db: db}
+	if key == "" {
+		return s, nil
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	if s.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Unlocked reports whether the store has a key.
+func (s *SecretStore) Unlocked() bool {
+	return s != nil && s.aead != nil
+}
+
+// Get returns the named secret, or "" if it is not set.
+func (s *SecretStore) Get(ctx context.Context, name string) (string, error) {
+	if !s.Unlocked() {
+		return "", nil
+	}
+	row, err := dbgen.New(s.db).GetSecret(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	plain, err := s.aead.Open(nil, row.Nonce, row.Ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s: wrong SECRETS_KEY?", name)
+	}
+	return string(plain), nil
+}
+
+// KeyFunc returns an APIKeyFunc that reads the named secret each time,
+// so replacing the secret takes effect without a restart.
+func (s *SecretStore) KeyFunc(name string) APIKeyFunc {
+	return func(ctx context.Context) (string, error) {
+		return s.Get(ctx, name)
+	}
+}
+
+// Set stores value under name, replacing only that secret.
+func (s *SecretStore) Set(ctx context.Context, name, value string) error {
+	if !s.Unlocked() {
+		return ErrSecretsLocked
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return dbgen.New(s.db).UpsertSecret(ctx, dbgen.UpsertSecretParams{
+		Name:       name,
+		Nonce:      nonce,
+		Ciphertext: s.aead.Seal(nil, nonce, []byte(value), []byte(name)),
+	})
+}
+
+// Delete removes the named secret.
+func (s *SecretStore) Delete(ctx context.Context, name string) error {
+	_, err := dbgen.New(s.db).DeleteSecret(ctx, name)
+	return err
+}
+
+// SecretInfo describes a secret without revealing it.
+type SecretInfo struct {
+	Name      string     `json:"name"`
+	HasValue  bool       `json:"has_value"`
+	Masked    string     `json:"masked,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// List describes every known secret, set or not.
+func (s *SecretStore) List(ctx context.Context) ([]SecretInfo, error) {
+	rows, err := dbgen.New(s.db).ListSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SecretInfo, len(knownSecrets))
+	for i, name := range knownSecrets {
+		infos[i].Name = name
+		for _, row := range rows {
+			if row.Name != name {
+				continue
+			}
+			value, err := s.Get(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			infos[i].HasValue = true
+			infos[i].Masked = maskSecret(value)
+			infos[i].UpdatedAt = &row.UpdatedAt
+		}
+	}
+	return infos, nil
+}
+
+// maskSecret hides all but the last four characters of long values.
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 8 {
+		return "••••"
+	}
+	return "••••" + value[len(value)-4:]
+}
+
+// HandleListSecrets reports which secrets are set, masked.
+func (s *Server) HandleListSecrets(w http.ResponseWriter, r *http.Request) {
+	infos, err := s.Secrets.List(r.Context())
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, map[string]any{"unlocked": s.Secrets.Unlocked(), "secrets": infos})
+}
+
+// HandleSetSecret stores one secret.
+func (s *Server) HandleSetSecret(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !slices.Contains(knownSecrets, name) {
+		writeError(w, "unknown secret "+name, 404)
+		return
+	}
+	var req struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid JSON", 400)
+		return
+	}
+	req.Value = strings.TrimSpace(req.Value)
+	if req.Value == "" {
+		writeError(w, "value required", 400)
+		return
+	}
+	if err := s.Secrets.Set(r.Context(), name, req.Value); errors.Is(err, ErrSecretsLocked) {
+		writeError(w, err.Error(), 503)
+		return
+	} else if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, SecretInfo{Name: name, HasValue: true, Masked: maskSecret(req.Value)})
+}
+
+// HandleDeleteSecret removes one secret.
+func (s *Server) HandleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !slices.Contains(knownSecrets, name) {
+		writeError(w, "unknown secret "+name, 404)
+		return
+	}
+	if err := s.Secrets.Delete(r.Context(), name); err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	w.WriteHeader(204)
+}
@@ -0,0 +1,118 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretStore(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "secrets.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := server.Secrets.Set(ctx, SecretGitHubToken, "ghp_secret"); err != ErrSecretsLocked {
+		t.Errorf("locked store accepted a secret: %v", err)
+	}
+
+	server.Secrets, _ = NewSecretStore(server.DB, "correct horse battery staple")
+	server.Secrets.Set(ctx, SecretGitHubToken, "ghp_0123456789abcdef")
+	server.Secrets.Set(ctx, SecretYouTubeAPIKey, "yt-key-1")
+	server.Secrets.Set(ctx, SecretGitHubToken, "ghp_rotated_token_wxyz")
+
+	if v, _ := server.Secrets.Get(ctx, SecretGitHubToken); v != "ghp_rotated_token_wxyz" {
+		t.Errorf("github token = %q", v)
+	}
+	if v, _ := server.Secrets.Get(ctx, SecretYouTubeAPIKey); v != "yt-key-1" {
+		t.Errorf("rotating one secret changed another: %q", v)
+	}
+	var raw []byte
+	server.DB.QueryRow("SELECT ciphertext FROM secrets WHERE name = ?", SecretGitHubToken).Scan(&raw)
+	if bytes.Contains(raw, []byte("ghp_")) {
+		t.Error("secret stored in plaintext")
+	}
+	other, _ := NewSecretStore(server.DB, "wrong key")
+	if _, err := other.Get(ctx, SecretGitHubToken); err == nil {
+		t.Error("expected an error decrypting with the wrong key")
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleListSecrets(w, httptest.NewRequest("GET", "/api/secrets", nil))
+	if strings.Contains(w.Body.String(), "rotated_token") || strings.Contains(w.Body.String(), "yt-key") {
+		t.Errorf("secret leaked in listing: %s", w.Body.String())
+	}
+	var resp struct {
+		Secrets []SecretInfo `json:"secrets"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	got := map[string]SecretInfo{}
+	for _, info := range resp.Secrets {
+		got[info.Name] = info
+	}
+	if s := got[SecretGitHubToken]; !s.HasValue || s.Masked != "••••wxyz" {
+		t.Errorf("unexpected github entry %+v", s)
+	}
+	if got[SecretOpenAIKey].HasValue {
+		t.Errorf("unset secret reported as set")
+	}
+}
+
+func TestSecretsNeedAdmin(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "secrets.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Secrets, _ = NewSecretStore(server.DB, "correct horse battery staple")
+	server.Admins = []string{"root"}
+	server.AllowAnonymous = true
+	server.DefaultOwner = "default"
+	handler := server.Handler()
+	put := func(user, value string) int {
+		req := httptest.NewRequest("PUT", "/api/secrets/"+SecretOpenAIKey, strings.NewReader(`{"value": "`+value+`"}`))
+		if user != "" {
+			req.Header.Set("X-ExeDev-UserID", user)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := put("alice", "sk-alice"); code != 403 {
+		t.Errorf("header user set a secret: %d", code)
+	}
+	if code := put("", "sk-anon"); code != 403 {
+		t.Errorf("anonymous user set a secret: %d", code)
+	}
+	if code := put("root", "sk-first"); code != 200 {
+		t.Fatalf("admin set a secret: %d", code)
+	}
+
+	// The key is read per request, so rotating it needs no restart.
+	var auth []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"choices": [{"message": {"content": "ok"}}]}`))
+	}))
+	defer api.Close()
+	llm, err := NewLLMProvider(LLMConfig{Provider: "openai", BaseURL: api.URL, APIKeyFunc: server.Secrets.KeyFunc(SecretOpenAIKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	llm.Complete(ctx, CompletionRequest{Prompt: "hi"})
+	put("root", "sk-second")
+	llm.Complete(ctx, CompletionRequest{Prompt: "hi"})
+	if want := []string{"Bearer sk-first", "Bearer sk-second"}; strings.Join(auth, ",") != strings.Join(want, ",") {
+		t.Errorf("Authorization headers = %q", auth)
+	}
+	server.Secrets.Delete(ctx, SecretOpenAIKey)
+	if _, err := llm.Complete(ctx, CompletionRequest{Prompt: "hi"}); !errors.Is(err, ErrNoAPIKey) || len(auth) != 2 {
+		t.Errorf("without a key: %v after %d requests", err, len(auth))
+	}
+}
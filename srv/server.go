@@ -1,36 +1,121 @@
 package srv
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
-	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"srv.exe.dev/db"
 )
 
 type Server struct {
 	DB           *sql.DB
+	Jobs         *JobQueue
+	Events       *EventHub
+	LLM          LLMProvider // nil disables LLM summaries
+	Embedder     EmbeddingProvider
+	AutoTag      AutoTagOptions
+	Secrets      *SecretStore
+	Metrics      *Metrics
 	Hostname     string
-	TemplatesDir string
-	StaticDir    string
+	TemplatesDir string       // overrides the embedded templates
+	StaticDir    string       // overrides the embedded static files
+	Dev          bool         // re-read templates on every request
+	SyncDir      string       // git working directories for data sync, one per user
+	ProjectRoot  string       // holds .github-config.json
+	BlobDir      string       // content-addressed favicons and thumbnails, by SHA-256
+	GitDefaults  GitHubConfig // git settings not set in .github-config.json
+	SSHKeyDir    string       // holds the keys .github-config.json may name
+	FetchTimeout time.Duration
+	UserAgent    string
+	Workers      int      // job queue workers
+	CORSOrigins  []string // "*", exact origins, or prefixes ending in "*"
+	Features     Features
+	// ScreenshotFallback uses an external screenshot service for pages
+	// without a usable preview image. It sends the page's URL to that
+	// service.
+	ScreenshotFallback bool
+
+	// HTTP server limits. Request bodies are capped at MaxBodyBytes, or
+	// MaxUploadBytes for the import endpoints.
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // for draining requests and jobs
+	MaxBodyBytes    int64
+	MaxUploadBytes  int64
+
+	// DefaultOwner receives rows that predate per-user data and, when
+	// AllowAnonymous is set, requests without exe.dev identity headers.
+	DefaultOwner   string
+	AllowAnonymous bool
+	// Admins are the user IDs given the admin scope, which manages the
+	// secrets shared by every user. Anonymous requests never get it.
+	Admins []string
+
+	seenUsers      sync.Map // user ID -> email already recorded in users
+	faviconLookups sync.Map // domain -> chan closed when discovery ends
+	syncLocks      sync.Map // sync repository name -> *sync.Mutex held while syncing
+
+	templatesOnce sync.Once
+	templates     *template.Template
+	templatesErr  error
 }
 
+// Features switches optional parts of the server on or off. Disabled
+// features have no routes.
+type Features struct {
+	WebSearch     bool `json:"web_search"`
+	YouTubeImport bool `json:"youtube_import"`
+	GitSync       bool `json:"git_sync"`
+}
+
+var DefaultFeatures = Features{WebSearch: true, YouTubeImport: true, GitSync: true}
+
 func New(dbPath, hostname string) (*Server, error) {
-	_, thisFile, _, _ := runtime.Caller(0)
-	baseDir := filepath.Dir(thisFile)
 	srv := &Server{
 		Hostname:     hostname,
-		TemplatesDir: filepath.Join(baseDir, "templates"),
-		StaticDir:    filepath.Join(baseDir, "static"),
+		FetchTimeout: DefaultFetchTimeout,
+		UserAgent:    DefaultUserAgent,
+		Workers:      4,
+		CORSOrigins:  []string{"*"},
+		Features:     DefaultFeatures,
+
+		ScreenshotFallback: true,
+
+		ReadTimeout:     30 * time.Second,
+		WriteTimeout:    2 * time.Minute,
+		IdleTimeout:     2 * time.Minute,
+		ShutdownTimeout: 30 * time.Second,
+		MaxBodyBytes:    1 << 20,
+		MaxUploadBytes:  64 << 20,
 	}
+	srv.ProjectRoot = filepath.Dir(dbPath)
+	srv.SyncDir = filepath.Join(srv.ProjectRoot, "sync-data")
+	srv.BlobDir = filepath.Join(srv.ProjectRoot, "blobs")
 	if err := srv.setUpDatabase(dbPath); err != nil {
 		return nil, err
 	}
+	srv.Metrics = NewMetrics()
+	srv.Events = NewEventHub()
+	srv.Jobs = NewJobQueue(srv.DB)
+	srv.Jobs.Events = srv.Events
+	srv.Embedder = NewHashingEmbedder(512)
+	srv.Secrets, _ = NewSecretStore(srv.DB, "")
+	srv.registerJobHandlers()
 	return srv, nil
 }
 
@@ -46,16 +131,93 @@ func (s *Server) setUpDatabase(dbPath string) error {
 	return nil
 }
 
+// Serve listens on addr until SIGINT or SIGTERM, then shuts down cleanly.
 func (s *Server) Serve(addr string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("starting server", "addr", ln.Addr())
+	return s.serve(ctx, ln)
+}
+
+// serve handles requests on ln until ctx is done. Shutdown stops accepting
+// connections, waits for in-flight requests and then running jobs (up to
+// ShutdownTimeout between them), and closes the database.
+func (s *Server) serve(ctx context.Context, ln net.Listener) error {
+	if _, err := s.loadTemplates(); err != nil {
+		return err
+	}
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if err := s.Jobs.Start(jobsCtx, s.Workers); err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+	}
+	httpServer.RegisterOnShutdown(s.Events.Close)
+	errc := make(chan error, 1)
+	go func() { errc <- httpServer.Serve(ln) }()
+
+	var serveErr error
+	select {
+	case serveErr = <-errc:
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("shutdown: requests still running", "error", err)
+	}
+	if err := s.Jobs.Drain(shutdownCtx); err != nil {
+		slog.Warn("shutdown: interrupting running jobs", "error", err)
+	}
+	stopJobs()
+	s.Jobs.Wait()
+	if err := db.Close(s.DB); err != nil {
+		return fmt.Errorf("close database: %w", err)
+	}
+	slog.Info("server stopped")
+	if errors.Is(serveErr, http.ErrServerClosed) {
+		return nil
+	}
+	return serveErr
+}
+
+// Handler returns the server's routes wrapped in its middleware.
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.HandleHealthz)
+	mux.HandleFunc("GET /readyz", s.HandleReadyz)
+	mux.HandleFunc("GET /metrics", s.HandleMetrics)
 	mux.HandleFunc("GET /{$}", s.HandleIndex)
 	mux.HandleFunc("GET /share", s.HandleShare)
 	mux.HandleFunc("GET /extension", s.HandleExtensionPage)
+	mux.HandleFunc("GET /favicons/{domain}", s.HandleFavicon)
+	mux.HandleFunc("GET /thumbnails/{hash}", s.HandleThumbnail)
+	mux.HandleFunc("GET /api/me", s.HandleMe)
+	mux.HandleFunc("GET /api/tokens", s.HandleListTokens)
+	mux.HandleFunc("POST /api/tokens", s.HandleCreateToken)
+	mux.HandleFunc("DELETE /api/tokens/{id}", s.HandleRevokeToken)
+	mux.HandleFunc("GET /api/secrets", s.HandleListSecrets)
+	mux.HandleFunc("PUT /api/secrets/{name}", s.HandleSetSecret)
+	mux.HandleFunc("DELETE /api/secrets/{name}", s.HandleDeleteSecret)
 	mux.HandleFunc("GET /api/bookmarks", s.HandleListBookmarks)
 	mux.HandleFunc("POST /api/bookmarks", s.HandleCreateBookmark)
 	mux.HandleFunc("GET /api/bookmarks/{id}", s.HandleGetBookmark)
 	mux.HandleFunc("PUT /api/bookmarks/{id}", s.HandleUpdateBookmark)
 	mux.HandleFunc("DELETE /api/bookmarks/{id}", s.HandleDeleteBookmark)
+	mux.HandleFunc("GET /api/bookmarks/{id}/related", s.HandleRelatedBookmarks)
 	mux.HandleFunc("GET /api/tags", s.HandleListTags)
 	mux.HandleFunc("POST /api/tags", s.HandleCreateTag)
 	mux.HandleFunc("GET /api/collections", s.HandleListCollections)
@@ -65,24 +227,68 @@ func (s *Server) Serve(addr string) error {
 	mux.HandleFunc("DELETE /api/collections/{id}/bookmarks", s.HandleRemoveBookmarksFromCollection)
 	mux.HandleFunc("POST /api/bookmarks/bulk-update", s.HandleBulkUpdateBookmarks)
 	mux.HandleFunc("GET /api/search", s.HandleSearch)
-	mux.HandleFunc("GET /api/web-search", s.HandleWebSearch)
 	mux.HandleFunc("POST /api/fetch-metadata", s.HandleFetchMetadata)
-	mux.HandleFunc("POST /api/youtube/import", s.HandleYouTubeImport)
 	mux.HandleFunc("POST /api/instagram/import", s.HandleInstagramImport)
+	mux.HandleFunc("POST /api/import/netscape", s.HandleNetscapeImport)
+	mux.HandleFunc("GET /api/export/netscape", s.HandleNetscapeExport)
+	mux.HandleFunc("POST /api/import/json", s.HandleJSONImport)
+	mux.HandleFunc("GET /api/export/json", s.HandleJSONExport)
 	mux.HandleFunc("POST /api/analyze", s.HandleAnalyzeURL)
 	mux.HandleFunc("POST /api/bookmarks/{id}/analyze", s.HandleAnalyzeBookmark)
+	mux.HandleFunc("POST /api/bookmarks/{id}/autotag", s.HandleAutoTag)
+	mux.HandleFunc("GET /api/bookmarks/{id}/tag-suggestions", s.HandleListTagSuggestions)
+	mux.HandleFunc("POST /api/embeddings/rebuild", s.HandleRebuildEmbeddings)
 	mux.HandleFunc("POST /api/generate-all", s.HandleGenerateAllMetadata)
-	mux.HandleFunc("GET /api/github/config", s.HandleGitHubConfig)
-	mux.HandleFunc("POST /api/github/config", s.HandleGitHubConfig)
-	mux.HandleFunc("POST /api/github/pull", s.HandleGitHubPull)
-	mux.HandleFunc("POST /api/github/push", s.HandleGitHubPush)
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.StaticDir))))
+	mux.HandleFunc("GET /api/jobs", s.HandleListJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", s.HandleGetJob)
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", s.HandleCancelJob)
+	mux.HandleFunc("GET /api/events", s.HandleEvents)
+	if s.Features.WebSearch {
+		mux.HandleFunc("GET /api/web-search", s.HandleWebSearch)
+	}
+	if s.Features.YouTubeImport {
+		mux.HandleFunc("POST /api/youtube/import", s.HandleYouTubeImport)
+	}
+	if s.Features.GitSync {
+		mux.HandleFunc("GET /api/github/config", s.HandleGitHubConfig)
+		mux.HandleFunc("POST /api/github/config", s.HandleGitHubConfig)
+		mux.HandleFunc("POST /api/sync/pull", s.HandleSyncPull)
+		mux.HandleFunc("POST /api/sync/push", s.HandleSyncPush)
+		mux.HandleFunc("GET /api/sync/conflicts", s.HandleListSyncConflicts)
+		mux.HandleFunc("POST /api/sync/conflicts/{id}/resolve", s.HandleResolveSyncConflict)
+	}
+	mux.Handle("/static/", s.staticHandler())
 	
 	// Wrap with CORS middleware for extension support
-	handler := s.corsMiddleware(mux)
-	
-	slog.Info("starting server", "addr", addr)
-	return http.ListenAndServe(addr, handler)
+	handler := s.corsMiddleware(s.limitBodies(s.identify(mux)))
+	if s.Metrics != nil {
+		handler = s.Metrics.instrument(mux, handler)
+	}
+	return handler
+}
+
+// uploadPaths accept bodies up to MaxUploadBytes rather than MaxBodyBytes.
+var uploadPaths = map[string]bool{
+	"/api/import/json":      true,
+	"/api/import/netscape":  true,
+	"/api/instagram/import": true,
+}
+
+// limitBodies caps the size of request bodies. Reads beyond the limit
+// fail, so handlers reject oversized JSON as invalid.
+func (s *Server) limitBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := s.MaxBodyBytes
+		if uploadPaths[r.URL.Path] {
+			limit = s.MaxUploadBytes
+		}
+		if r.ContentLength > limit {
+			writeError(w, "request body too large", 413)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
 }
 
 func (s *Server) HandleIndex(w http.ResponseWriter, r *http.Request) {
@@ -92,16 +298,6 @@ func (s *Server) HandleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) renderTemplate(w http.ResponseWriter, name string, data any) error {
-	path := filepath.Join(s.TemplatesDir, name)
-	tmpl, err := template.ParseFiles(path)
-	if err != nil {
-		return fmt.Errorf("parse template %q: %w", name, err)
-	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	return tmpl.Execute(w, data)
-}
-
 func writeJSON(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
@@ -116,7 +312,7 @@ func writeError(w http.ResponseWriter, msg string, code int) {
 // CORS middleware
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		s.setAllowOrigin(w, r)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		
@@ -130,7 +326,7 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 
 func (s *Server) cors(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		s.setAllowOrigin(w, r)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if r.Method == "OPTIONS" {
@@ -141,6 +337,23 @@ func (s *Server) cors(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// setAllowOrigin allows the request's origin if CORSOrigins lists it.
+func (s *Server) setAllowOrigin(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	for _, allowed := range s.CORSOrigins {
+		switch {
+		case allowed == "*":
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return
+		case origin == "":
+		case origin == allowed, strings.HasSuffix(allowed, "*") && strings.HasPrefix(origin, strings.TrimSuffix(allowed, "*")):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			return
+		}
+	}
+}
+
 func (s *Server) HandleExtensionPage(w http.ResponseWriter, r *http.Request) {
 	if err := s.renderTemplate(w, "extension.html", nil); err != nil {
 		slog.Warn("render template", "error", err)
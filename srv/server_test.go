@@ -1,12 +1,18 @@
 package srv
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"srv.exe.dev/db"
+	"srv.exe.dev/db/dbgen"
 )
 
 func TestServerSetupAndHandlers(t *testing.T) {
@@ -18,100 +24,144 @@ func TestServerSetupAndHandlers(t *testing.T) {
 		t.Fatalf("failed to create server: %v", err)
 	}
 
-	// Test root endpoint without auth
-	t.Run("root endpoint unauthenticated", func(t *testing.T) {
+	t.Run("index page renders", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		w := httptest.NewRecorder()
 
-		server.HandleRoot(w, req)
+		server.HandleIndex(w, req)
 
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status 200, got %d", w.Code)
 		}
-
-		body := w.Body.String()
-		if !strings.Contains(body, "test-hostname") {
-			t.Errorf("expected page to show hostname, got body: %s", body)
-		}
-		if !strings.Contains(body, "Go Template Project") {
-			t.Errorf("expected page to contain headline, got body: %s", body)
-		}
-		if strings.Contains(body, "Signed in as") {
-			t.Errorf("expected page to not be logged in, got body: %s", body)
-		}
-		if !strings.Contains(body, "Not signed in") {
-			t.Errorf("expected page to show 'Not signed in', got body: %s", body)
+		if !strings.Contains(w.Body.String(), "Bookmark Manager") {
+			t.Errorf("expected page to contain title, got body: %s", w.Body.String())
 		}
 	})
 
-	// Test root endpoint with auth headers
-	t.Run("root endpoint authenticated", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/", nil)
-		req.Header.Set("X-ExeDev-UserID", "user123")
-		req.Header.Set("X-ExeDev-Email", "test@example.com")
+	t.Run("list bookmarks empty", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/bookmarks", nil)
 		w := httptest.NewRecorder()
 
-		server.HandleRoot(w, req)
+		server.HandleListBookmarks(w, req)
 
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status 200, got %d", w.Code)
 		}
-
-		body := w.Body.String()
-		if !strings.Contains(body, "Signed in as") {
-			t.Errorf("expected page to show logged in state, got body: %s", body)
-		}
-		if !strings.Contains(body, "test@example.com") {
-			t.Error("expected page to show user email")
+		if strings.TrimSpace(w.Body.String()) != "[]" {
+			t.Errorf("expected empty list, got body: %s", w.Body.String())
 		}
 	})
 
-	// Test view counter functionality
-	t.Run("view counter increments", func(t *testing.T) {
-		// Make first request
-		req1 := httptest.NewRequest(http.MethodGet, "/", nil)
-		req1.Header.Set("X-ExeDev-UserID", "counter-test")
-		req1.RemoteAddr = "192.168.1.100:12345"
-		w1 := httptest.NewRecorder()
-		server.HandleRoot(w1, req1)
-
-		// Should show "1 times" or similar
-		body1 := w1.Body.String()
-		if !strings.Contains(body1, "1</strong> times") {
-			t.Error("expected first visit to show 1 time")
+	t.Run("static files are embedded", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/static/manifest.json", nil)
+		w := httptest.NewRecorder()
+
+		server.staticHandler().ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
 		}
+	})
 
-		// Make second request with same user
-		req2 := httptest.NewRequest(http.MethodGet, "/", nil)
-		req2.Header.Set("X-ExeDev-UserID", "counter-test")
-		req2.RemoteAddr = "192.168.1.100:12345"
-		w2 := httptest.NewRecorder()
-		server.HandleRoot(w2, req2)
-
-		// Should show "2 times" or similar
-		body2 := w2.Body.String()
-		if !strings.Contains(body2, "2</strong> times") {
-			t.Error("expected second visit to show 2 times")
+	t.Run("dev mode reloads templates", func(t *testing.T) {
+		dir := t.TempDir()
+		dev := &Server{TemplatesDir: dir, Dev: true}
+		for _, version := range []string{"first", "second"} {
+			os.WriteFile(filepath.Join(dir, "index.html"), []byte(version), 0o644)
+			w := httptest.NewRecorder()
+			dev.HandleIndex(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Body.String() != version {
+				t.Errorf("expected %q, got %q", version, w.Body.String())
+			}
 		}
 	})
 }
 
-func TestUtilityFunctions(t *testing.T) {
-	t.Run("mainDomainFromHost function", func(t *testing.T) {
-		tests := []struct {
-			input    string
-			expected string
-		}{
-			{"example.exe.cloud:8080", "exe.cloud:8080"},
-			{"example.exe.dev", "exe.dev"},
-			{"example.exe.cloud", "exe.cloud"},
-		}
+func TestEventHub(t *testing.T) {
+	hub := NewEventHub()
+	alice := withOwner(context.Background(), "alice")
+	events, unsubscribe := hub.Subscribe("alice")
 
-		for _, test := range tests {
-			result := mainDomainFromHost(test.input)
-			if result != test.expected {
-				t.Errorf("mainDomainFromHost(%q) = %q, expected %q", test.input, result, test.expected)
-			}
-		}
+	hub.Publish(withOwner(context.Background(), "bob"), EventBookmarkCreated, nil)
+	hub.Publish(alice, EventBookmarkDeleted, map[string]int64{"id": 7})
+	e := <-events
+	if e.Type != EventBookmarkDeleted || e.ID != 2 {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	unsubscribe()
+	hub.Publish(alice, EventBookmarkDeleted, nil)
+	select {
+	case e := <-events:
+		t.Errorf("received event after unsubscribe: %+v", e)
+	default:
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "shutdown.sqlite3")
+	server, err := New(dbPath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, finished := make(chan struct{}), false
+	server.Jobs.Handle("slow", func(ctx context.Context, job dbgen.Job) (any, error) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		finished = true
+		return nil, nil
 	})
+	ctx := withOwner(context.Background(), "alice")
+	job, _ := server.Jobs.Enqueue(ctx, "slow", nil, nil)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveCtx, stop := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- server.serve(serveCtx, ln) }()
+	base := "http://" + ln.Addr().String()
+
+	// An open event stream must not hold up shutdown.
+	req, _ := http.NewRequest("GET", base+"/api/events", nil)
+	req.Header.Set("X-ExeDev-UserID", "alice")
+	events, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+
+	req, _ = http.NewRequest("POST", base+"/api/bookmarks", strings.NewReader(strings.Repeat("x", 2<<20)))
+	req.Header.Set("X-ExeDev-UserID", "alice")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != 413 {
+		t.Errorf("oversized body: %v, %v", resp, err)
+	}
+
+	<-started
+	stop()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not finish")
+	}
+	if !finished {
+		t.Error("shutdown interrupted a running job")
+	}
+	if server.DB.Ping() == nil {
+		t.Error("database still open")
+	}
+
+	wdb, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wdb.Close()
+	got, _ := dbgen.New(wdb).GetJob(ctx, dbgen.GetJobParams{OwnerID: "alice", ID: job.ID})
+	if got.Status != "succeeded" {
+		t.Errorf("job status %q after shutdown", got.Status)
+	}
 }
@@ -0,0 +1,374 @@
+package srv
+
+import (
+	"encoding/json"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// StructuredData is the schema.org item that describes a page, read from
+// JSON-LD or microdata. Fields the page doesn't give are empty.
+type StructuredData struct {
+	Type        string `json:"type"` // Article, VideoObject, Recipe, Product, Event or SoftwareSourceCode
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Published   string `json:"published,omitempty"`
+	Image       string `json:"image,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Rating      string `json:"rating,omitempty"` // aggregateRating.ratingValue
+
+	// VideoObject
+	Duration string `json:"duration,omitempty"` // ISO 8601, e.g. PT4M13S
+	EmbedURL string `json:"embed_url,omitempty"`
+
+	// Recipe
+	TotalTime   string   `json:"total_time,omitempty"`
+	Yield       string   `json:"yield,omitempty"`
+	Ingredients []string `json:"ingredients,omitempty"`
+
+	// Product
+	Price        string `json:"price,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	Availability string `json:"availability,omitempty"` // e.g. InStock
+	Brand        string `json:"brand,omitempty"`
+
+	// Event
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+	Location  string `json:"location,omitempty"`
+
+	// SoftwareSourceCode
+	ProgrammingLanguage string `json:"programming_language,omitempty"`
+	CodeRepository      string `json:"code_repository,omitempty"`
+	License             string `json:"license,omitempty"`
+}
+
+// articleTypes are the schema.org Article subtypes treated as Article.
+var articleTypes = []string{
+	"Article", "NewsArticle", "BlogPosting", "TechArticle", "ScholarlyArticle",
+	"Report", "SocialMediaPosting", "LiveBlogPosting", "DiscussionForumPosting",
+	"AnalysisNewsArticle", "OpinionNewsArticle", "ReportageNewsArticle",
+}
+
+// schemaType maps a schema.org type name or URL to the StructuredData
+// type it is read as, or "" for types that aren't supported.
+func schemaType(t string) string {
+	t = strings.TrimSpace(t)
+	for _, prefix := range []string{"http://schema.org/", "https://schema.org/", "schema:"} {
+		t = strings.TrimPrefix(t, prefix)
+	}
+	switch {
+	case slices.Contains(articleTypes, t):
+		return "Article"
+	case t == "VideoObject", t == "Recipe", t == "Product", t == "SoftwareSourceCode":
+		return t
+	case strings.HasSuffix(t, "Event"):
+		return "Event"
+	}
+	return ""
+}
+
+// findStructuredData returns the first supported item in the document's
+// JSON-LD blocks, or failing that its microdata.
+func findStructuredData(doc *html.Node) *StructuredData {
+	var items []map[string]any
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Script {
+			if mediaType, _, _ := mime.ParseMediaType(attr(n, "type")); mediaType == "application/ld+json" {
+				var v any
+				if err := json.Unmarshal([]byte(nodeText(n)), &v); err == nil {
+					items = append(items, ldItems(v)...)
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	items = append(items, microdataItems(doc)...)
+
+	for _, item := range items {
+		for _, t := range ldStrings(item["@type"]) {
+			if typ := schemaType(t); typ != "" {
+				return structuredData(typ, item)
+			}
+		}
+	}
+	return nil
+}
+
+// ldItems flattens a JSON-LD value into its items, including those in
+// @graph and a page's mainEntity.
+func ldItems(v any) []map[string]any {
+	var items []map[string]any
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			items = append(items, ldItems(e)...)
+		}
+	case map[string]any:
+		if _, ok := v["@type"]; ok {
+			items = append(items, v)
+		}
+		items = append(items, ldItems(v["@graph"])...)
+		items = append(items, ldItems(v["mainEntity"])...)
+	}
+	return items
+}
+
+// structuredData reads the fields of a JSON-LD item, or a microdata item
+// converted to the same shape.
+func structuredData(typ string, item map[string]any) *StructuredData {
+	sd := &StructuredData{
+		Type:        typ,
+		Name:        firstNonEmpty(ldString(item["headline"]), ldString(item["name"])),
+		Description: ldString(item["description"]),
+		Author:      strings.Join(ldStrings(firstValue(item, "author", "creator")), ", "),
+		Published:   ldString(firstValue(item, "datePublished", "uploadDate", "dateCreated")),
+		Image:       ldURL(firstValue(item, "image", "thumbnailUrl")),
+		URL:         ldURL(item["url"]),
+	}
+	if rating, ok := item["aggregateRating"].(map[string]any); ok {
+		sd.Rating = ldString(rating["ratingValue"])
+	}
+	switch typ {
+	case "VideoObject":
+		sd.Duration = ldString(item["duration"])
+		sd.EmbedURL = ldURL(item["embedUrl"])
+	case "Recipe":
+		sd.TotalTime = ldString(item["totalTime"])
+		sd.Yield = ldString(item["recipeYield"])
+		sd.Ingredients = ldStrings(firstValue(item, "recipeIngredient", "ingredients"))
+	case "Product":
+		offer := firstMap(item["offers"])
+		sd.Price = firstNonEmpty(ldString(offer["price"]), ldString(offer["lowPrice"]))
+		sd.Currency = ldString(offer["priceCurrency"])
+		sd.Availability = schemaEnum(ldString(offer["availability"]))
+		sd.Brand = ldString(item["brand"])
+	case "Event":
+		sd.StartDate = ldString(item["startDate"])
+		sd.EndDate = ldString(item["endDate"])
+		sd.Location = ldLocation(item["location"])
+	case "SoftwareSourceCode":
+		sd.ProgrammingLanguage = strings.Join(ldStrings(item["programmingLanguage"]), ", ")
+		sd.CodeRepository = ldURL(item["codeRepository"])
+		sd.License = firstNonEmpty(ldURL(item["license"]), ldString(item["license"]))
+	}
+	return sd
+}
+
+// ldString returns a text value: a string or number, the name or @value
+// of an object, or the first such value in a list.
+func ldString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return collapseSpace(html.UnescapeString(v))
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]any:
+		return firstNonEmpty(ldString(v["name"]), ldString(v["@value"]))
+	case []any:
+		for _, e := range v {
+			if s := ldString(e); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// ldStrings returns every text value in v.
+func ldStrings(v any) []string {
+	list, ok := v.([]any)
+	if !ok {
+		list = []any{v}
+	}
+	var values []string
+	for _, e := range list {
+		if s := ldString(e); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// ldURL returns a URL value: a string, the url, contentUrl or @id of an
+// object, or the first such value in a list.
+func ldURL(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return firstNonEmpty(ldURL(v["url"]), ldURL(v["contentUrl"]), ldURL(v["@id"]))
+	case []any:
+		for _, e := range v {
+			if s := ldURL(e); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// ldLocation formats an event's Place as "name, address", or returns the
+// URL of a VirtualLocation.
+func ldLocation(v any) string {
+	place := firstMap(v)
+	if place == nil {
+		return ldString(v)
+	}
+	var parts []string
+	if name := ldString(place["name"]); name != "" {
+		parts = append(parts, name)
+	}
+	if address, ok := place["address"].(map[string]any); ok {
+		for _, key := range []string{"streetAddress", "addressLocality", "addressRegion", "addressCountry"} {
+			if s := ldString(address[key]); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	} else if s := ldString(place["address"]); s != "" {
+		parts = append(parts, s)
+	}
+	if len(parts) == 0 {
+		return ldURL(place["url"])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// schemaEnum shortens "https://schema.org/InStock" to "InStock".
+func schemaEnum(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
+
+func firstMap(v any) map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		return v
+	case []any:
+		for _, e := range v {
+			if m, ok := e.(map[string]any); ok {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+func firstValue(item map[string]any, keys ...string) any {
+	for _, k := range keys {
+		if v, ok := item[k]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// microdataItems returns the top-level microdata items under n in the
+// same shape as JSON-LD: "@type" and one key per itemprop, with repeated
+// properties as lists and nested items as maps.
+func microdataItems(n *html.Node) []map[string]any {
+	var items []map[string]any
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if hasAttr(c, "itemscope") && !hasAttr(c, "itemprop") {
+			items = append(items, microdataItem(c))
+		}
+		items = append(items, microdataItems(c)...)
+	}
+	return items
+}
+
+func microdataItem(n *html.Node) map[string]any {
+	item := map[string]any{}
+	if types := strings.Fields(attr(n, "itemtype")); len(types) > 0 {
+		item["@type"] = types[0]
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			props := strings.Fields(attr(c, "itemprop"))
+			if len(props) > 0 {
+				var v any
+				if hasAttr(c, "itemscope") {
+					v = microdataItem(c)
+				} else {
+					v = microdataValue(c)
+				}
+				for _, p := range props {
+					switch existing := item[p].(type) {
+					case nil:
+						item[p] = v
+					case []any:
+						item[p] = append(existing, v)
+					default:
+						item[p] = []any{existing, v}
+					}
+				}
+			}
+			// A nested item's properties belong to it.
+			if !hasAttr(c, "itemscope") {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return item
+}
+
+// microdataValue returns a property's value as the microdata spec defines
+// it for the element. A content attribute is accepted on any element.
+func microdataValue(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.Meta:
+		return strings.TrimSpace(attr(n, "content"))
+	case atom.A, atom.Area, atom.Link:
+		return strings.TrimSpace(attr(n, "href"))
+	case atom.Img, atom.Audio, atom.Video, atom.Source, atom.Embed, atom.Iframe, atom.Track:
+		return strings.TrimSpace(attr(n, "src"))
+	case atom.Object:
+		return strings.TrimSpace(attr(n, "data"))
+	case atom.Time:
+		if hasAttr(n, "datetime") {
+			return strings.TrimSpace(attr(n, "datetime"))
+		}
+	case atom.Data, atom.Meter:
+		return strings.TrimSpace(attr(n, "value"))
+	}
+	if hasAttr(n, "content") {
+		return strings.TrimSpace(attr(n, "content"))
+	}
+	return collapseSpace(nodeText(n))
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return true
+		}
+	}
+	return false
+}
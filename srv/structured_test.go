@@ -0,0 +1,102 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStructuredData(t *testing.T) {
+	tests := []struct {
+		name, html string
+		want       *StructuredData
+	}{
+		{
+			name: "JSON-LD recipe in a graph",
+			html: `<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+				{"@type": "WebSite", "name": "Cooking"},
+				{"@type": "Recipe", "name": "Pancakes &amp; Syrup", "author": [{"@type": "Person", "name": "Ann"}, {"name": "Bob"}],
+				 "image": {"@type": "ImageObject", "url": "/pancakes.jpg"}, "totalTime": "PT20M", "recipeYield": ["4", "4 servings"],
+				 "recipeIngredient": ["2 eggs", "1 cup flour"], "aggregateRating": {"ratingValue": 4.5}}]}</script>`,
+			want: &StructuredData{Type: "Recipe", Name: "Pancakes & Syrup", Author: "Ann, Bob", Image: "https://example.com/pancakes.jpg",
+				Rating: "4.5", TotalTime: "PT20M", Yield: "4", Ingredients: []string{"2 eggs", "1 cup flour"}},
+		},
+		{
+			name: "JSON-LD event",
+			html: `<script type="application/ld+json">[{"@type": "MusicEvent", "name": "Jazz Night", "startDate": "2025-06-01T20:00",
+				"location": {"@type": "Place", "name": "Blue Room", "address": {"addressLocality": "Oslo", "addressCountry": "NO"}}}]</script>`,
+			want: &StructuredData{Type: "Event", Name: "Jazz Night", StartDate: "2025-06-01T20:00", Location: "Blue Room, Oslo, NO"},
+		},
+		{
+			name: "microdata product",
+			html: `<div itemscope itemtype="https://schema.org/Product"><h1 itemprop="name">Kettle</h1>
+				<div itemprop="brand" itemscope itemtype="https://schema.org/Brand"><span itemprop="name">Boil Co</span></div>
+				<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+				<span itemprop="price" content="39.90">$39.90</span><meta itemprop="priceCurrency" content="USD">
+				<link itemprop="availability" href="https://schema.org/InStock"></div></div>`,
+			want: &StructuredData{Type: "Product", Name: "Kettle", Price: "39.90", Currency: "USD", Availability: "InStock", Brand: "Boil Co"},
+		},
+		{
+			name: "JSON-LD is preferred and unsupported types skipped",
+			html: `<script type="application/ld+json">{"@type": "Organization", "name": "Acme"}</script>
+				<script type="application/ld+json">{"@type": "VideoObject", "name": "Demo", "duration": "PT4M13S", "uploadDate": "2024-01-02",
+				"embedUrl": "https://video.example.com/embed/1"}</script>
+				<div itemscope itemtype="http://schema.org/Article"><span itemprop="headline">Ignored</span></div>`,
+			want: &StructuredData{Type: "VideoObject", Name: "Demo", Published: "2024-01-02", Duration: "PT4M13S", EmbedURL: "https://video.example.com/embed/1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := &PageMetadata{URL: "https://example.com/page"}
+			if err := page.parse(strings.NewReader("<html><body>" + tt.html + "</body></html>")); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(page.Structured, tt.want) {
+				got, _ := json.Marshal(page.Structured)
+				t.Errorf("got %s", got)
+			}
+		})
+	}
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Repo</title><script type="application/ld+json">{"@type": "SoftwareSourceCode",
+			"name": "widget", "programmingLanguage": {"name": "Go"}, "codeRepository": "https://git.example.com/widget",
+			"author": "Carol", "license": "https://opensource.org/licenses/MIT"}</script></head></html>`))
+	}))
+	defer site.Close()
+	server, err := New(filepath.Join(t.TempDir(), "structured.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := server.Handler()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-ExeDev-UserID", "alice")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("POST", "/api/bookmarks", `{"url": "`+site.URL+`"}`); w.Code != 201 {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	var got struct {
+		Structured *StructuredData `json:"structured"`
+	}
+	json.NewDecoder(do("GET", "/api/bookmarks/1", "").Body).Decode(&got)
+	want := &StructuredData{Type: "SoftwareSourceCode", Name: "widget", Author: "Carol", ProgrammingLanguage: "Go",
+		CodeRepository: "https://git.example.com/widget", License: "https://opensource.org/licenses/MIT"}
+	if !reflect.DeepEqual(got.Structured, want) {
+		t.Errorf("stored structured data %+v", got.Structured)
+	}
+
+	// Other users can't read it.
+	ctx := withOwner(context.Background(), "bob")
+	if sd, _, err := server.loadBookmarkMetadata(ctx, 1); sd != nil || err != nil {
+		t.Errorf("bob read %+v, %v", sd, err)
+	}
+}
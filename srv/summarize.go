@@ -1,10 +1,9 @@
 package srv
 
 import (
+	"context"
 	"encoding/json"
-	"io"
 	"net/http"
-	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -45,6 +44,12 @@ var stopWords = map[string]bool{
 type ContentAnalysis struct {
 	Summary  string   `json:"summary"`
 	Keywords []string `json:"keywords"`
+
+	// Page content kept for follow-up steps such as auto-tagging.
+	title       string
+	description string
+	text        string
+	page        *PageMetadata
 }
 
 func (s *Server) HandleAnalyzeURL(w http.ResponseWriter, r *http.Request) {
@@ -56,7 +61,7 @@ func (s *Server) HandleAnalyzeURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	analysis, err := analyzeURL(req.URL)
+	analysis, err := s.analyzeURL(r.Context(), req.URL)
 	if err != nil {
 		writeError(w, err.Error(), 500)
 		return
@@ -65,223 +70,86 @@ func (s *Server) HandleAnalyzeURL(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, analysis)
 }
 
-func analyzeURL(url string) (*ContentAnalysis, error) {
-	client := &http.Client{Timeout: 15 * time.Second}
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-	resp, err := client.Do(req)
+func (s *Server) analyzeURL(ctx context.Context, url string) (*ContentAnalysis, error) {
+	page, err := s.fetchPage(ctx, url)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 500000)) // 500KB max
-	html := string(body)
-
-	// Extract metadata
-	title := extractMetaContent(html, "og:title")
-	if title == "" {
-		re := regexp.MustCompile(`(?i)<title[^>]*>([^<]+)</title>`)
-		if m := re.FindStringSubmatch(html); len(m) > 1 {
-			title = strings.TrimSpace(m[1])
-		}
-	}
-	description := extractMetaContent(html, "og:description")
-	if description == "" {
-		description = extractMetaContent(html, "description")
-	}
-
-	// Extract text for keywords and LLM
-	text := extractText(html)
-	keywords := extractKeywords(text)
+	return s.analyzePage(ctx, page), nil
+}
 
-	// Try LLM summarization first
-	summary, err := summarizeWithLLM(title, description, text, url)
+// analyzePage summarizes a fetched page, with the LLM when one is
+// configured and from the page metadata otherwise.
+func (s *Server) analyzePage(ctx context.Context, page *PageMetadata) *ContentAnalysis {
+	summary, err := summarizeWithLLM(ctx, s.llm(), page.Title, page.Description, page.Text, page.URL)
 	if err != nil {
-		// Fall back to metadata-based summary
-		summary = generateSummary(html, url)
+		summary = generateSummary(page)
 	}
-
 	return &ContentAnalysis{
-		Summary:  summary,
-		Keywords: keywords,
-	}, nil
-}
-
-func extractText(html string) string {
-	// Remove script tags and their content
-	re := regexp.MustCompile(`(?is)<script[^>]*>.*?</script>`)
-	html = re.ReplaceAllString(html, " ")
-	
-	// Remove style tags
-	re = regexp.MustCompile(`(?is)<style[^>]*>.*?</style>`)
-	html = re.ReplaceAllString(html, " ")
-	
-	// Remove noscript
-	re = regexp.MustCompile(`(?is)<noscript[^>]*>.*?</noscript>`)
-	html = re.ReplaceAllString(html, " ")
-	
-	// Remove JSON-LD
-	re = regexp.MustCompile(`(?is)<script[^>]*type=["']application/ld\+json["'][^>]*>.*?</script>`)
-	html = re.ReplaceAllString(html, " ")
-
-	// Remove nav, footer, header, aside
-	for _, tag := range []string{"nav", "footer", "header", "aside", "menu"} {
-		re = regexp.MustCompile(`(?is)<` + tag + `[^>]*>.*?</` + tag + `>`)
-		html = re.ReplaceAllString(html, " ")
+		Summary:     summary,
+		Keywords:    extractKeywords(page.Text),
+		title:       page.Title,
+		description: page.Description,
+		text:        page.Text,
+		page:        page,
 	}
-
-	// Remove all HTML tags
-	re = regexp.MustCompile(`<[^>]+>`)
-	text := re.ReplaceAllString(html, " ")
-
-	// Decode HTML entities
-	text = decodeHTMLEntities(text)
-
-	// Remove any remaining JavaScript-like content
-	re = regexp.MustCompile(`\{[^}]*\}`)
-	text = re.ReplaceAllString(text, " ")
-	re = regexp.MustCompile(`\[[^\]]*\]`)
-	text = re.ReplaceAllString(text, " ")
-
-	// Normalize whitespace
-	re = regexp.MustCompile(`\s+`)
-	text = re.ReplaceAllString(text, " ")
-
-	return strings.TrimSpace(text)
 }
 
-func decodeHTMLEntities(text string) string {
-	replacements := map[string]string{
-		"&nbsp;": " ", "&amp;": "&", "&lt;": "<", "&gt;": ">",
-		"&quot;": "\"", "&#39;": "'", "&apos;": "'",
-		"&mdash;": "—", "&ndash;": "–", "&hellip;": "...",
-		"&copy;": "©", "&reg;": "®", "&trade;": "™",
-	}
-	for entity, char := range replacements {
-		text = strings.ReplaceAll(text, entity, char)
+// generateSummary describes a page from its metadata.
+func generateSummary(page *PageMetadata) string {
+	// Videos and other embeds without a description are best described by
+	// their oEmbed data.
+	if page.OEmbed != nil && page.Description == "" {
+		return describeOEmbed(page.OEmbed)
 	}
-	// Remove numeric entities
-	re := regexp.MustCompile(`&#\d+;`)
-	text = re.ReplaceAllString(text, " ")
-	return text
-}
 
-func generateSummary(html, url string) string {
 	var parts []string
+	title, description, siteName := page.Title, page.Description, page.SiteName
 
-	// 1. Get the title
-	title := extractMetaContent(html, "og:title")
-	if title == "" {
-		title = extractMetaContent(html, "twitter:title")
-	}
-	if title == "" {
-		re := regexp.MustCompile(`(?i)<title[^>]*>([^<]+)</title>`)
-		if m := re.FindStringSubmatch(html); len(m) > 1 {
-			title = strings.TrimSpace(m[1])
-		}
-	}
-
-	// 2. Get description
-	description := extractMetaContent(html, "og:description")
-	if description == "" {
-		description = extractMetaContent(html, "description")
-	}
-	if description == "" {
-		description = extractMetaContent(html, "twitter:description")
-	}
-
-	// 3. Get site name
-	siteName := extractMetaContent(html, "og:site_name")
-	if siteName == "" {
-		siteName = extractMetaContent(html, "application-name")
-	}
-
-	// 4. Get type/category
-	contentType := extractMetaContent(html, "og:type")
-
-	// 5. Get author
-	author := extractMetaContent(html, "author")
-	if author == "" {
-		author = extractMetaContent(html, "article:author")
-	}
-
-	// 6. Get publish date
-	publishDate := extractMetaContent(html, "article:published_time")
-	if publishDate == "" {
-		publishDate = extractMetaContent(html, "datePublished")
-	}
-
-	// Build human-readable summary
 	if siteName != "" && siteName != title {
 		parts = append(parts, "From "+siteName+".")
 	}
 
-	if contentType != "" && contentType != "website" {
-		parts = append(parts, strings.Title(strings.ReplaceAll(contentType, "_", " "))+".")
+	if page.Type != "" && page.Type != "website" {
+		parts = append(parts, strings.Title(strings.ReplaceAll(page.Type, "_", " "))+".")
 	}
 
 	if description != "" {
-		// Clean up description
-		description = decodeHTMLEntities(description)
-		description = strings.TrimSpace(description)
-		if len(description) > 400 {
-			description = description[:400] + "..."
-		}
-		parts = append(parts, description)
+		parts = append(parts, truncate(description, 400))
 	}
 
-	if author != "" {
-		parts = append(parts, "By "+author+".")
+	if page.Author != "" {
+		parts = append(parts, "By "+page.Author+".")
 	}
 
-	if publishDate != "" {
-		// Try to format date nicely
-		if t, err := time.Parse(time.RFC3339, publishDate); err == nil {
-			parts = append(parts, "Published "+t.Format("January 2, 2006")+".")
-		} else if t, err := time.Parse("2006-01-02", publishDate); err == nil {
-			parts = append(parts, "Published "+t.Format("January 2, 2006")+".")
-		}
+	if published := parsePublished(page.Published); !published.IsZero() {
+		parts = append(parts, "Published "+published.Format("January 2, 2006")+".")
 	}
 
-	// If we still don't have a good description, try to get first paragraph
-	if description == "" {
-		firstPara := extractFirstParagraph(html)
-		if firstPara != "" {
-			parts = append(parts, firstPara)
-		}
+	// If we still don't have a good description, use the first paragraph
+	if description == "" && page.lead != "" {
+		parts = append(parts, page.lead)
 	}
 
 	// Detect content type from URL if not specified
 	if len(parts) == 0 || (len(parts) == 1 && siteName != "") {
-		urlLower := strings.ToLower(url)
+		urlLower := strings.ToLower(page.URL)
 		switch {
-		case strings.Contains(urlLower, "youtube.com") || strings.Contains(urlLower, "youtu.be"):
-			videoTitle := extractMetaContent(html, "og:title")
-			if videoTitle != "" {
-				parts = append(parts, "YouTube video: "+videoTitle)
-			}
 		case strings.Contains(urlLower, "instagram.com"):
 			parts = append(parts, "Instagram post.")
 		case strings.Contains(urlLower, "linkedin.com"):
 			parts = append(parts, "LinkedIn content.")
-		case strings.Contains(urlLower, "twitter.com") || strings.Contains(urlLower, "x.com"):
-			parts = append(parts, "Twitter/X post.")
 		case strings.Contains(urlLower, "github.com"):
 			parts = append(parts, "GitHub repository or page.")
 		}
 	}
 
-	summary := strings.Join(parts, " ")
-	
-	// Final cleanup
-	summary = strings.TrimSpace(summary)
-	
+	summary := strings.TrimSpace(strings.Join(parts, " "))
+
 	// Make sure we don't have JavaScript garbage
-	if strings.Contains(summary, "function") || strings.Contains(summary, "window.") || 
-	   strings.Contains(summary, "{") || strings.Contains(summary, "var ") ||
-	   strings.Contains(summary, "ytcfg") || strings.Contains(summary, "ytplayer") {
+	if strings.Contains(summary, "function") || strings.Contains(summary, "window.") ||
+		strings.Contains(summary, "{") || strings.Contains(summary, "var ") ||
+		strings.Contains(summary, "ytcfg") || strings.Contains(summary, "ytplayer") {
 		// Fall back to just title + site
 		parts = []string{}
 		if siteName != "" {
@@ -300,55 +168,15 @@ func generateSummary(html, url string) string {
 	return summary
 }
 
-func extractMetaContent(html, name string) string {
-	// Try property attribute (og:, twitter:)
-	patterns := []string{
-		`(?i)<meta[^>]+property=["']` + regexp.QuoteMeta(name) + `["'][^>]+content=["']([^"']+)["']`,
-		`(?i)<meta[^>]+content=["']([^"']+)["'][^>]+property=["']` + regexp.QuoteMeta(name) + `["']`,
-		`(?i)<meta[^>]+name=["']` + regexp.QuoteMeta(name) + `["'][^>]+content=["']([^"']+)["']`,
-		`(?i)<meta[^>]+content=["']([^"']+)["'][^>]+name=["']` + regexp.QuoteMeta(name) + `["']`,
-	}
-
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		if m := re.FindStringSubmatch(html); len(m) > 1 {
-			return strings.TrimSpace(decodeHTMLEntities(m[1]))
-		}
-	}
-	return ""
-}
-
-func extractFirstParagraph(html string) string {
-	// Look for article content first
-	re := regexp.MustCompile(`(?is)<article[^>]*>(.*?)</article>`)
-	if m := re.FindStringSubmatch(html); len(m) > 1 {
-		html = m[1]
-	}
-
-	// Find first meaningful paragraph
-	re = regexp.MustCompile(`(?is)<p[^>]*>([^<]{100,})</p>`)
-	matches := re.FindAllStringSubmatch(html, 5)
-	
-	for _, m := range matches {
-		if len(m) > 1 {
-			text := strings.TrimSpace(m[1])
-			text = regexp.MustCompile(`<[^>]+>`).ReplaceAllString(text, "")
-			text = decodeHTMLEntities(text)
-			text = strings.TrimSpace(text)
-			
-			// Skip if it looks like code or garbage
-			if strings.Contains(text, "{") || strings.Contains(text, "function") ||
-			   strings.Contains(text, "var ") || len(text) < 50 {
-				continue
-			}
-			
-			if len(text) > 300 {
-				text = text[:300] + "..."
-			}
-			return text
+// parsePublished parses the common date formats used for publication
+// dates. It returns the zero time for anything else.
+func parsePublished(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04:05Z0700", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
 		}
 	}
-	return ""
+	return time.Time{}
 }
 
 func extractKeywords(text string) []string {
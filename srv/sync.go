@@ -0,0 +1,848 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Data sync keeps each user's library in its own git working directory
+// under SyncDir and exchanges it with the user's own branch of the
+// configured remote, named after the configured branch. Bookmarks are
+// written one JSON file each, grouped into a directory per collection;
+// collections.json and tags.json hold the rest of the library.
+const (
+	syncBookmarksDir    = "bookmarks"
+	syncUnsortedDir     = "_unsorted"
+	syncCollectionsFile = "collections.json"
+	syncTagsFile        = "tags.json"
+)
+
+// Commits made by sync use this identity unless another is configured.
+const (
+	syncAuthorName  = "Bookmark Manager"
+	syncAuthorEmail = "bookmarks@localhost"
+)
+
+// syncBookmark is a bookmark as stored in the sync repository. Bookmarks
+// are matched by URL, since IDs differ between databases.
+type syncBookmark struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Summary     string    `json:"summary,omitempty"`
+	SourceType  string    `json:"source_type"`
+	FaviconURL  string    `json:"favicon_url,omitempty"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	Keywords    []string  `json:"keywords,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Collections []string  `json:"collections,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type syncCollection struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+}
+
+type syncTag struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// errSyncRunning is returned when the user already has a sync running.
+var errSyncRunning = errors.New("a sync is already running")
+
+// SyncResult reports what a sync run did.
+type SyncResult struct {
+	Commit  string `json:"commit,omitempty"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+	Deleted int    `json:"deleted"`
+	// Conflicts counts bookmarks both sides changed; they are listed by
+	// GET /api/sync/conflicts.
+	Conflicts int    `json:"conflicts"`
+	Pushed    bool   `json:"pushed"`
+	Branch    string `json:"branch"`
+	Head      string `json:"head,omitempty"`
+}
+
+// HandleSyncPull commits local changes, merges the remote branch and
+// imports what changed into the database.
+func (s *Server) HandleSyncPull(w http.ResponseWriter, r *http.Request) {
+	s.handleSync(w, r, false)
+}
+
+// HandleSyncPush does everything HandleSyncPull does and then pushes.
+func (s *Server) HandleSyncPush(w http.ResponseWriter, r *http.Request) {
+	s.handleSync(w, r, true)
+}
+
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request, push bool) {
+	config, err := s.loadGitConfig(r.Context())
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	res, err := s.syncLibrary(r.Context(), config, push)
+	if errors.Is(err, errSyncRunning) {
+		writeError(w, err.Error(), 409)
+		return
+	} else if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, res)
+}
+
+// syncLibrary brings the current user's sync repository up to date with
+// the database, merges in the remote branch and, if push is set, pushes
+// the result. Without a remote only the local commit is made.
+func (s *Server) syncLibrary(ctx context.Context, config GitHubConfig, push bool) (*SyncResult, error) {
+	// Syncs share the user's working tree, so only one may run at a time.
+	lock, _ := s.syncLocks.LoadOrStore(syncOwnerName(ctx), &sync.Mutex{})
+	if !lock.(*sync.Mutex).TryLock() {
+		return nil, errSyncRunning
+	}
+	defer lock.(*sync.Mutex).Unlock()
+
+	config = config.withDefaults(s.GitDefaults)
+	config.Branch += "-" + syncOwnerName(ctx)
+	repo, err := s.openSyncRepo(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	res := &SyncResult{Branch: config.Branch}
+	// A new repository starts from the remote branch, so the library
+	// becomes one history rather than two unrelated ones.
+	if repo.head(ctx) == "" && config.Repo != "" {
+		if err := s.mergeRemote(ctx, repo, config.Branch, res); err != nil {
+			return nil, err
+		}
+	}
+	if res.Commit, err = s.commitLibrary(ctx, repo); err != nil {
+		return nil, err
+	}
+	if config.Repo != "" {
+		if err := s.mergeRemote(ctx, repo, config.Branch, res); err != nil {
+			return nil, err
+		}
+		if push {
+			if repo.head(ctx) != "" {
+				if _, err := repo.git(ctx, "push", repo.remote, "HEAD:refs/heads/"+config.Branch); err != nil {
+					return nil, err
+				}
+				res.Pushed = true
+			}
+		}
+	}
+	res.Head = repo.head(ctx)
+	return res, nil
+}
+
+// gitRepo runs git in a working directory, committing as the configured
+// author and authenticating to the remote as config.Auth says.
+type gitRepo struct {
+	dir    string
+	remote string
+	args   []string // -c options for every command
+	env    []string
+}
+
+// credentialHelper answers git's credential requests from the environment,
+// so the token is never written to .git/config or shown in the process
+// list. It is only registered for the configured repository URL, so a
+// remote that points or redirects elsewhere never receives the token.
+const credentialHelper = `!f() { test "$1" = get && printf 'username=%s\npassword=%s\n' "$BOOKMARKS_GIT_USERNAME" "$BOOKMARKS_GIT_TOKEN"; }; f`
+
+func newGitRepo(dir string, config GitHubConfig) gitRepo {
+	g := gitRepo{
+		dir:    dir,
+		remote: config.Remote,
+		args:   []string{"-c", "user.name=" + config.AuthorName, "-c", "user.email=" + config.AuthorEmail},
+		env:    append(os.Environ(), "GIT_TERMINAL_PROMPT=0"),
+	}
+	switch config.Auth {
+	case GitAuthToken:
+		// The empty helper clears any configured for the user first.
+		g.args = append(g.args, "-c", "credential.helper=")
+		if config.Repo != "" {
+			g.args = append(g.args, "-c", "credential."+config.Repo+".helper="+credentialHelper)
+		}
+		g.env = append(g.env, "BOOKMARKS_GIT_USERNAME="+config.Username, "BOOKMARKS_GIT_TOKEN="+config.Token)
+	case GitAuthSSH:
+		ssh := "ssh -o BatchMode=yes"
+		if config.SSHKey != "" {
+			ssh += " -o IdentitiesOnly=yes -i " + shellQuote(config.SSHKey)
+		}
+		g.env = append(g.env, "GIT_SSH_COMMAND="+ssh)
+	}
+	return g
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (g gitRepo) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", slices.Concat(g.args, args)...)
+	cmd.Dir = g.dir
+	cmd.Env = g.env
+	return cmd
+}
+
+func (g gitRepo) git(ctx context.Context, args ...string) (string, error) {
+	out, err := g.command(ctx, args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// head returns the current commit, or "" on an unborn branch.
+func (g gitRepo) head(ctx context.Context) string {
+	out, err := g.git(ctx, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+var (
+	unsafePathChars  = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	unsafeOwnerChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// syncOwnerName returns the current user's ID made safe for both a
+// directory and a branch name. IDs that had to be changed get a hash of
+// the original, so that two users never share a repository or branch.
+func syncOwnerName(ctx context.Context) string {
+	id := ownerID(ctx)
+	name := unsafeOwnerChars.ReplaceAllString(id, "_")
+	if name == id && name != "" {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return fmt.Sprintf("%s_%08x", name, h.Sum32())
+}
+
+// openSyncRepo creates the current user's sync repository if needed and
+// points its remote at config.Repo.
+func (s *Server) openSyncRepo(ctx context.Context, config GitHubConfig) (gitRepo, error) {
+	repo := newGitRepo(filepath.Join(s.SyncDir, syncOwnerName(ctx)), config)
+	if err := os.MkdirAll(repo.dir, 0o700); err != nil {
+		return repo, err
+	}
+	if _, err := os.Stat(filepath.Join(repo.dir, ".git")); errors.Is(err, fs.ErrNotExist) {
+		if _, err := repo.git(ctx, "init", "--initial-branch="+config.Branch); err != nil {
+			return repo, err
+		}
+	}
+	if config.Repo != "" {
+		if err := setupGitRemote(ctx, repo, config); err != nil {
+			return repo, err
+		}
+	}
+	return repo, nil
+}
+
+// libraryFiles renders the current user's library as repository paths and
+// file contents.
+func (s *Server) libraryFiles(ctx context.Context) (map[string][]byte, error) {
+	backup, err := s.exportBackup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tagNames := make(map[int64]string, len(backup.Tags))
+	tags := make([]syncTag, 0, len(backup.Tags))
+	for _, t := range backup.Tags {
+		tagNames[t.ID] = t.Name
+		tags = append(tags, syncTag{Name: t.Name, Color: deref(t.Color)})
+	}
+	collectionNames := make(map[int64]string, len(backup.Collections))
+	collections := make([]syncCollection, 0, len(backup.Collections))
+	for _, c := range backup.Collections {
+		collectionNames[c.ID] = c.Name
+		collections = append(collections, syncCollection{Name: c.Name, Description: deref(c.Description), Icon: deref(c.Icon)})
+	}
+	bookmarkTags := make(map[int64][]string)
+	for _, bt := range backup.BookmarkTags {
+		bookmarkTags[bt.BookmarkID] = append(bookmarkTags[bt.BookmarkID], tagNames[bt.TagID])
+	}
+	bookmarkCollections := make(map[int64][]string)
+	for _, bc := range backup.BookmarkCollections {
+		bookmarkCollections[bc.BookmarkID] = append(bookmarkCollections[bc.BookmarkID], collectionNames[bc.CollectionID])
+	}
+
+	files := make(map[string][]byte)
+	if files[syncTagsFile], err = marshalSyncFile(tags); err != nil {
+		return nil, err
+	}
+	if files[syncCollectionsFile], err = marshalSyncFile(collections); err != nil {
+		return nil, err
+	}
+	for _, b := range backup.Bookmarks {
+		rec := syncBookmark{
+			URL:         b.Url,
+			Title:       b.Title,
+			Description: deref(b.Description),
+			Summary:     deref(b.Summary),
+			SourceType:  b.SourceType,
+			FaviconURL:  deref(b.FaviconUrl),
+			ImageURL:    deref(b.ImageUrl),
+			Keywords:    bookmarkKeywords(b),
+			Tags:        bookmarkTags[b.ID],
+			Collections: bookmarkCollections[b.ID],
+			CreatedAt:   b.CreatedAt.UTC(),
+			UpdatedAt:   b.UpdatedAt.UTC(),
+		}
+		slices.Sort(rec.Tags)
+		slices.Sort(rec.Collections)
+		data, err := marshalSyncFile(rec)
+		if err != nil {
+			return nil, err
+		}
+		files[syncBookmarkPath(rec)] = data
+	}
+	return files, nil
+}
+
+func marshalSyncFile(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	return append(data, '\n'), err
+}
+
+// syncBookmarkPath files a bookmark under its first collection. The name
+// comes from the URL rather than the title so that renaming a bookmark
+// edits its file in place.
+func syncBookmarkPath(b syncBookmark) string {
+	dir := syncUnsortedDir
+	if len(b.Collections) > 0 {
+		dir = syncSlug(b.Collections[0], 60)
+	}
+	name := b.URL
+	if u, err := url.Parse(b.URL); err == nil && u.Host != "" {
+		name = strings.TrimPrefix(u.Host, "www.") + u.Path
+	}
+	h := fnv.New32a()
+	h.Write([]byte(b.URL))
+	return fmt.Sprintf("%s/%s/%s-%08x.json", syncBookmarksDir, dir, syncSlug(name, 60), h.Sum32())
+}
+
+func syncSlug(s string, max int) string {
+	slug := strings.Trim(unsafePathChars.ReplaceAllString(strings.ToLower(s), "-"), "-.")
+	if len(slug) > max {
+		slug = strings.TrimRight(slug[:max], "-.")
+	}
+	if slug == "" {
+		slug = "untitled"
+	}
+	return slug
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// commitLibrary writes the library into the working tree and commits any
+// difference, returning the commit message or "" if nothing changed.
+func (s *Server) commitLibrary(ctx context.Context, repo gitRepo) (string, error) {
+	files, err := s.libraryFiles(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := writeSyncFiles(repo.dir, files); err != nil {
+		return "", err
+	}
+	if _, err := repo.git(ctx, "add", "-A"); err != nil {
+		return "", err
+	}
+	status, err := repo.git(ctx, "status", "--porcelain", "--no-renames")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(status) == "" {
+		return "", nil
+	}
+	msg := syncCommitMessage(ctx, repo, status, files)
+	if _, err := repo.git(ctx, "commit", "--quiet", "-m", msg); err != nil {
+		return "", err
+	}
+	return msg, nil
+}
+
+// writeSyncFiles makes dir's library files match files exactly, leaving
+// unchanged files untouched.
+func writeSyncFiles(dir string, files map[string][]byte) error {
+	existing := []string{}
+	for _, name := range []string{syncTagsFile, syncCollectionsFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			existing = append(existing, name)
+		}
+	}
+	err := filepath.WalkDir(filepath.Join(dir, syncBookmarksDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		existing = append(existing, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, name := range existing {
+		if _, ok := files[name]; !ok {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return err
+		}
+	}
+	removeEmptyDirs(filepath.Join(dir, syncBookmarksDir))
+	return nil
+}
+
+func removeEmptyDirs(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			dir := filepath.Join(root, e.Name())
+			removeEmptyDirs(dir)
+			os.Remove(dir) // fails unless empty
+		}
+	}
+}
+
+// syncCommitMessage summarizes staged changes, for example
+// "Add 2 bookmarks, update 1" followed by one line per bookmark. A bookmark
+// that moved to another collection counts as updated.
+func syncCommitMessage(ctx context.Context, repo gitRepo, status string, files map[string][]byte) string {
+	changes := map[string]byte{}
+	labels := map[string]string{}
+	var order []string
+	other := false
+	for _, line := range strings.Split(strings.TrimRight(status, "\n"), "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		code, path := line[0], line[3:]
+		if !strings.HasPrefix(path, syncBookmarksDir+"/") {
+			other = true
+			continue
+		}
+		data := files[path]
+		if code == 'D' {
+			old, _ := repo.git(ctx, "show", "HEAD:"+path)
+			data = []byte(old)
+		}
+		var b syncBookmark
+		json.Unmarshal(data, &b)
+		key := b.URL
+		if key == "" {
+			key = path
+		}
+		if prev, ok := changes[key]; ok {
+			if prev != code {
+				changes[key] = 'M'
+			}
+			continue
+		}
+		changes[key] = code
+		labels[key] = syncBookmarkLabel(data)
+		order = append(order, key)
+	}
+
+	var added, updated, removed []string
+	for _, key := range order {
+		switch changes[key] {
+		case 'A':
+			added = append(added, "+ "+labels[key])
+		case 'D':
+			removed = append(removed, "- "+labels[key])
+		default:
+			updated = append(updated, "~ "+labels[key])
+		}
+	}
+
+	var parts []string
+	for _, c := range []struct {
+		verb  string
+		lines []string
+	}{{"Add", added}, {"Update", updated}, {"Remove", removed}} {
+		switch n := len(c.lines); {
+		case n == 0:
+		case len(parts) > 0:
+			parts = append(parts, fmt.Sprintf("%s %d", strings.ToLower(c.verb), n))
+		case n == 1:
+			parts = append(parts, c.verb+" 1 bookmark")
+		default:
+			parts = append(parts, fmt.Sprintf("%s %d bookmarks", c.verb, n))
+		}
+	}
+	subject := strings.Join(parts, ", ")
+	if subject == "" && other {
+		subject = "Update tags and collections"
+	}
+	body := slices.Concat(added, updated, removed)
+	if len(body) == 0 {
+		return subject
+	}
+	return subject + "\n\n" + strings.Join(body, "\n")
+}
+
+func syncBookmarkLabel(data []byte) string {
+	var b syncBookmark
+	if json.Unmarshal(data, &b) != nil || b.URL == "" {
+		return "(unreadable bookmark)"
+	}
+	if b.Title == "" || b.Title == b.URL {
+		return b.URL
+	}
+	return fmt.Sprintf("%s <%s>", b.Title, b.URL)
+}
+
+// mergeRemote merges the remote branch into the sync repository and
+// imports the files it changed. Diverged histories are merged bookmark by
+// bookmark (see mergeLibraries); conflicts keep the local version and are
+// recorded for the user to resolve.
+func (s *Server) mergeRemote(ctx context.Context, repo gitRepo, branch string, res *SyncResult) error {
+	heads, err := repo.git(ctx, "ls-remote", "--heads", repo.remote, branch)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(heads) == "" {
+		return nil // nothing pushed yet
+	}
+	if _, err := repo.git(ctx, "fetch", "--quiet", repo.remote, branch); err != nil {
+		return err
+	}
+	out, err := repo.git(ctx, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+	old, theirs := repo.head(ctx), strings.TrimSpace(out)
+	var base string
+	if old != "" {
+		out, _ := repo.git(ctx, "merge-base", old, theirs) // fails for unrelated histories
+		base = strings.TrimSpace(out)
+	}
+
+	var conflicts []syncConflict
+	switch {
+	case base == theirs:
+		return nil // already merged
+	case old == "":
+		_, err = repo.git(ctx, "reset", "--hard", "--quiet", theirs)
+	case base == old:
+		_, err = repo.git(ctx, "merge", "--ff-only", "--quiet", theirs)
+	default:
+		conflicts, err = s.mergeLibraries(ctx, repo, base, old, theirs)
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.importChanges(ctx, repo, old, repo.head(ctx), res); err != nil {
+		// Leave the repository where it was, so the next sync merges again.
+		if old != "" {
+			repo.git(ctx, "reset", "--hard", "--quiet", old)
+		}
+		return err
+	}
+	res.Conflicts += len(conflicts)
+	return s.recordConflicts(ctx, conflicts)
+}
+
+// importChanges applies the library files that changed between commits
+// old and head to the database. An empty old means everything is new.
+func (s *Server) importChanges(ctx context.Context, repo gitRepo, old, head string, res *SyncResult) error {
+	var diff string
+	var err error
+	if old == "" {
+		if diff, err = repo.git(ctx, "ls-tree", "-r", "--name-only", head); err == nil {
+			diff = "A\t" + strings.ReplaceAll(strings.TrimSpace(diff), "\n", "\nA\t")
+		}
+	} else {
+		diff, err = repo.git(ctx, "diff", "--name-status", "--no-renames", old, head)
+	}
+	if err != nil {
+		return err
+	}
+	readAt := func(rev, path string) []byte {
+		out, err := repo.git(ctx, "show", rev+":"+path)
+		if err != nil {
+			return nil
+		}
+		return []byte(out)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	var events []Event
+
+	// Files are applied in two passes so that a bookmark whose file moved
+	// to another collection directory is updated rather than deleted.
+	var removed []string
+	var needImages []int64
+	present := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(diff), "\n") {
+		status, path, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		switch {
+		case path == syncTagsFile:
+			if err := importSyncTags(ctx, q, readAt(head, path)); err != nil {
+				return err
+			}
+		case path == syncCollectionsFile:
+			if err := importSyncCollections(ctx, q, readAt(old, path), readAt(head, path)); err != nil {
+				return err
+			}
+		case !strings.HasPrefix(path, syncBookmarksDir+"/"):
+		case status == "D":
+			removed = append(removed, path)
+		default:
+			var rec syncBookmark
+			if err := json.Unmarshal(readAt(head, path), &rec); err != nil || rec.URL == "" {
+				return fmt.Errorf("read %s: invalid bookmark file", path)
+			}
+			present[rec.URL] = true
+			b, created, err := importSyncBookmark(ctx, q, rec)
+			if err != nil {
+				return fmt.Errorf("import %s: %w", path, err)
+			}
+			if rec.ImageURL != "" && b.ImageUrl == nil {
+				needImages = append(needImages, b.ID)
+			}
+			if created {
+				res.Created++
+				events = append(events, Event{Type: EventBookmarkCreated, Data: b})
+			} else {
+				res.Updated++
+				events = append(events, Event{Type: EventBookmarkUpdated, Data: b})
+			}
+		}
+	}
+	for _, path := range removed {
+		var rec syncBookmark
+		if json.Unmarshal(readAt(old, path), &rec) != nil || present[rec.URL] {
+			continue
+		}
+		b, err := q.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: ownerID(ctx), Url: rec.URL})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := q.DeleteBookmark(ctx, dbgen.DeleteBookmarkParams{OwnerID: ownerID(ctx), ID: b.ID}); err != nil {
+			return err
+		}
+		res.Deleted++
+		events = append(events, Event{Type: EventBookmarkDeleted, Data: map[string]int64{"id": b.ID}})
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, e := range events {
+		s.Events.Publish(ctx, e.Type, e.Data)
+	}
+	s.queueMetadata(ctx, needImages)
+	return nil
+}
+
+// importSyncBookmark creates or updates the bookmark with rec's URL, making
+// its tags and collections match rec. Only a preview image cached here is
+// stored; otherwise an existing bookmark keeps its own.
+func importSyncBookmark(ctx context.Context, q *dbgen.Queries, rec syncBookmark) (dbgen.Bookmark, bool, error) {
+	owner := ownerID(ctx)
+	var keywords *string
+	if len(rec.Keywords) > 0 {
+		data, _ := json.Marshal(rec.Keywords)
+		keywords = strPtr(string(data))
+	}
+	if rec.SourceType == "" {
+		rec.SourceType = "web"
+	}
+
+	b, err := q.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: owner, Url: rec.URL})
+	created := errors.Is(err, sql.ErrNoRows)
+	image := localImageURL(ctx, q, rec.ImageURL)
+	if image == "" && err == nil {
+		image = localImageURL(ctx, q, deref(b.ImageUrl))
+	}
+	switch {
+	case created:
+		b, err = q.ImportBookmark(ctx, dbgen.ImportBookmarkParams{
+			OwnerID: owner, Url: rec.URL, Title: rec.Title,
+			Description: strPtr(rec.Description), Summary: strPtr(rec.Summary),
+			SourceType: rec.SourceType, FaviconUrl: strPtr(rec.FaviconURL), ImageUrl: strPtr(image),
+			Keywords: keywords, CreatedAt: rec.CreatedAt, UpdatedAt: rec.UpdatedAt,
+		})
+	case err == nil:
+		b, err = q.UpdateBookmarkFromSync(ctx, dbgen.UpdateBookmarkFromSyncParams{
+			Title: rec.Title, Description: strPtr(rec.Description), Summary: strPtr(rec.Summary),
+			SourceType: rec.SourceType, FaviconUrl: strPtr(rec.FaviconURL), ImageUrl: strPtr(image),
+			Keywords: keywords, UpdatedAt: rec.UpdatedAt, OwnerID: owner, ID: b.ID,
+		})
+	}
+	if err != nil {
+		return b, false, err
+	}
+
+	tags, err := q.GetBookmarkTags(ctx, dbgen.GetBookmarkTagsParams{OwnerID: owner, BookmarkID: b.ID})
+	if err != nil {
+		return b, false, err
+	}
+	for _, t := range tags {
+		if !slices.Contains(rec.Tags, t.Name) {
+			if err := q.RemoveTagFromBookmark(ctx, dbgen.RemoveTagFromBookmarkParams{OwnerID: owner, BookmarkID: b.ID, TagID: t.ID}); err != nil {
+				return b, false, err
+			}
+		}
+	}
+	for _, name := range rec.Tags {
+		t, err := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: owner, Name: name, Color: strPtr("#6366f1")})
+		if err != nil {
+			return b, false, err
+		}
+		if err := q.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: owner, BookmarkID: b.ID, TagID: t.ID}); err != nil {
+			return b, false, err
+		}
+	}
+
+	collections, err := q.GetBookmarkCollections(ctx, dbgen.GetBookmarkCollectionsParams{OwnerID: owner, BookmarkID: b.ID})
+	if err != nil {
+		return b, false, err
+	}
+	for _, c := range collections {
+		if !slices.Contains(rec.Collections, c.Name) {
+			if err := q.RemoveBookmarkFromCollection(ctx, dbgen.RemoveBookmarkFromCollectionParams{OwnerID: owner, BookmarkID: b.ID, CollectionID: c.ID}); err != nil {
+				return b, false, err
+			}
+		}
+	}
+	for _, name := range rec.Collections {
+		c, err := collectionByName(ctx, q, syncCollection{Name: name})
+		if err != nil {
+			return b, false, err
+		}
+		if err := q.AddBookmarkToCollection(ctx, dbgen.AddBookmarkToCollectionParams{OwnerID: owner, BookmarkID: b.ID, CollectionID: c.ID}); err != nil {
+			return b, false, err
+		}
+	}
+	return b, created, nil
+}
+
+// collectionByName returns the user's collection called c.Name, creating
+// it from c if there is none.
+func collectionByName(ctx context.Context, q *dbgen.Queries, c syncCollection) (dbgen.Collection, error) {
+	col, err := q.GetCollectionByName(ctx, dbgen.GetCollectionByNameParams{OwnerID: ownerID(ctx), Name: c.Name})
+	if errors.Is(err, sql.ErrNoRows) {
+		return q.CreateCollection(ctx, dbgen.CreateCollectionParams{
+			OwnerID: ownerID(ctx), Name: c.Name, Description: strPtr(c.Description), Icon: strPtr(c.Icon),
+		})
+	}
+	return col, err
+}
+
+// importSyncTags creates the tags listed in data that don't exist yet.
+// Tags are removed through their bookmarks, not by editing tags.json.
+func importSyncTags(ctx context.Context, q *dbgen.Queries, data []byte) error {
+	var tags []syncTag
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return fmt.Errorf("read %s: %w", syncTagsFile, err)
+	}
+	for _, t := range tags {
+		if t.Color == "" {
+			t.Color = "#6366f1"
+		}
+		if _, err := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: t.Name, Color: &t.Color}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importSyncCollections applies the difference between two versions of
+// collections.json: new collections are created, edited ones updated and
+// removed ones deleted.
+func importSyncCollections(ctx context.Context, q *dbgen.Queries, oldData, newData []byte) error {
+	var before, after []syncCollection
+	if oldData != nil {
+		json.Unmarshal(oldData, &before)
+	}
+	if err := json.Unmarshal(newData, &after); err != nil && newData != nil {
+		return fmt.Errorf("read %s: %w", syncCollectionsFile, err)
+	}
+	owner := ownerID(ctx)
+	for _, c := range after {
+		col, err := collectionByName(ctx, q, c)
+		if err != nil {
+			return err
+		}
+		if deref(col.Description) != c.Description || deref(col.Icon) != c.Icon {
+			if _, err := q.UpdateCollection(ctx, dbgen.UpdateCollectionParams{
+				Name: c.Name, Description: strPtr(c.Description), Icon: strPtr(c.Icon), OwnerID: owner, ID: col.ID,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range before {
+		if slices.ContainsFunc(after, func(a syncCollection) bool { return a.Name == c.Name }) {
+			continue
+		}
+		col, err := q.GetCollectionByName(ctx, dbgen.GetCollectionByNameParams{OwnerID: owner, Name: c.Name})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := q.DeleteCollection(ctx, dbgen.DeleteCollectionParams{OwnerID: owner, ID: col.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestGitDataSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	if out, err := exec.Command("git", "init", "--bare", "--initial-branch=main", remote).CombinedOutput(); err != nil {
+		t.Fatalf("init bare repo: %v: %s", err, out)
+	}
+	config := GitHubConfig{Repo: remote, Branch: "main"}
+	ctx := withOwner(context.Background(), "alice")
+
+	newServer := func(name string) (*Server, *dbgen.Queries) {
+		os.Mkdir(filepath.Join(dir, name), 0o755)
+		s, err := New(filepath.Join(dir, name, "db.sqlite3"), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, dbgen.New(s.DB)
+	}
+	sync := func(s *Server, push bool) *SyncResult {
+		t.Helper()
+		res, err := s.syncLibrary(ctx, config, push)
+		if err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		return res
+	}
+
+	a, qa := newServer("a")
+	b1, _ := qa.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: "alice", Url: "https://go.dev/", Title: "Go", SourceType: "web"})
+	qa.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: "alice", Url: "https://sqlite.org/", Title: "SQLite", SourceType: "web"})
+	tag, _ := qa.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: "alice", Name: "lang", Color: strPtr("#ff0000")})
+	qa.AddTagToBookmark(ctx, dbgen.AddTagToBookmarkParams{OwnerID: "alice", BookmarkID: b1.ID, TagID: tag.ID})
+	col, _ := qa.CreateCollection(ctx, dbgen.CreateCollectionParams{OwnerID: "alice", Name: "Reading List"})
+	qa.AddBookmarkToCollection(ctx, dbgen.AddBookmarkToCollectionParams{OwnerID: "alice", BookmarkID: b1.ID, CollectionID: col.ID})
+
+	res := sync(a, true)
+	if !res.Pushed || !strings.HasPrefix(res.Commit, "Add 2 bookmarks") || !strings.Contains(res.Commit, "+ Go <https://go.dev/>") {
+		t.Errorf("unexpected first push %+v", res)
+	}
+	files, _ := filepath.Glob(filepath.Join(a.SyncDir, "alice", "bookmarks", "reading-list", "*.json"))
+	if len(files) != 1 {
+		t.Errorf("expected Go under its collection directory, got %v", files)
+	}
+	if res := sync(a, true); res.Commit != "" {
+		t.Errorf("unchanged library should not commit, got %q", res.Commit)
+	}
+
+	b, qb := newServer("b")
+	if res := sync(b, false); res.Created != 2 {
+		t.Fatalf("expected 2 imported bookmarks, got %+v", res)
+	}
+	got, err := qb.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: "alice", Url: "https://go.dev/"})
+	if err != nil || !got.CreatedAt.Equal(b1.CreatedAt) {
+		t.Fatalf("bookmark not imported faithfully: %+v, %v", got, err)
+	}
+	if tags, _ := qb.GetBookmarkTags(ctx, dbgen.GetBookmarkTagsParams{OwnerID: "alice", BookmarkID: got.ID}); len(tags) != 1 || tags[0].Name != "lang" {
+		t.Errorf("tags not imported: %v", tags)
+	}
+
+	// Edit on b, delete on a; both sync and end up with the same library.
+	qb.UpdateBookmark(ctx, dbgen.UpdateBookmarkParams{OwnerID: "alice", ID: got.ID, Title: "The Go Programming Language"})
+	sync(b, true)
+	sqlite, _ := qa.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: "alice", Url: "https://sqlite.org/"})
+	qa.DeleteBookmark(ctx, dbgen.DeleteBookmarkParams{OwnerID: "alice", ID: sqlite.ID})
+	if res := sync(a, true); res.Updated != 1 || !strings.HasPrefix(res.Commit, "Remove 1 bookmark") {
+		t.Errorf("unexpected sync on a %+v", res)
+	}
+	if b, _ := qa.GetBookmark(ctx, dbgen.GetBookmarkParams{OwnerID: "alice", ID: b1.ID}); b.Title != "The Go Programming Language" {
+		t.Errorf("remote edit not imported: %q", b.Title)
+	}
+	if res := sync(b, false); res.Deleted != 1 {
+		t.Errorf("remote delete not imported: %+v", res)
+	}
+	if n, _ := qb.CountBookmarks(ctx, "alice"); n != 1 {
+		t.Errorf("expected 1 bookmark on b, got %d", n)
+	}
+
+	// The sync repository only ever holds library files.
+	entries, _ := os.ReadDir(filepath.Join(b.SyncDir, "alice"))
+	for _, e := range entries {
+		if name := e.Name(); name != ".git" && name != syncBookmarksDir && name != syncTagsFile && name != syncCollectionsFile {
+			t.Errorf("unexpected file %s in sync repository", name)
+		}
+	}
+}
+
+func TestGitSyncMerge(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	if out, err := exec.Command("git", "init", "--bare", "--initial-branch=main", remote).CombinedOutput(); err != nil {
+		t.Fatalf("init bare repo: %v: %s", err, out)
+	}
+	config := GitHubConfig{Repo: remote, Branch: "main"}
+	ctx := withOwner(context.Background(), "alice")
+	servers := map[string]*Server{}
+	for _, name := range []string{"a", "b"} {
+		os.Mkdir(filepath.Join(dir, name), 0o755)
+		s, err := New(filepath.Join(dir, name, "db.sqlite3"), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers[name] = s
+	}
+	a, b := servers["a"], servers["b"]
+	qa, qb := dbgen.New(a.DB), dbgen.New(b.DB)
+	sync := func(s *Server) *SyncResult {
+		t.Helper()
+		res, err := s.syncLibrary(ctx, config, true)
+		if err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		return res
+	}
+	bookmark := func(q *dbgen.Queries) dbgen.Bookmark {
+		t.Helper()
+		bm, err := q.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: "alice", Url: "https://go.dev/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bm
+	}
+
+	qa.CreateBookmark(ctx, dbgen.CreateBookmarkParams{OwnerID: "alice", Url: "https://go.dev/", Title: "Go", SourceType: "web"})
+	sync(a)
+	sync(b)
+
+	// Different fields of the same bookmark merge cleanly.
+	qa.UpdateBookmark(ctx, dbgen.UpdateBookmarkParams{OwnerID: "alice", ID: bookmark(qa).ID, Title: "Go (a)"})
+	sync(a)
+	qb.UpdateBookmark(ctx, dbgen.UpdateBookmarkParams{OwnerID: "alice", ID: bookmark(qb).ID, Title: "Go", Description: strPtr("Docs")})
+	if res := sync(b); res.Conflicts != 0 || res.Updated != 1 {
+		t.Fatalf("expected a clean merge, got %+v", res)
+	}
+	if got := bookmark(qb); got.Title != "Go (a)" || deref(got.Description) != "Docs" {
+		t.Errorf("fields not merged: %q, %q", got.Title, deref(got.Description))
+	}
+	sync(a)
+	if got := bookmark(qa); got.Title != "Go (a)" || deref(got.Description) != "Docs" {
+		t.Errorf("merge not pushed: %q, %q", got.Title, deref(got.Description))
+	}
+
+	// Both sides renaming it: the later edit wins.
+	edit := func(s *Server, q *dbgen.Queries, title string, at time.Time) {
+		t.Helper()
+		q.UpdateBookmark(ctx, dbgen.UpdateBookmarkParams{OwnerID: "alice", ID: bookmark(q).ID, Title: title, Description: strPtr("Docs")})
+		if _, err := s.DB.Exec("UPDATE bookmarks SET updated_at = ? WHERE url = 'https://go.dev/'", at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	edit(a, qa, "Newer title from a", now.Add(time.Minute))
+	sync(a)
+	edit(b, qb, "Older title from b", now)
+	if res := sync(b); res.Conflicts != 0 || bookmark(qb).Title != "Newer title from a" {
+		t.Fatalf("newer edit should win, got %+v with title %q", res, bookmark(qb).Title)
+	}
+	sync(a)
+
+	// With the same updated_at it is a conflict; the local title stays
+	// until the conflict is resolved.
+	edit(a, qa, "Title from a", now.Add(2*time.Minute))
+	sync(a)
+	edit(b, qb, "Title from b", now.Add(2*time.Minute))
+	if res := sync(b); res.Conflicts != 1 {
+		t.Fatalf("expected 1 conflict, got %+v", res)
+	}
+	if got := bookmark(qb); got.Title != "Title from b" {
+		t.Errorf("conflict should keep the local title, got %q", got.Title)
+	}
+	repo := gitRepo{dir: filepath.Join(b.SyncDir, "alice")}
+	if status, _ := repo.git(ctx, "status", "--porcelain"); status != "" {
+		t.Errorf("merge left a dirty working tree:\n%s", status)
+	}
+	if _, err := os.Stat(filepath.Join(repo.dir, ".git", "MERGE_HEAD")); err == nil {
+		t.Error("merge left MERGE_HEAD behind")
+	}
+
+	w := httptest.NewRecorder()
+	b.HandleListSyncConflicts(w, httptest.NewRequest("GET", "/api/sync/conflicts", nil).WithContext(ctx))
+	var conflicts []SyncConflict
+	json.NewDecoder(w.Body).Decode(&conflicts)
+	if len(conflicts) != 1 || !slices.Equal(conflicts[0].Fields, []string{"title"}) || conflicts[0].Theirs.Title != "Title from a" {
+		t.Fatalf("unexpected conflicts %s", w.Body.String())
+	}
+
+	r := httptest.NewRequest("POST", "/api/sync/conflicts/{id}/resolve", strings.NewReader(`{"choice":"theirs"}`)).WithContext(ctx)
+	r.SetPathValue("id", strconv.FormatInt(conflicts[0].ID, 10))
+	w = httptest.NewRecorder()
+	b.HandleResolveSyncConflict(w, r)
+	if w.Code != 200 {
+		t.Fatalf("resolve: %d %s", w.Code, w.Body.String())
+	}
+	if got := bookmark(qb); got.Title != "Title from a" {
+		t.Errorf("resolution not applied, title %q", got.Title)
+	}
+	if rows, _ := qb.ListSyncConflicts(ctx, "alice"); len(rows) != 0 {
+		t.Errorf("conflict not cleared: %v", rows)
+	}
+	sync(b)
+	if res := sync(a); res.Conflicts != 0 || bookmark(qa).Title != "Title from a" {
+		t.Errorf("resolution did not sync back: %+v", res)
+	}
+}
+
+func TestGitSyncOwners(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	if out, err := exec.Command("git", "init", "--bare", "--initial-branch=main", remote).CombinedOutput(); err != nil {
+		t.Fatalf("init bare repo: %v: %s", err, out)
+	}
+	config := GitHubConfig{Repo: remote, Branch: "main"}
+	server, err := New(filepath.Join(dir, "db.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := dbgen.New(server.DB)
+	alice, bob := withOwner(context.Background(), "alice"), withOwner(context.Background(), "bob@example.com")
+	q.CreateBookmark(alice, dbgen.CreateBookmarkParams{OwnerID: "alice", Url: "https://go.dev/", Title: "Go", SourceType: "web"})
+	q.CreateBookmark(bob, dbgen.CreateBookmarkParams{OwnerID: "bob@example.com", Url: "https://sqlite.org/", Title: "SQLite", SourceType: "web"})
+
+	res, err := server.syncLibrary(alice, config, true)
+	if err != nil || !res.Pushed || res.Branch != "main-alice" {
+		t.Fatalf("alice's sync: %+v, %v", res, err)
+	}
+	// Bob's first sync must not start from, or import, alice's library.
+	res, err = server.syncLibrary(bob, config, true)
+	if err != nil || !res.Pushed || res.Created != 0 || res.Deleted != 0 {
+		t.Fatalf("bob's sync: %+v, %v", res, err)
+	}
+	if !strings.HasPrefix(res.Branch, "main-bob_example_com_") {
+		t.Errorf("bob's branch = %q", res.Branch)
+	}
+	for owner, want := range map[string]string{"alice": "https://go.dev/", "bob@example.com": "https://sqlite.org/"} {
+		ctx := context.Background()
+		n, _ := q.CountBookmarks(ctx, owner)
+		if _, err := q.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: owner, Url: want}); err != nil || n != 1 {
+			t.Errorf("%s has %d bookmarks, %s: %v", owner, n, want, err)
+		}
+	}
+	// A second sync for the same user is refused while one is running.
+	lock, _ := server.syncLocks.LoadOrStore("alice", &gosync.Mutex{})
+	lock.(*gosync.Mutex).Lock()
+	if _, err := server.syncLibrary(alice, config, false); !errors.Is(err, errSyncRunning) {
+		t.Errorf("concurrent sync: %v", err)
+	}
+	if _, err := server.syncLibrary(bob, config, false); err != nil {
+		t.Errorf("another user's sync was blocked: %v", err)
+	}
+	lock.(*gosync.Mutex).Unlock()
+
+	out, _ := exec.Command("git", "-C", remote, "branch", "--format=%(refname:short)").Output()
+	if branches := strings.Fields(string(out)); len(branches) != 2 || branches[0] != "main-alice" {
+		t.Errorf("remote branches = %q", branches)
+	}
+}
@@ -0,0 +1,535 @@
+package srv
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// syncRecord is a library record decoded field by field, so that a merge
+// can compare individual fields.
+type syncRecord = map[string]any
+
+// syncLibraryDoc is the library at one commit: bookmarks keyed by URL,
+// tags and collections keyed by name.
+type syncLibraryDoc struct {
+	Bookmarks   map[string]syncRecord
+	Tags        map[string]syncRecord
+	Collections map[string]syncRecord
+}
+
+// syncSetFields merge as sets: an item added or removed on either side is
+// added or removed in the result, so they never conflict.
+var syncSetFields = []string{"tags", "collections", "keywords"}
+
+// syncConflict is a bookmark that both sides changed incompatibly.
+type syncConflict struct {
+	URL                string
+	Fields             []string
+	Base, Ours, Theirs syncRecord
+}
+
+// mergeLibraries merges commit theirs into ours record by record, with base
+// as the common ancestor ("" if there is none). It writes and commits the
+// result; on error the working tree is reset to ours, so nothing is left
+// half-merged. Conflicting bookmarks keep the local version (or the
+// surviving one, if the other side deleted it) and are returned.
+func (s *Server) mergeLibraries(ctx context.Context, repo gitRepo, base, ours, theirs string) (conflicts []syncConflict, err error) {
+	defer func() {
+		if err != nil {
+			repo.git(ctx, "reset", "--hard", "--quiet", ours)
+		}
+	}()
+	docs := make([]syncLibraryDoc, 3)
+	for i, rev := range []string{base, ours, theirs} {
+		if docs[i], err = readLibraryDoc(ctx, repo, rev); err != nil {
+			return nil, err
+		}
+	}
+	b, o, t := docs[0], docs[1], docs[2]
+
+	merged := syncLibraryDoc{
+		Bookmarks:   map[string]syncRecord{},
+		Tags:        mergeRecordSets(b.Tags, o.Tags, t.Tags, nil),
+		Collections: mergeRecordSets(b.Collections, o.Collections, t.Collections, nil),
+	}
+	merged.Bookmarks = mergeRecordSets(b.Bookmarks, o.Bookmarks, t.Bookmarks, func(c syncConflict) {
+		conflicts = append(conflicts, c)
+	})
+
+	files, err := merged.files()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeSyncFiles(repo.dir, files); err != nil {
+		return nil, err
+	}
+	if _, err := repo.git(ctx, "add", "-A"); err != nil {
+		return nil, err
+	}
+	tree, err := repo.git(ctx, "write-tree")
+	if err != nil {
+		return nil, err
+	}
+	msg := "Merge remote bookmarks"
+	if len(conflicts) > 0 {
+		msg += fmt.Sprintf("\n\n%d conflicting bookmarks kept their local version:", len(conflicts))
+		for _, c := range conflicts {
+			msg += "\n! " + c.URL + " (" + strings.Join(c.Fields, ", ") + ")"
+		}
+	}
+	commit, err := repo.git(ctx, "commit-tree", strings.TrimSpace(tree), "-p", ours, "-p", theirs, "-m", msg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.git(ctx, "update-ref", "HEAD", strings.TrimSpace(commit)); err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// mergeRecordSets three-way merges keyed records. Records added or deleted
+// on one side are added or deleted; a record deleted on one side but edited
+// on the other is a conflict, as is a field both sides set differently.
+// A nil onConflict resolves conflicts silently in favour of ours.
+func mergeRecordSets(base, ours, theirs map[string]syncRecord, onConflict func(syncConflict)) map[string]syncRecord {
+	merged := map[string]syncRecord{}
+	keys := map[string]bool{}
+	for _, m := range []map[string]syncRecord{base, ours, theirs} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	for key := range keys {
+		b, o, t := base[key], ours[key], theirs[key]
+		var rec syncRecord
+		var fields []string
+		switch {
+		case o == nil && t == nil:
+		case b == nil && o == nil:
+			rec = t
+		case b == nil && t == nil:
+			rec = o
+		case o == nil:
+			if !reflect.DeepEqual(b, t) {
+				rec, fields = t, []string{"deleted"}
+			}
+		case t == nil:
+			if !reflect.DeepEqual(b, o) {
+				rec, fields = o, []string{"deleted"}
+			}
+		default:
+			rec, fields = mergeRecord(b, o, t)
+		}
+		if rec != nil {
+			merged[key] = rec
+		}
+		if len(fields) > 0 && onConflict != nil {
+			onConflict(syncConflict{URL: key, Fields: fields, Base: b, Ours: o, Theirs: t})
+		}
+	}
+	return merged
+}
+
+// mergeRecord merges one record field by field. Set fields are merged as
+// sets, created_at keeps the earlier time and updated_at the later one.
+// A field both sides changed differently takes the value from the side
+// with the later updated_at; when the times tie or are missing it is
+// returned as a conflict, with ours kept.
+func mergeRecord(base, ours, theirs syncRecord) (syncRecord, []string) {
+	merged := syncRecord{}
+	var conflicts []string
+	ourTime, ourErr := recordTime(ours, "updated_at")
+	theirTime, theirErr := recordTime(theirs, "updated_at")
+	newer := 0
+	if ourErr == nil && theirErr == nil && !ourTime.IsZero() && !theirTime.IsZero() {
+		newer = theirTime.Compare(ourTime)
+	}
+	keys := map[string]bool{}
+	for _, m := range []syncRecord{base, ours, theirs} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	for k := range keys {
+		b, o, t := base[k], ours[k], theirs[k]
+		var v any
+		switch {
+		case reflect.DeepEqual(o, t), reflect.DeepEqual(b, t):
+			v = o
+		case reflect.DeepEqual(b, o):
+			v = t
+		case slices.Contains(syncSetFields, k):
+			v = mergeSet(b, o, t)
+		case k == "created_at" || k == "updated_at":
+			ot, _ := recordTime(ours, k)
+			tt, _ := recordTime(theirs, k)
+			v = o
+			if k == "created_at" && tt.Before(ot) || k == "updated_at" && tt.After(ot) {
+				v = t
+			}
+		case newer > 0:
+			v = t
+		case newer < 0:
+			v = o
+		default:
+			v = o
+			conflicts = append(conflicts, k)
+		}
+		if v != nil {
+			merged[k] = v
+		}
+	}
+	slices.Sort(conflicts)
+	return merged, conflicts
+}
+
+// recordTime parses one of a record's timestamps.
+func recordTime(rec syncRecord, field string) (time.Time, error) {
+	s, ok := rec[field].(string)
+	if !ok || s == "" {
+		return time.Time{}, fmt.Errorf("no %s", field)
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// mergeSet merges JSON string arrays: base's items that both sides kept,
+// plus anything either side added. The result is sorted.
+func mergeSet(base, ours, theirs any) any {
+	items := func(v any) []string {
+		var out []string
+		list, _ := v.([]any)
+		for _, item := range list {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	}
+	b, o, t := items(base), items(ours), items(theirs)
+	var merged []any
+	for _, item := range slices.Concat(o, t) {
+		kept := !slices.Contains(b, item) || (slices.Contains(o, item) && slices.Contains(t, item))
+		if kept && !slices.Contains(merged, any(item)) {
+			merged = append(merged, item)
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	slices.SortFunc(merged, func(a, b any) int { return cmp.Compare(a.(string), b.(string)) })
+	return merged
+}
+
+// readLibraryDoc reads every library file at rev. An empty rev gives an
+// empty library.
+func readLibraryDoc(ctx context.Context, repo gitRepo, rev string) (syncLibraryDoc, error) {
+	doc := syncLibraryDoc{Bookmarks: map[string]syncRecord{}, Tags: map[string]syncRecord{}, Collections: map[string]syncRecord{}}
+	if rev == "" {
+		return doc, nil
+	}
+	blobs, err := repo.readTree(ctx, rev)
+	if err != nil {
+		return doc, err
+	}
+	for path, data := range blobs {
+		switch {
+		case path == syncTagsFile, path == syncCollectionsFile:
+			var list []syncRecord
+			if err := json.Unmarshal(data, &list); err != nil {
+				return doc, fmt.Errorf("read %s at %.8s: %w", path, rev, err)
+			}
+			into := doc.Tags
+			if path == syncCollectionsFile {
+				into = doc.Collections
+			}
+			for _, rec := range list {
+				into[fmt.Sprint(rec["name"])] = rec
+			}
+		case strings.HasPrefix(path, syncBookmarksDir+"/"):
+			var rec syncRecord
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return doc, fmt.Errorf("read %s at %.8s: %w", path, rev, err)
+			}
+			doc.Bookmarks[fmt.Sprint(rec["url"])] = rec
+		}
+	}
+	return doc, nil
+}
+
+// files renders doc in the same layout as libraryFiles.
+func (doc syncLibraryDoc) files() (map[string][]byte, error) {
+	var tags []syncTag
+	if err := convertRecords(doc.Tags, &tags); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(tags, func(a, b syncTag) int { return cmp.Compare(a.Name, b.Name) })
+	var collections []syncCollection
+	if err := convertRecords(doc.Collections, &collections); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(collections, func(a, b syncCollection) int { return cmp.Compare(a.Name, b.Name) })
+
+	files := map[string][]byte{}
+	var err error
+	if files[syncTagsFile], err = marshalSyncFile(tags); err != nil {
+		return nil, err
+	}
+	if files[syncCollectionsFile], err = marshalSyncFile(collections); err != nil {
+		return nil, err
+	}
+	for _, rec := range doc.Bookmarks {
+		b, err := syncBookmarkFromRecord(rec)
+		if err != nil {
+			return nil, err
+		}
+		if files[syncBookmarkPath(b)], err = marshalSyncFile(b); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func convertRecords(m map[string]syncRecord, out any) error {
+	list := make([]syncRecord, 0, len(m))
+	for _, rec := range m {
+		list = append(list, rec)
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func syncBookmarkFromRecord(rec syncRecord) (syncBookmark, error) {
+	var b syncBookmark
+	data, err := json.Marshal(rec)
+	if err == nil {
+		err = json.Unmarshal(data, &b)
+	}
+	return b, err
+}
+
+// readTree returns the contents of every file at rev.
+func (g gitRepo) readTree(ctx context.Context, rev string) (map[string][]byte, error) {
+	list, err := g.git(ctx, "ls-tree", "-r", "-z", rev)
+	if err != nil {
+		return nil, err
+	}
+	var paths, objects []string
+	for _, entry := range strings.Split(strings.TrimRight(list, "\x00"), "\x00") {
+		meta, path, ok := strings.Cut(entry, "\t")
+		if f := strings.Fields(meta); ok && len(f) == 3 && f[1] == "blob" {
+			paths = append(paths, path)
+			objects = append(objects, f[2])
+		}
+	}
+	files := make(map[string][]byte, len(paths))
+	if len(paths) == 0 {
+		return files, nil
+	}
+
+	cmd := g.command(ctx, "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(strings.Join(objects, "\n") + "\n")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(stdout)
+	for _, path := range paths {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			cmd.Wait()
+			return nil, fmt.Errorf("git cat-file: %w", err)
+		}
+		f := strings.Fields(header)
+		size, _ := strconv.Atoi(f[len(f)-1])
+		data := make([]byte, size+1) // content and trailing newline
+		if _, err := io.ReadFull(r, data); err != nil {
+			cmd.Wait()
+			return nil, fmt.Errorf("git cat-file: %w", err)
+		}
+		files[path] = data[:size]
+	}
+	return files, cmd.Wait()
+}
+
+// recordConflicts stores conflicts for the current user, replacing any
+// older conflict on the same bookmark.
+func (s *Server) recordConflicts(ctx context.Context, conflicts []syncConflict) error {
+	q := dbgen.New(s.DB)
+	for _, c := range conflicts {
+		fields, _ := json.Marshal(c.Fields)
+		if err := q.UpsertSyncConflict(ctx, dbgen.UpsertSyncConflictParams{
+			OwnerID: ownerID(ctx),
+			Url:     c.URL,
+			Fields:  string(fields),
+			Base:    recordJSON(c.Base),
+			Ours:    recordJSON(c.Ours),
+			Theirs:  recordJSON(c.Theirs),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recordJSON(rec syncRecord) *string {
+	if rec == nil {
+		return nil
+	}
+	data, _ := json.Marshal(rec)
+	return strPtr(string(data))
+}
+
+// SyncConflict is an unresolved conflict as returned by the API. Base, Ours
+// and Theirs are nil where that side had no such bookmark.
+type SyncConflict struct {
+	ID        int64         `json:"id"`
+	URL       string        `json:"url"`
+	Fields    []string      `json:"fields"`
+	Base      *syncBookmark `json:"base"`
+	Ours      *syncBookmark `json:"ours"`
+	Theirs    *syncBookmark `json:"theirs"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func apiSyncConflict(c dbgen.SyncConflict) SyncConflict {
+	out := SyncConflict{ID: c.ID, URL: c.Url, CreatedAt: c.CreatedAt}
+	json.Unmarshal([]byte(c.Fields), &out.Fields)
+	for _, side := range []struct {
+		from *string
+		to   **syncBookmark
+	}{{c.Base, &out.Base}, {c.Ours, &out.Ours}, {c.Theirs, &out.Theirs}} {
+		if side.from != nil {
+			var b syncBookmark
+			if json.Unmarshal([]byte(*side.from), &b) == nil {
+				*side.to = &b
+			}
+		}
+	}
+	return out
+}
+
+// HandleListSyncConflicts returns the current user's unresolved conflicts.
+func (s *Server) HandleListSyncConflicts(w http.ResponseWriter, r *http.Request) {
+	rows, err := dbgen.New(s.DB).ListSyncConflicts(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	conflicts := make([]SyncConflict, len(rows))
+	for i, c := range rows {
+		conflicts[i] = apiSyncConflict(c)
+	}
+	writeJSON(w, conflicts)
+}
+
+// HandleResolveSyncConflict settles a conflict with {"choice": "ours"},
+// {"choice": "theirs"} or {"choice": "custom", "bookmark": {...}}. The chosen
+// version is written to the database with a fresh updated_at and goes out
+// with the next push; choosing a missing side deletes the bookmark.
+func (s *Server) HandleResolveSyncConflict(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	var req struct {
+		Choice   string        `json:"choice"`
+		Bookmark *syncBookmark `json:"bookmark"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid JSON", 400)
+		return
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	defer tx.Rollback()
+	q := dbgen.New(tx)
+	row, err := q.GetSyncConflict(ctx, dbgen.GetSyncConflictParams{OwnerID: ownerID(ctx), ID: id})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, "not found", 404)
+		return
+	} else if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	conflict := apiSyncConflict(row)
+
+	var chosen *syncBookmark
+	switch req.Choice {
+	case "ours":
+		chosen = conflict.Ours
+	case "theirs":
+		chosen = conflict.Theirs
+	case "custom":
+		if req.Bookmark == nil {
+			writeError(w, "bookmark required for a custom resolution", 400)
+			return
+		}
+		chosen = req.Bookmark
+		chosen.URL = conflict.URL
+	default:
+		writeError(w, "choice must be ours, theirs or custom", 400)
+		return
+	}
+
+	var event Event
+	if chosen == nil {
+		b, err := q.GetBookmarkByURL(ctx, dbgen.GetBookmarkByURLParams{OwnerID: ownerID(ctx), Url: conflict.URL})
+		if err == nil {
+			err = q.DeleteBookmark(ctx, dbgen.DeleteBookmarkParams{OwnerID: ownerID(ctx), ID: b.ID})
+			event = Event{Type: EventBookmarkDeleted, Data: map[string]int64{"id": b.ID}}
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			writeError(w, err.Error(), 500)
+			return
+		}
+	} else {
+		chosen.UpdatedAt = time.Now().UTC()
+		if chosen.CreatedAt.IsZero() {
+			chosen.CreatedAt = chosen.UpdatedAt
+		}
+		b, created, err := importSyncBookmark(ctx, q, *chosen)
+		if err != nil {
+			writeError(w, err.Error(), 500)
+			return
+		}
+		event = Event{Type: EventBookmarkUpdated, Data: b}
+		if created {
+			event.Type = EventBookmarkCreated
+		}
+	}
+	if err := q.DeleteSyncConflict(ctx, dbgen.DeleteSyncConflictParams{OwnerID: ownerID(ctx), ID: id}); err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	if event.Type != "" {
+		s.Events.Publish(ctx, event.Type, event.Data)
+	}
+	if b, ok := event.Data.(dbgen.Bookmark); ok && chosen.ImageURL != "" && b.ImageUrl == nil {
+		s.queueMetadata(ctx, []int64{b.ID})
+	}
+	writeJSON(w, map[string]any{"resolved": id, "bookmark": chosen})
+}
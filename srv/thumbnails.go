@@ -0,0 +1,195 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"srv.exe.dev/db/dbgen"
+)
+
+// thumbnailWidths are the widths preview images are stored at. The
+// default is the one served without ?w=.
+var thumbnailWidths = []int{320, 640, 1280}
+
+const (
+	defaultThumbnailWidth = 640
+	maxImageBytes         = 10 << 20
+	maxImagePixels        = 50_000_000 // refuse to decode anything bigger
+)
+
+// thumbnailTypes are the preview image formats that can be decoded.
+var thumbnailTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// previewImage stores the first usable image among candidates in the
+// thumbnail cache and returns its local URL. When none can be used, a
+// screenshot of pageURL is tried if ScreenshotFallback is set; otherwise
+// it returns "". Screenshot URLs stored by older versions are candidates
+// only with the fallback on, since fetching one sends the page's URL to
+// the screenshot service.
+func (s *Server) previewImage(ctx context.Context, pageURL string, candidates []string) string {
+	for _, img := range candidates {
+		if strings.HasPrefix(img, "/thumbnails/") {
+			return img
+		}
+		if strings.HasPrefix(img, screenshotService) && !s.ScreenshotFallback {
+			continue
+		}
+		local, err := s.cacheThumbnail(ctx, img)
+		if err == nil {
+			return local
+		}
+		slog.Debug("thumbnail: failed", "url", img, "error", err)
+	}
+	if !s.ScreenshotFallback || ctx.Err() != nil {
+		return ""
+	}
+	local, err := s.cacheThumbnail(ctx, getScreenshotService(pageURL))
+	if err != nil {
+		slog.Debug("thumbnail: screenshot failed", "url", pageURL, "error", err)
+		return ""
+	}
+	return local
+}
+
+// localImageURL returns img if it is a thumbnail cached here, and ""
+// otherwise. Imports use it so that neither third-party images nor
+// another server's thumbnails are stored; see queueMetadata.
+func localImageURL(ctx context.Context, q *dbgen.Queries, img string) string {
+	hash, ok := strings.CutPrefix(img, "/thumbnails/")
+	if !ok {
+		return ""
+	}
+	if thumbs, err := q.ListThumbnails(ctx, hash); err != nil || len(thumbs) == 0 {
+		return ""
+	}
+	return img
+}
+
+// cacheThumbnail downloads an image and stores it resized to each of
+// thumbnailWidths narrower than the original, or at its own size if it is
+// smaller than all of them. It returns the URL the image is served from.
+func (s *Server) cacheThumbnail(ctx context.Context, imageURL string) (string, error) {
+	if !strings.HasPrefix(imageURL, "http://") && !strings.HasPrefix(imageURL, "https://") {
+		return "", fmt.Errorf("not an http URL: %q", imageURL)
+	}
+	data, contentType, err := s.downloadImage(ctx, imageURL, maxImageBytes)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(thumbnailTypes, contentType) {
+		return "", fmt.Errorf("unsupported image type %s", contentType)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	q := dbgen.New(s.DB)
+	if existing, err := q.ListThumbnails(ctx, hash); err == nil && len(existing) > 0 {
+		return "/thumbnails/" + hash, nil
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if cfg.Width*cfg.Height > maxImagePixels || cfg.Width == 0 || cfg.Height == 0 {
+		return "", fmt.Errorf("image is %dx%d", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	widths := slices.DeleteFunc(slices.Clone(thumbnailWidths), func(w int) bool { return w > cfg.Width })
+	if len(widths) == 0 {
+		widths = []int{cfg.Width}
+	}
+	for _, width := range widths {
+		height := max(1, cfg.Height*width/cfg.Width)
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+		resized, contentType, err := encodeThumbnail(dst)
+		if err != nil {
+			return "", err
+		}
+		blob, err := s.putBlob(resized)
+		if err != nil {
+			return "", err
+		}
+		if err := q.CreateThumbnail(ctx, dbgen.CreateThumbnailParams{
+			Hash:        hash,
+			Width:       int64(width),
+			Height:      int64(height),
+			Blob:        blob,
+			ContentType: contentType,
+			SourceUrl:   &imageURL,
+		}); err != nil {
+			return "", err
+		}
+	}
+	return "/thumbnails/" + hash, nil
+}
+
+// encodeThumbnail writes opaque images as JPEG and keeps transparency as
+// PNG.
+func encodeThumbnail(img *image.RGBA) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if img.Opaque() {
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), "image/png", err
+}
+
+// HandleThumbnail serves a cached preview image. ?w= picks the smallest
+// stored width at least that wide, defaulting to defaultThumbnailWidth.
+func (s *Server) HandleThumbnail(w http.ResponseWriter, r *http.Request) {
+	hash := r.PathValue("hash")
+	if !validBlobHash(hash) {
+		http.NotFound(w, r)
+		return
+	}
+	thumbs, err := dbgen.New(s.DB).ListThumbnails(r.Context(), hash)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if len(thumbs) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	want, err := strconv.Atoi(r.URL.Query().Get("w"))
+	if err != nil || want <= 0 {
+		want = defaultThumbnailWidth
+	}
+	thumb := thumbs[len(thumbs)-1]
+	if i := slices.IndexFunc(thumbs, func(t dbgen.Thumbnail) bool { return int(t.Width) >= want }); i >= 0 {
+		thumb = thumbs[i]
+	}
+
+	f, err := s.openBlob(thumb.Blob)
+	if err != nil {
+		slog.Warn("thumbnail: missing blob", "hash", hash, "blob", thumb.Blob, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", thumb.ContentType)
+	// A hash and width always name the same image.
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+thumb.Blob+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", thumb.CreatedAt, f)
+}
@@ -0,0 +1,174 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestThumbnails(t *testing.T) {
+	var screenshots atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wide.png", func(w http.ResponseWriter, r *http.Request) {
+		png.Encode(w, image.NewGray(image.Rect(0, 0, 800, 400)))
+	})
+	mux.HandleFunc("/alpha.png", func(w http.ResponseWriter, r *http.Request) {
+		img := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+		draw.Draw(img, image.Rect(0, 0, 50, 50), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+		png.Encode(w, img)
+	})
+	mux.HandleFunc("/error.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("<html><body>Oops</body></html>"))
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Article</title><meta property="og:image" content="/gone.png">
+			<meta name="twitter:image" content="/wide.png"></head></html>`))
+	})
+	mux.HandleFunc("/bare/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>No images</title></head></html>`))
+	})
+	mux.HandleFunc("/shot/", func(w http.ResponseWriter, r *http.Request) {
+		screenshots.Add(1)
+		png.Encode(w, image.NewGray(image.Rect(0, 0, 600, 400)))
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+	defer func(service string) { screenshotService = service }(screenshotService)
+	screenshotService = site.URL + "/shot/"
+
+	server, err := New(filepath.Join(t.TempDir(), "thumbnails.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	q := dbgen.New(server.DB)
+
+	wide, err := server.cacheThumbnail(ctx, site.URL+"/wide.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbs, _ := q.ListThumbnails(ctx, strings.TrimPrefix(wide, "/thumbnails/"))
+	if len(thumbs) != 2 || thumbs[0].Width != 320 || thumbs[0].Height != 160 || thumbs[1].Width != 640 || thumbs[1].ContentType != "image/jpeg" {
+		t.Errorf("wide image stored as %+v", thumbs)
+	}
+	alpha, err := server.cacheThumbnail(ctx, site.URL+"/alpha.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbs, _ = q.ListThumbnails(ctx, strings.TrimPrefix(alpha, "/thumbnails/"))
+	if len(thumbs) != 1 || thumbs[0].Width != 100 || thumbs[0].ContentType != "image/png" {
+		t.Errorf("small transparent image stored as %+v", thumbs)
+	}
+	if _, err := server.cacheThumbnail(ctx, site.URL+"/error.png"); err == nil {
+		t.Error("an HTML error page was cached as an image")
+	}
+
+	handler := server.Handler()
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-ExeDev-UserID", "alice")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	for query, width := range map[string]int{"?w=100": 320, "": 640, "?w=2000": 640} {
+		w := do("GET", wide+query, "")
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+		if w.Code != 200 || err != nil || format != "jpeg" || cfg.Width != width {
+			t.Errorf("GET %s%s: %d, %s %dpx, %v", wide, query, w.Code, format, cfg.Width, err)
+		}
+		if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+			t.Errorf("Cache-Control = %q", cc)
+		}
+	}
+	if w := do("GET", wide, "", "If-None-Match", do("GET", wide, "").Header().Get("ETag")); w.Code != 304 {
+		t.Errorf("conditional request: %d", w.Code)
+	}
+	if w := do("GET", "/thumbnails/"+strings.Repeat("0", 64), ""); w.Code != 404 {
+		t.Errorf("unknown thumbnail: %d", w.Code)
+	}
+
+	// Images are cached by the generate_metadata job, not while creating.
+	create := func(path string) string {
+		w := do("POST", "/api/bookmarks", `{"url": "`+site.URL+path+`"}`)
+		if w.Code != 201 {
+			t.Fatalf("create %s: %d %s", path, w.Code, w.Body)
+		}
+		var b struct {
+			ID       int64  `json:"id"`
+			ImageURL string `json:"image_url"`
+		}
+		json.NewDecoder(w.Body).Decode(&b)
+		if b.ImageURL != "" {
+			t.Errorf("create %s: image_url %q before the job ran", path, b.ImageURL)
+		}
+		runQueuedJobs(t, server.Jobs)
+		stored, _ := q.GetBookmark(withOwner(ctx, "alice"), dbgen.GetBookmarkParams{OwnerID: "alice", ID: b.ID})
+		return deref(stored.ImageUrl)
+	}
+	// The first image that downloads is used.
+	if img := create("/article"); img != wide {
+		t.Errorf("article image_url = %q, want %q", img, wide)
+	}
+	if img := create("/bare/1"); !strings.HasPrefix(img, "/thumbnails/") || screenshots.Load() != 1 {
+		t.Errorf("screenshot fallback: image_url %q after %d screenshots", img, screenshots.Load())
+	}
+	server.ScreenshotFallback = false
+	if img := create("/bare/2"); img != "" || screenshots.Load() != 1 {
+		t.Errorf("fallback disabled: image_url %q after %d screenshots", img, screenshots.Load())
+	}
+
+	// Screenshot and third-party URLs stored by older versions are neither
+	// fetched without the fallback nor kept when they can't be cached.
+	alice := withOwner(ctx, "alice")
+	for _, img := range []string{getScreenshotService(site.URL + "/bare/3"), site.URL + "/gone.png"} {
+		b, err := q.CreateBookmark(alice, dbgen.CreateBookmarkParams{OwnerID: "alice", Url: site.URL + "/bare/3", Title: "Legacy", SourceType: "web", ImageUrl: &img})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.generateBookmarkMetadata(alice, b.ID); err != nil {
+			t.Fatal(err)
+		}
+		b, _ = q.GetBookmark(alice, dbgen.GetBookmarkParams{OwnerID: "alice", ID: b.ID})
+		if b.ImageUrl != nil || screenshots.Load() != 1 {
+			t.Errorf("legacy %s: image_url %v after %d screenshots", img, deref(b.ImageUrl), screenshots.Load())
+		}
+		q.DeleteBookmark(alice, dbgen.DeleteBookmarkParams{OwnerID: "alice", ID: b.ID})
+	}
+
+	// Imports keep only thumbnails cached here and queue a fetch for the
+	// rest.
+	backup := &Backup{Bookmarks: []dbgen.Bookmark{
+		{ID: 1, Url: "https://cdn.example/a", Title: "A", SourceType: "web", ImageUrl: strPtr("https://cdn.example/a.jpg")},
+		{ID: 2, Url: "https://cdn.example/b", Title: "B", SourceType: "web", ImageUrl: &wide},
+	}}
+	if _, err := server.restoreBackup(alice, backup, "merge"); err != nil {
+		t.Fatal(err)
+	}
+	for url, want := range map[string]string{"https://cdn.example/a": "", "https://cdn.example/b": wide} {
+		if b, _ := q.GetBookmarkByURL(alice, dbgen.GetBookmarkByURLParams{OwnerID: "alice", Url: url}); deref(b.ImageUrl) != want {
+			t.Errorf("restored %s with image_url %q, want %q", url, deref(b.ImageUrl), want)
+		}
+	}
+	var queued int
+	server.DB.QueryRow("SELECT COUNT(*) FROM jobs WHERE kind = ? AND status = 'queued'", JobGenerateMetadata).Scan(&queued)
+	if queued != 1 {
+		t.Errorf("%d generate_metadata jobs queued, want 1", queued)
+	}
+}
@@ -0,0 +1,224 @@
+package srv
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// API token scopes. write implies read, and admin implies both.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var allScopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// userScopes are granted to users identified by the exe.dev headers. Only
+// the server's Admins also get admin.
+var userScopes = []string{ScopeRead, ScopeWrite}
+
+// tokenPrefix marks bookmark manager tokens so they are easy to spot in
+// config files and secret scanners.
+const tokenPrefix = "bmk_"
+
+// APIToken is a token as shown to its owner. The secret itself is only
+// returned once, when the token is created.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func apiToken(t dbgen.ApiToken) APIToken {
+	return APIToken{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     strings.Split(t.Scopes, ","),
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hasScope reports whether scopes grant need.
+func hasScope(scopes []string, need string) bool {
+	for _, s := range scopes {
+		if s == need || s == ScopeAdmin || (s == ScopeWrite && need == ScopeRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredScope is the scope an API request from u needs: admin for the
+// secrets and git settings shared by the whole deployment and to manage
+// tokens with a token, read for safe methods and write for everything
+// else.
+func requiredScope(r *http.Request, u User) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/secrets"), strings.HasPrefix(r.URL.Path, "/api/github/config"):
+		return ScopeAdmin
+	case strings.HasPrefix(r.URL.Path, "/api/tokens") && u.TokenID != 0:
+		return ScopeAdmin
+	case isSafeMethod(r.Method):
+		return ScopeRead
+	default:
+		return ScopeWrite
+	}
+}
+
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// isCrossOrigin reports whether a browser sent r from another site. Requests
+// from non-browser clients carry neither header and are not cross-origin.
+func isCrossOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site != "same-origin" && site != "none"
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	i := strings.Index(origin, "://")
+	return i < 0 || origin[i+3:] != r.Host
+}
+
+var errInvalidToken = errors.New("invalid API token")
+
+// authenticateToken resolves an "Authorization: Bearer" token to its owner.
+// It returns a zero User when the request has no bearer token.
+func (s *Server) authenticateToken(r *http.Request) (User, error) {
+	ctx := r.Context()
+	auth := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return User{}, nil
+	}
+	q := dbgen.New(s.DB)
+	t, err := q.GetAPITokenByHash(ctx, hashToken(strings.TrimSpace(token)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, errInvalidToken
+	} else if err != nil {
+		return User{}, err
+	}
+	if err := q.TouchAPIToken(ctx, t.ID); err != nil {
+		slog.Warn("tokens: record use", "token", t.ID, "error", err)
+	}
+	return User{ID: t.OwnerID, Scopes: strings.Split(t.Scopes, ","), TokenID: t.ID}, nil
+}
+
+// HandleListTokens returns the current user's API tokens.
+func (s *Server) HandleListTokens(w http.ResponseWriter, r *http.Request) {
+	rows, err := dbgen.New(s.DB).ListAPITokens(r.Context(), ownerID(r.Context()))
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	tokens := make([]APIToken, len(rows))
+	for i, t := range rows {
+		tokens[i] = apiToken(t)
+	}
+	writeJSON(w, tokens)
+}
+
+// HandleCreateToken creates an API token. The response is the only time the
+// token itself is returned.
+func (s *Server) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid JSON", 400)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, "name required", 400)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{ScopeRead, ScopeWrite}
+	}
+	u, _ := userFromContext(r.Context())
+	for _, scope := range req.Scopes {
+		if !slices.Contains(allScopes, scope) {
+			writeError(w, fmt.Sprintf("unknown scope %q", scope), 400)
+			return
+		}
+		if !hasScope(u.Scopes, scope) {
+			writeError(w, fmt.Sprintf("cannot grant the %s scope", scope), 403)
+			return
+		}
+	}
+
+	token, err := newToken()
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	t, err := dbgen.New(s.DB).CreateAPIToken(r.Context(), dbgen.CreateAPITokenParams{
+		OwnerID:   ownerID(r.Context()),
+		Name:      req.Name,
+		TokenHash: hashToken(token),
+		Prefix:    token[:len(tokenPrefix)+6],
+		Scopes:    strings.Join(req.Scopes, ","),
+	})
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	resp := apiToken(t)
+	resp.Token = token
+	w.WriteHeader(201)
+	writeJSON(w, resp)
+}
+
+// HandleRevokeToken deletes one of the current user's API tokens.
+func (s *Server) HandleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	n, err := dbgen.New(s.DB).DeleteAPIToken(r.Context(), dbgen.DeleteAPITokenParams{OwnerID: ownerID(r.Context()), ID: id})
+	if err != nil {
+		writeError(w, err.Error(), 500)
+		return
+	}
+	if n == 0 {
+		writeError(w, "not found", 404)
+		return
+	}
+	w.WriteHeader(204)
+}
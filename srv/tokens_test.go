@@ -0,0 +1,111 @@
+package srv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestAPITokens(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "tokens.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/me", server.HandleMe)
+	mux.HandleFunc("GET /api/tokens", server.HandleListTokens)
+	mux.HandleFunc("POST /api/tokens", server.HandleCreateToken)
+	mux.HandleFunc("DELETE /api/tokens/{id}", server.HandleRevokeToken)
+	mux.HandleFunc("POST /api/tags", server.HandleCreateTag)
+	handler := server.identify(mux)
+
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	alice := map[string]string{"X-ExeDev-UserID": "alice"}
+	create := func(body string) APIToken {
+		t.Helper()
+		w := do("POST", "/api/tokens", body, alice)
+		if w.Code != 201 {
+			t.Fatalf("create token status %d: %s", w.Code, w.Body.String())
+		}
+		var tok APIToken
+		json.NewDecoder(w.Body).Decode(&tok)
+		return tok
+	}
+	bearer := func(tok APIToken, extra ...string) map[string]string {
+		h := map[string]string{"Authorization": "Bearer " + tok.Token}
+		for i := 0; i+1 < len(extra); i += 2 {
+			h[extra[i]] = extra[i+1]
+		}
+		return h
+	}
+
+	readOnly := create(`{"name":"reader","scopes":["read"]}`)
+	writer := create(`{"name":"extension"}`)
+	if !strings.HasPrefix(writer.Token, tokenPrefix) || !strings.HasPrefix(writer.Token, writer.Prefix) {
+		t.Errorf("unexpected token %+v", writer)
+	}
+	if w := do("POST", "/api/tokens", `{"name":"x","scopes":["root"]}`, alice); w.Code != 400 {
+		t.Errorf("expected 400 for unknown scope, got %d", w.Code)
+	}
+
+	w := do("GET", "/api/me", "", bearer(readOnly))
+	var me User
+	json.NewDecoder(w.Body).Decode(&me)
+	if w.Code != 200 || me.ID != "alice" || me.TokenID != readOnly.ID {
+		t.Errorf("token did not authenticate as alice: %d %+v", w.Code, me)
+	}
+	if w := do("POST", "/api/tags", `{"name":"go"}`, bearer(readOnly)); w.Code != 403 {
+		t.Errorf("read-only token should not write, got %d", w.Code)
+	}
+	if w := do("POST", "/api/tags", `{"name":"go"}`, bearer(writer, "Origin", "chrome-extension://abc")); w.Code != 201 {
+		t.Errorf("write token from the extension got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/tokens", "", bearer(writer)); w.Code != 403 {
+		t.Errorf("managing tokens should need the admin scope, got %d", w.Code)
+	}
+	if w := do("GET", "/api/me", "", map[string]string{"Authorization": "Bearer bmk_nope"}); w.Code != 401 {
+		t.Errorf("expected 401 for unknown token, got %d", w.Code)
+	}
+
+	crossSite := map[string]string{"X-ExeDev-UserID": "alice", "Sec-Fetch-Site": "cross-site"}
+	if w := do("POST", "/api/tags", `{"name":"csrf"}`, crossSite); w.Code != 403 {
+		t.Errorf("cross-origin write without a token should be rejected, got %d", w.Code)
+	}
+	if w := do("GET", "/api/me", "", crossSite); w.Code != 200 {
+		t.Errorf("cross-origin reads are allowed, got %d", w.Code)
+	}
+
+	var tokens []APIToken
+	json.NewDecoder(do("GET", "/api/tokens", "", alice).Body).Decode(&tokens)
+	if len(tokens) != 2 || tokens[0].Token != "" {
+		t.Fatalf("unexpected token list %+v", tokens)
+	}
+	stored, _ := dbgen.New(server.DB).GetAPITokenByHash(t.Context(), hashToken(readOnly.Token))
+	if stored.LastUsedAt == nil {
+		t.Error("last use not recorded")
+	}
+
+	if w := do("DELETE", fmt.Sprintf("/api/tokens/%d", readOnly.ID), "", map[string]string{"X-ExeDev-UserID": "bob"}); w.Code != 404 {
+		t.Errorf("bob revoked alice's token: %d", w.Code)
+	}
+	if w := do("DELETE", fmt.Sprintf("/api/tokens/%d", readOnly.ID), "", alice); w.Code != 204 {
+		t.Errorf("revoke status %d", w.Code)
+	}
+	if w := do("GET", "/api/me", "", bearer(readOnly)); w.Code != 401 {
+		t.Errorf("revoked token still works: %d", w.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package srv
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// User is the account a request acts as. Every bookmark, tag, collection
+// and job belongs to exactly one user through its owner_id.
+type User struct {
+	ID     string   `json:"id"`
+	Email  string   `json:"email"`
+	Scopes []string `json:"scopes"`
+	// TokenID is set when the request authenticated with an API token.
+	TokenID int64 `json:"token_id,omitempty"`
+}
+
+type userContextKey struct{}
+
+func withUser(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, u)
+}
+
+func withOwner(ctx context.Context, ownerID string) context.Context {
+	return withUser(ctx, User{ID: ownerID})
+}
+
+// userFromContext returns the user set by the identify middleware or the
+// job queue.
+func userFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(userContextKey{}).(User)
+	return u, ok
+}
+
+// ownerID returns the current user's ID, or "" outside of a request.
+func ownerID(ctx context.Context) string {
+	u, _ := userFromContext(ctx)
+	return u.ID
+}
+
+// identify resolves the current user from an API token or from the
+// X-ExeDev-UserID and X-ExeDev-Email headers set by the exe.dev proxy.
+// Requests without either act as DefaultOwner when AllowAnonymous is set;
+// otherwise API requests are rejected and pages are served without a user.
+//
+// API requests must also hold the scope requiredScope asks for, and writes
+// from another origin must use a token: the exe.dev headers ride along on
+// any request the browser sends, so they cannot tell a forged form post
+// from a real one.
+func (s *Server) identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := s.authenticateToken(r)
+		if err != nil {
+			writeError(w, err.Error(), 401)
+			return
+		}
+		if u.ID == "" {
+			u = User{
+				ID:    strings.TrimSpace(r.Header.Get("X-ExeDev-UserID")),
+				Email: strings.TrimSpace(r.Header.Get("X-ExeDev-Email")),
+			}
+			u.Scopes = s.headerUserScopes(u.ID)
+		}
+		if u.ID == "" && s.AllowAnonymous {
+			u = User{ID: s.DefaultOwner, Scopes: userScopes}
+		}
+		api := strings.HasPrefix(r.URL.Path, "/api/")
+		if u.ID == "" {
+			if api {
+				writeError(w, "authentication required", 401)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if api {
+			if u.TokenID == 0 && !isSafeMethod(r.Method) && isCrossOrigin(r) {
+				writeError(w, "cross-origin requests require an API token", 403)
+				return
+			}
+			if need := requiredScope(r, u); !hasScope(u.Scopes, need) {
+				writeError(w, "the "+need+" scope is required", 403)
+				return
+			}
+		}
+		s.rememberUser(r.Context(), u)
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), u)))
+	})
+}
+
+// headerUserScopes returns the scopes of a user identified by the exe.dev
+// headers: read and write, and admin for the configured Admins.
+func (s *Server) headerUserScopes(id string) []string {
+	if id != "" && slices.Contains(s.Admins, id) {
+		return allScopes
+	}
+	return userScopes
+}
+
+// rememberUser records the user the first time this process sees them, or
+// when their email changes.
+func (s *Server) rememberUser(ctx context.Context, u User) {
+	if email, ok := s.seenUsers.Load(u.ID); ok && email == u.Email {
+		return
+	}
+	if err := dbgen.New(s.DB).UpsertUser(ctx, dbgen.UpsertUserParams{ID: u.ID, Email: u.Email}); err != nil {
+		slog.Warn("users: record", "user", u.ID, "error", err)
+		return
+	}
+	s.seenUsers.Store(u.ID, u.Email)
+}
+
+// HandleMe returns the current user.
+func (s *Server) HandleMe(w http.ResponseWriter, r *http.Request) {
+	u, _ := userFromContext(r.Context())
+	writeJSON(w, u)
+}
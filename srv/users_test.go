@@ -0,0 +1,97 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"srv.exe.dev/db"
+	"srv.exe.dev/db/dbgen"
+)
+
+func TestOwnerIsolation(t *testing.T) {
+	server, err := New(filepath.Join(t.TempDir(), "owners.sqlite3"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := dbgen.New(server.DB)
+	alice := withOwner(context.Background(), "alice")
+	bob := withOwner(context.Background(), "bob")
+
+	ab, err := q.CreateBookmark(alice, dbgen.CreateBookmarkParams{OwnerID: ownerID(alice), Url: "https://go.dev/", Title: "Go", SourceType: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.CreateBookmark(bob, dbgen.CreateBookmarkParams{OwnerID: ownerID(bob), Url: "https://go.dev/", Title: "Go for Bob", SourceType: "web"})
+	for _, ctx := range []context.Context{alice, bob} {
+		if _, err := q.CreateTag(ctx, dbgen.CreateTagParams{OwnerID: ownerID(ctx), Name: "golang", Color: strPtr("#000000")}); err != nil {
+			t.Fatalf("same tag name for %s: %v", ownerID(ctx), err)
+		}
+	}
+	bobTag, _ := q.GetTagByName(bob, dbgen.GetTagByNameParams{OwnerID: "bob", Name: "golang"})
+	q.AddTagToBookmark(bob, dbgen.AddTagToBookmarkParams{OwnerID: "bob", BookmarkID: ab.ID, TagID: bobTag.ID})
+	if tags, _ := q.GetBookmarkTags(alice, dbgen.GetBookmarkTagsParams{OwnerID: "alice", BookmarkID: ab.ID}); len(tags) != 0 {
+		t.Errorf("bob tagged alice's bookmark: %v", tags)
+	}
+
+	handler := server.identify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "" {
+			server.HandleGetBookmark(w, r)
+			return
+		}
+		server.HandleSearch(w, r)
+	}))
+	get := func(user, path, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.SetPathValue("id", id)
+		if user != "" {
+			req.Header.Set("X-ExeDev-UserID", user)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("", "/api/search?q=go", ""); w.Code != 401 {
+		t.Errorf("expected 401 without identity, got %d", w.Code)
+	}
+	w := get("bob", "/api/search?q=go", "")
+	var resp struct {
+		Bookmarks []SearchResult `json:"bookmarks"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Bookmarks) != 1 || resp.Bookmarks[0].Bookmark.Title != "Go for Bob" {
+		t.Errorf("bob's search leaked other users' bookmarks: %+v", resp.Bookmarks)
+	}
+	if w := get("bob", "/api/bookmarks/x", fmt.Sprint(ab.ID)); w.Code != 404 {
+		t.Errorf("bob read alice's bookmark: %d", w.Code)
+	}
+	if w := get("alice", "/api/bookmarks/x", fmt.Sprint(ab.ID)); w.Code != 200 {
+		t.Errorf("alice could not read her bookmark: %d", w.Code)
+	}
+
+	server.AllowAnonymous, server.DefaultOwner = true, "alice"
+	if w := get("", "/api/bookmarks/x", fmt.Sprint(ab.ID)); w.Code != 200 {
+		t.Errorf("anonymous request should act as the default owner, got %d", w.Code)
+	}
+
+	if _, err := server.DB.Exec("INSERT INTO collections (name) VALUES ('Legacy')"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.CountUnowned(server.DB); err != nil || n != 1 {
+		t.Errorf("CountUnowned = %d, %v", n, err)
+	}
+	if n, err := db.AssignUnowned(server.DB, "alice"); err != nil || n != 1 {
+		t.Errorf("AssignUnowned = %d, %v", n, err)
+	}
+	if n, _ := db.CountUnowned(server.DB); n != 0 {
+		t.Errorf("%d rows left unowned", n)
+	}
+	if _, err := q.GetCollectionByName(alice, dbgen.GetCollectionByNameParams{OwnerID: "alice", Name: "Legacy"}); err != nil {
+		t.Errorf("legacy collection not assigned: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package srv
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,7 +9,6 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
-	"time"
 
 	"srv.exe.dev/db/dbgen"
 )
@@ -37,15 +37,27 @@ func (s *Server) HandleYouTubeImport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	progress := s.newProgress(r, "youtube_import")
+	defer progress.done()
+
+	if req.APIKey == "" {
+		key, err := s.Secrets.Get(r.Context(), SecretYouTubeAPIKey)
+		if err != nil {
+			writeError(w, err.Error(), 500)
+			return
+		}
+		req.APIKey = key
+	}
+
 	var videos []YouTubeVideo
 	var err error
 
 	if req.APIKey != "" {
 		// Use official API if key provided
-		videos, err = fetchPlaylistWithAPI(playlistID, req.APIKey)
+		videos, err = s.fetchPlaylistWithAPI(r.Context(), playlistID, req.APIKey)
 	} else {
 		// Scrape without API key
-		videos, err = scrapePlaylist(playlistID)
+		videos, err = s.scrapePlaylist(r.Context(), playlistID)
 	}
 
 	if err != nil {
@@ -54,31 +66,40 @@ func (s *Server) HandleYouTubeImport(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save videos as bookmarks
+	progress.found(len(videos))
 	q := dbgen.New(s.DB)
 	saved := 0
 	for _, v := range videos {
-		_, err := q.GetBookmarkByURL(r.Context(), v.URL)
+		progress.item(v.URL)
+		_, err := q.GetBookmarkByURL(r.Context(), dbgen.GetBookmarkByURLParams{OwnerID: ownerID(r.Context()), Url: v.URL})
 		if err == nil {
+			progress.skipped()
 			continue // Already exists
 		}
 
-		_, err = q.CreateBookmark(r.Context(), dbgen.CreateBookmarkParams{
+		bookmark, err := q.CreateBookmark(r.Context(), dbgen.CreateBookmarkParams{
+			OwnerID:     ownerID(r.Context()),
 			Url:         v.URL,
 			Title:       v.Title,
 			Description: strPtr(v.Description),
 			SourceType:  "youtube",
-			ImageUrl:    strPtr(v.Thumbnail),
+			ImageUrl:    strPtr(s.previewImage(r.Context(), v.URL, []string{v.Thumbnail})),
 		})
-		if err == nil {
-			saved++
+		if err != nil {
+			progress.failed()
+			continue
 		}
+		saved++
+		progress.saved()
+		s.Events.Publish(r.Context(), EventBookmarkCreated, bookmark)
 	}
 
 	writeJSON(w, map[string]any{
-		"found":   len(videos),
-		"saved":   saved,
-		"skipped": len(videos) - saved,
-		"videos":  videos,
+		"found":        len(videos),
+		"saved":        saved,
+		"skipped":      len(videos) - saved,
+		"videos":       videos,
+		"operation_id": progress.OperationID,
 	})
 }
 
@@ -102,10 +123,10 @@ func extractPlaylistID(rawURL string) string {
 	return ""
 }
 
-func fetchPlaylistWithAPI(playlistID, apiKey string) ([]YouTubeVideo, error) {
+func (s *Server) fetchPlaylistWithAPI(ctx context.Context, playlistID, apiKey string) ([]YouTubeVideo, error) {
 	var videos []YouTubeVideo
 	nextPageToken := ""
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := s.httpClient()
 
 	for {
 		apiURL := fmt.Sprintf(
@@ -116,7 +137,11 @@ func fetchPlaylistWithAPI(playlistID, apiKey string) ([]YouTubeVideo, error) {
 			apiURL += "&pageToken=" + nextPageToken
 		}
 
-		resp, err := client.Get(apiURL)
+		req, err := s.newFetchRequest(ctx, apiURL)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
 		if err != nil {
 			return nil, err
 		}
@@ -171,15 +196,16 @@ func fetchPlaylistWithAPI(playlistID, apiKey string) ([]YouTubeVideo, error) {
 	return videos, nil
 }
 
-func scrapePlaylist(playlistID string) ([]YouTubeVideo, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
+func (s *Server) scrapePlaylist(ctx context.Context, playlistID string) ([]YouTubeVideo, error) {
 	playlistURL := "https://www.youtube.com/playlist?list=" + playlistID
 
-	req, _ := http.NewRequest("GET", playlistURL, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	req, err := s.newFetchRequest(ctx, playlistURL)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 
-	resp, err := client.Do(req)
+	resp, err := s.httpClient().Do(req)
 	if err != nil {
 		return nil, err
 	}